@@ -0,0 +1,61 @@
+package frankenphp
+
+// #cgo nocallback frankenphp_interrupt_vm
+// #cgo noescape frankenphp_interrupt_vm
+// #include "frankenphp.h"
+import "C"
+import (
+	"context"
+	"unsafe"
+)
+
+// watchClientDisconnect interrupts the script running on the thread once the client has disconnected.
+// The interrupt only aborts the script if WithAbortOnClientDisconnect is enabled and ignore_user_abort() is not,
+// otherwise connection_aborted() and connection_status() just report the disconnection.
+func (fc *frankenPHPContext) watchClientDisconnect(thread *phpThread) {
+	if fc.responseWriter == nil {
+		return
+	}
+
+	fc.stopWatchingDisconnect = context.AfterFunc(fc.request.Context(), thread.interrupt)
+}
+
+// stopWatchingClientDisconnect must be called before the request context is canceled by the HTTP server
+func (fc *frankenPHPContext) stopWatchingClientDisconnect() {
+	if fc.stopWatchingDisconnect == nil {
+		return
+	}
+
+	fc.stopWatchingDisconnect()
+	fc.stopWatchingDisconnect = nil
+}
+
+// interrupt sets the VM interrupt flag of the thread, the Zend VM checks it on every loop iteration and function call.
+// Blocking calls (sleep(), database queries...) are not interrupted, the script is interrupted once they return.
+func (thread *phpThread) interrupt() {
+	thread.vmInterruptMu.Lock()
+	defer thread.vmInterruptMu.Unlock()
+
+	if thread.vmInterrupt != nil {
+		C.frankenphp_interrupt_vm(thread.vmInterrupt)
+	}
+}
+
+//export go_frankenphp_set_vm_interrupt
+func go_frankenphp_set_vm_interrupt(threadIndex C.uintptr_t, vmInterrupt unsafe.Pointer) {
+	thread := phpThreads[threadIndex]
+
+	thread.vmInterruptMu.Lock()
+	thread.vmInterrupt = vmInterrupt
+	thread.vmInterruptMu.Unlock()
+}
+
+//export go_is_client_disconnected
+func go_is_client_disconnected(threadIndex C.uintptr_t) C.bool {
+	fc := phpThreads[threadIndex].getRequestContext()
+	if fc == nil || fc.responseWriter == nil || fc.isDone {
+		return C.bool(false)
+	}
+
+	return C.bool(fc.clientHasClosed())
+}
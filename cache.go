@@ -0,0 +1,196 @@
+package frankenphp
+
+// #include "frankenphp.h"
+import "C"
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"github.com/dunglas/frankenphp/internal/cache"
+)
+
+// defaultCacheMaxMemory is the default capacity of the shared cache
+const defaultCacheMaxMemory = 64 * 1024 * 1024
+
+// expired entries are removed at this interval, they are otherwise removed once accessed or evicted
+const cacheCleanupInterval = time.Minute
+
+var (
+	// ErrCacheMiss is returned when a key is not in the shared cache or has expired
+	ErrCacheMiss = errors.New("cache miss")
+	// ErrCacheValueTooLarge is returned when a value is larger than the capacity of the shared cache
+	ErrCacheValueTooLarge = cache.ErrTooLarge
+	// ErrCacheNotAnInteger is returned when incrementing a value that is not an integer
+	ErrCacheNotAnInteger = errors.New("cached value is not an integer")
+
+	// sharedCache is shared by all PHP threads and outlives restarts of FrankenPHP
+	sharedCache      = newSharedCache()
+	cacheCleanupDone chan struct{}
+)
+
+func newSharedCache() *cache.Cache {
+	c := cache.New(defaultCacheMaxMemory)
+	c.OnEvict = func(reason cache.EvictionReason) {
+		metrics.EvictedCacheEntry(reason == cache.EvictionExpired)
+	}
+
+	return c
+}
+
+func initCache(maxMemory int64) {
+	if maxMemory == 0 {
+		maxMemory = defaultCacheMaxMemory
+	}
+	sharedCache.SetMaxBytes(maxMemory)
+	updateCacheMetrics()
+
+	cacheCleanupDone = make(chan struct{})
+	go func(done chan struct{}) {
+		ticker := time.NewTicker(cacheCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				sharedCache.DeleteExpired()
+				updateCacheMetrics()
+			}
+		}
+	}(cacheCleanupDone)
+}
+
+func drainCache() {
+	if cacheCleanupDone == nil {
+		return
+	}
+
+	close(cacheCleanupDone)
+	cacheCleanupDone = nil
+}
+
+func updateCacheMetrics() {
+	metrics.CacheUsage(sharedCache.Len(), sharedCache.Bytes())
+}
+
+// CacheGet returns the value stored in the shared cache by PHP scripts (frankenphp_cache_set()) or by CacheSet.
+// Values are converted from PHP: lists become []any, other arrays become map[string]any,
+// integers are int64 and floats are float64. ErrCacheMiss is returned if the key does not exist or has expired.
+func CacheGet(key string) (any, error) {
+	b, ok := cacheGet(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrCacheMiss, key)
+	}
+
+	return unserializePHP(b)
+}
+
+// CacheSet stores a value in the shared cache, PHP scripts can read it with frankenphp_cache_get().
+// The value is converted to PHP in the same way as the payload of DispatchTask. A ttl of 0 means that the value never expires.
+func CacheSet(key string, value any, ttl time.Duration) error {
+	b, err := serializePHP(value)
+	if err != nil {
+		return err
+	}
+
+	return cacheSet(key, b, ttl)
+}
+
+// CacheDelete removes a key from the shared cache and reports whether it existed
+func CacheDelete(key string) bool {
+	deleted := sharedCache.Delete(key)
+	updateCacheMetrics()
+
+	return deleted
+}
+
+// CacheIncrement atomically adds step to the integer stored in the shared cache and returns the new value.
+// Keys that do not exist are created with the value step and the given ttl, the ttl of existing keys is kept.
+func CacheIncrement(key string, step int64, ttl time.Duration) (int64, error) {
+	var result int64
+	err := sharedCache.Update(key, ttl, func(value []byte, exists bool) ([]byte, error) {
+		result = step
+		if exists {
+			v, err := unserializePHP(value)
+			current, ok := v.(int64)
+			if err != nil || !ok {
+				return nil, fmt.Errorf("%w: %q", ErrCacheNotAnInteger, key)
+			}
+
+			result += current
+		}
+
+		return appendPHPInt(nil, result), nil
+	})
+	updateCacheMetrics()
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+// CacheClear removes all keys from the shared cache
+func CacheClear() {
+	sharedCache.Clear()
+	updateCacheMetrics()
+}
+
+func cacheGet(key string) ([]byte, bool) {
+	b, ok := sharedCache.Get(key)
+	if ok {
+		metrics.CacheHit()
+	} else {
+		metrics.CacheMiss()
+	}
+
+	return b, ok
+}
+
+func cacheSet(key string, value []byte, ttl time.Duration) error {
+	err := sharedCache.Set(key, value, ttl)
+	updateCacheMetrics()
+
+	return err
+}
+
+// go_frankenphp_cache_get returns the serialized value of the key, the value is pinned until the end of the script
+//
+//export go_frankenphp_cache_get
+func go_frankenphp_cache_get(threadIndex C.uintptr_t, key *C.char, keyLen C.size_t) (*C.char, C.size_t, C.bool) {
+	b, ok := cacheGet(C.GoStringN(key, C.int(keyLen)))
+	if !ok {
+		return nil, 0, C.bool(false)
+	}
+
+	p := unsafe.SliceData(b)
+	phpThreads[threadIndex].Pin(p)
+
+	return (*C.char)(unsafe.Pointer(p)), C.size_t(len(b)), C.bool(true)
+}
+
+//export go_frankenphp_cache_set
+func go_frankenphp_cache_set(key *C.char, keyLen C.size_t, value *C.char, valueLen C.size_t, ttl C.zend_long) C.bool {
+	err := cacheSet(C.GoStringN(key, C.int(keyLen)), C.GoBytes(unsafe.Pointer(value), C.int(valueLen)), time.Duration(ttl)*time.Second)
+
+	return C.bool(err == nil)
+}
+
+//export go_frankenphp_cache_delete
+func go_frankenphp_cache_delete(key *C.char, keyLen C.size_t) C.bool {
+	return C.bool(CacheDelete(C.GoStringN(key, C.int(keyLen))))
+}
+
+//export go_frankenphp_cache_increment
+func go_frankenphp_cache_increment(key *C.char, keyLen C.size_t, step C.zend_long, ttl C.zend_long) (C.zend_long, C.bool) {
+	v, err := CacheIncrement(C.GoStringN(key, C.int(keyLen)), int64(step), time.Duration(ttl)*time.Second)
+	if err != nil {
+		return 0, C.bool(false)
+	}
+
+	return C.zend_long(v), C.bool(true)
+}
@@ -0,0 +1,83 @@
+package frankenphp_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dunglas/frankenphp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_module(t *testing.T) { testCache(t, &testOptions{}) }
+func TestCache_worker(t *testing.T) {
+	testCache(t, &testOptions{workerScript: "cache.php"})
+}
+func testCache(t *testing.T, opts *testOptions) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		key := fmt.Sprintf("key%d", i)
+		url := "http://example.com/cache.php?key=" + key + "&action="
+
+		assert.Equal(t, "'default'", fetchBody("GET", url+"get", handler))
+		assert.Equal(t, "true", fetchBody("GET", url+"set&value=hello", handler))
+		assert.Equal(t, "array (\n  'value' => 'hello',\n  'number' => 42,\n)", fetchBody("GET", url+"get", handler))
+
+		// values set from PHP can be read from Go
+		v, err := frankenphp.CacheGet(key)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"value": "hello", "number": int64(42)}, v)
+
+		assert.Equal(t, "false", fetchBody("GET", url+"increment", handler), "only integers can be incremented")
+		assert.Equal(t, "true", fetchBody("GET", url+"delete", handler))
+		assert.Equal(t, "false", fetchBody("GET", url+"delete", handler))
+		assert.Equal(t, "1", fetchBody("GET", url+"increment", handler))
+		assert.Equal(t, "11", fetchBody("GET", url+"increment&step=10", handler))
+		assert.True(t, frankenphp.CacheDelete(key))
+	}, opts)
+}
+
+func TestCacheSetFromGo(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		require.NoError(t, frankenphp.CacheSet("from-go", []any{"a", 1, true}, 0))
+
+		assert.Equal(t, "array (\n  0 => 'a',\n  1 => 1,\n  2 => true,\n)", fetchBody("GET", "http://example.com/cache.php?key=from-go&action=get", handler))
+
+		frankenphp.CacheClear()
+		_, err := frankenphp.CacheGet("from-go")
+		assert.ErrorIs(t, err, frankenphp.ErrCacheMiss)
+	}, &testOptions{nbParallelRequests: 1})
+}
+
+func TestCacheTTL(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		require.NoError(t, frankenphp.CacheSet("short-lived", "value", 10*time.Millisecond))
+
+		assert.Eventually(t, func() bool {
+			_, err := frankenphp.CacheGet("short-lived")
+
+			return err != nil
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(t, "'default'", fetchBody("GET", "http://example.com/cache.php?key=short-lived&action=get", handler))
+	}, &testOptions{nbParallelRequests: 1})
+}
+
+func TestCacheMaxMemory(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		assert.ErrorIs(t, frankenphp.CacheSet("too-large", make([]byte, 2048), 0), frankenphp.ErrCacheValueTooLarge)
+
+		for i := 0; i < 10; i++ {
+			require.NoError(t, frankenphp.CacheSet(fmt.Sprintf("key%d", i), make([]byte, 200), 0))
+		}
+
+		// the least recently used keys have been evicted
+		_, err := frankenphp.CacheGet("key0")
+		assert.ErrorIs(t, err, frankenphp.ErrCacheMiss)
+		_, err = frankenphp.CacheGet("key9")
+		assert.NoError(t, err)
+
+		frankenphp.CacheClear()
+	}, &testOptions{nbParallelRequests: 1, initOpts: []frankenphp.Option{frankenphp.WithCacheMaxMemory(1024)}})
+}
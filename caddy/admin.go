@@ -2,10 +2,13 @@ package caddy
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/caddyserver/caddy/v2"
 	"github.com/dunglas/frankenphp"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type FrankenPHPAdmin struct{}
@@ -25,10 +28,23 @@ func (admin FrankenPHPAdmin) Routes() []caddy.AdminRoute {
 			Pattern: "/frankenphp/workers/restart",
 			Handler: caddy.AdminHandlerFunc(admin.restartWorkers),
 		},
+		{
+			// worker names can contain slashes, the path is parsed by the handler
+			Pattern: "/frankenphp/workers/",
+			Handler: caddy.AdminHandlerFunc(admin.restartWorker),
+		},
 		{
 			Pattern: "/frankenphp/threads",
 			Handler: caddy.AdminHandlerFunc(admin.threads),
 		},
+		{
+			Pattern: "/frankenphp/health/ready",
+			Handler: caddy.AdminHandlerFunc(admin.ready),
+		},
+		{
+			Pattern: "/frankenphp/health/live",
+			Handler: caddy.AdminHandlerFunc(admin.live),
+		},
 	}
 }
 
@@ -37,6 +53,44 @@ func (admin *FrankenPHPAdmin) restartWorkers(w http.ResponseWriter, r *http.Requ
 		return admin.error(http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
 	}
 
+	// boot a new generation of threads and switch to it once it is ready if requested, e.g. ?mode=blue-green
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "":
+	case "blue-green":
+		// the new generation replaces all threads at once, it cannot be booted in batches
+		if r.URL.Query().Has("batch") {
+			return admin.error(http.StatusBadRequest, errors.New("the batch parameter cannot be used with the blue-green mode"))
+		}
+
+		if err := frankenphp.BlueGreenRestartWorkers(); err != nil {
+			return admin.error(http.StatusInternalServerError, err)
+		}
+
+		caddy.Log().Info("workers restarted from admin api (blue-green)")
+		admin.success(w, "workers restarted successfully\n")
+
+		return nil
+	default:
+		return admin.error(http.StatusBadRequest, fmt.Errorf("unknown restart mode %q, expected blue-green", mode))
+	}
+
+	// restart the threads of each worker in batches if requested, e.g. ?batch=2 or ?batch=25%
+	if b := r.URL.Query().Get("batch"); b != "" {
+		batch, err := frankenphp.ParseRestartBatch(b)
+		if err != nil {
+			return admin.error(http.StatusBadRequest, err)
+		}
+
+		if err := frankenphp.RollingRestartWorkers(batch); err != nil {
+			return admin.error(http.StatusInternalServerError, err)
+		}
+
+		caddy.Log().Info("workers restarted from admin api (rolling)")
+		admin.success(w, "workers restarted successfully\n")
+
+		return nil
+	}
+
 	frankenphp.RestartWorkers()
 	caddy.Log().Info("workers restarted from admin api")
 	admin.success(w, "workers restarted successfully\n")
@@ -44,6 +98,36 @@ func (admin *FrankenPHPAdmin) restartWorkers(w http.ResponseWriter, r *http.Requ
 	return nil
 }
 
+// restartWorker restarts a single worker: POST /frankenphp/workers/{name}/restart
+func (admin *FrankenPHPAdmin) restartWorker(w http.ResponseWriter, r *http.Request) error {
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/frankenphp/workers/"), "/restart")
+	if !ok || name == "" {
+		return admin.error(http.StatusNotFound, fmt.Errorf("not found"))
+	}
+
+	if r.Method != http.MethodPost {
+		return admin.error(http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+	}
+
+	indices, err := frankenphp.RestartWorker(name)
+	if err != nil {
+		if errors.Is(err, frankenphp.ErrWorkerNotFound) {
+			return admin.error(http.StatusNotFound, err)
+		}
+
+		return admin.error(http.StatusInternalServerError, err)
+	}
+
+	caddy.Log().Info("worker " + name + " restarted from admin api")
+
+	threads := make([]string, 0, len(indices))
+	for _, index := range indices {
+		threads = append(threads, strconv.Itoa(index))
+	}
+
+	return admin.success(w, fmt.Sprintf("worker restarted successfully, restarted threads: %s\n", strings.Join(threads, ", ")))
+}
+
 func (admin *FrankenPHPAdmin) threads(w http.ResponseWriter, _ *http.Request) error {
 	debugState := frankenphp.DebugState()
 	prettyJson, err := json.MarshalIndent(debugState, "", "    ")
@@ -54,6 +138,48 @@ func (admin *FrankenPHPAdmin) threads(w http.ResponseWriter, _ *http.Request) er
 	return admin.success(w, string(prettyJson))
 }
 
+// ready reports whether FrankenPHP can receive traffic, e.g. ?max_queue_depth=10&max_transition_time=30s
+func (admin *FrankenPHPAdmin) ready(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return admin.error(http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+	}
+
+	hc := FrankenPHPHealth{Check: healthCheckReady}
+	if v := r.URL.Query().Get("max_queue_depth"); v != "" {
+		depth, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return admin.error(http.StatusBadRequest, fmt.Errorf("max_queue_depth must be a positive integer"))
+		}
+		hc.MaxQueueDepth = int(depth)
+	}
+	if v := r.URL.Query().Get("max_transition_time"); v != "" {
+		d, err := caddy.ParseDuration(v)
+		if err != nil {
+			return admin.error(http.StatusBadRequest, fmt.Errorf("max_transition_time must be a valid duration (example: 30s)"))
+		}
+		hc.MaxTransitionTime = caddy.Duration(d)
+	}
+
+	if err := hc.check(); err != nil {
+		return admin.error(http.StatusServiceUnavailable, err)
+	}
+
+	return admin.success(w, "ready\n")
+}
+
+// live reports whether the PHP runtime is running
+func (admin *FrankenPHPAdmin) live(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return admin.error(http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+	}
+
+	if err := frankenphp.CheckLiveness(); err != nil {
+		return admin.error(http.StatusServiceUnavailable, err)
+	}
+
+	return admin.success(w, "live\n")
+}
+
 func (admin *FrankenPHPAdmin) success(w http.ResponseWriter, message string) error {
 	w.WriteHeader(http.StatusOK)
 	_, err := w.Write([]byte(message))
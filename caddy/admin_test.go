@@ -45,6 +45,145 @@ func TestRestartWorkerViaAdminApi(t *testing.T) {
 	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:1")
 }
 
+func TestRollingRestartWorkerViaAdminApi(t *testing.T) {
+	tester := caddytest.NewTester(t)
+	tester.InitServer(`
+		{
+			skip_install_trust
+			admin localhost:2999
+			http_port `+testPort+`
+
+			frankenphp {
+				worker ../testdata/worker-with-counter.php 1
+			}
+		}
+
+		localhost:`+testPort+` {
+			route {
+				root ../testdata
+				rewrite worker-with-counter.php
+				php
+			}
+		}
+		`, "caddyfile")
+
+	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:1")
+	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:2")
+
+	assertAdminResponse(t, tester, "POST", "workers/restart?batch=invalid", http.StatusBadRequest, "")
+	assertAdminResponse(t, tester, "POST", "workers/restart?batch=50%25", http.StatusOK, "workers restarted successfully\n")
+
+	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:1")
+}
+
+func TestBlueGreenRestartWorkerViaAdminApi(t *testing.T) {
+	tester := caddytest.NewTester(t)
+	tester.InitServer(`
+		{
+			skip_install_trust
+			admin localhost:2999
+			http_port `+testPort+`
+
+			frankenphp {
+				num_threads 2
+				max_threads 4
+				worker ../testdata/worker-with-counter.php 1
+			}
+		}
+
+		localhost:`+testPort+` {
+			route {
+				root ../testdata
+				rewrite worker-with-counter.php
+				php
+			}
+		}
+		`, "caddyfile")
+
+	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:1")
+	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:2")
+
+	assertAdminResponse(t, tester, "POST", "workers/restart?mode=invalid", http.StatusBadRequest, "")
+	assertAdminResponse(t, tester, "POST", "workers/restart?mode=blue-green&batch=2", http.StatusBadRequest, "")
+	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:3")
+
+	assertAdminResponse(t, tester, "POST", "workers/restart?mode=blue-green", http.StatusOK, "workers restarted successfully\n")
+
+	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:1")
+}
+
+func TestRestartASingleWorkerViaAdminApi(t *testing.T) {
+	tester := caddytest.NewTester(t)
+	tester.InitServer(`
+		{
+			skip_install_trust
+			admin localhost:2999
+			http_port `+testPort+`
+
+			frankenphp {
+				num_threads 3
+				worker {
+					name counter
+					file ../testdata/worker-with-counter.php
+					num 1
+				}
+				worker {
+					name env
+					file ../testdata/worker-with-env.php
+					num 1
+				}
+			}
+		}
+
+		localhost:`+testPort+` {
+			route {
+				root ../testdata
+				rewrite worker-with-counter.php
+				php
+			}
+		}
+		`, "caddyfile")
+
+	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:1")
+	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:2")
+
+	assertAdminResponse(t, tester, "POST", "workers/unknown/restart", http.StatusNotFound, "")
+	assertAdminResponse(t, tester, "GET", "workers/counter/restart", http.StatusMethodNotAllowed, "")
+	assertAdminResponse(t, tester, "POST", "workers/counter/restart", http.StatusOK, "worker restarted successfully, restarted threads: 1\n")
+
+	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:1")
+}
+
+func TestHealthChecksViaAdminApi(t *testing.T) {
+	tester := caddytest.NewTester(t)
+	tester.InitServer(`
+		{
+			skip_install_trust
+			admin localhost:2999
+			http_port `+testPort+`
+
+			frankenphp {
+				num_threads 2
+				worker ../testdata/worker-with-counter.php 1
+			}
+		}
+
+		localhost:`+testPort+` {
+			route {
+				root ../testdata
+				rewrite worker-with-counter.php
+				php
+			}
+		}
+		`, "caddyfile")
+
+	assertAdminResponse(t, tester, "GET", "health/live", http.StatusOK, "live\n")
+	assertAdminResponse(t, tester, "GET", "health/ready", http.StatusOK, "ready\n")
+	assertAdminResponse(t, tester, "GET", "health/ready?max_queue_depth=10&max_transition_time=1m", http.StatusOK, "ready\n")
+	assertAdminResponse(t, tester, "GET", "health/ready?max_queue_depth=invalid", http.StatusBadRequest, "")
+	assertAdminResponse(t, tester, "POST", "health/live", http.StatusMethodNotAllowed, "")
+}
+
 func TestShowTheCorrectThreadDebugStatus(t *testing.T) {
 	tester := caddytest.NewTester(t)
 	tester.InitServer(`
@@ -16,6 +16,7 @@ import (
 	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
 	"github.com/dunglas/frankenphp"
 	"github.com/dunglas/frankenphp/internal/fastabs"
+	"github.com/dustin/go-humanize"
 )
 
 // FrankenPHPApp represents the global "frankenphp" directive in the Caddyfile
@@ -37,6 +38,29 @@ type FrankenPHPApp struct {
 	PhpIni map[string]string `json:"php_ini,omitempty"`
 	// The maximum amount of time a request may be stalled waiting for a thread
 	MaxWaitTime time.Duration `json:"max_wait_time,omitempty"`
+	// The maximum amount of requests waiting for a regular thread, further requests are rejected with a 503
+	MaxQueueLength int `json:"max_queue_length,omitempty"`
+	// Scaling tunes the default policy used to add and remove threads at runtime
+	Scaling *scalingConfig `json:"scaling,omitempty"`
+	// ScaleDownMode defines what happens to idle autoscaled threads: "inactive" (default) or "stop"
+	ScaleDownMode string `json:"scale_down_mode,omitempty"`
+	// WatcherRestartBatch restarts workers in batches of threads ("2") or a percentage of threads ("25%") when watched files change. Default: all threads at once
+	WatcherRestartBatch string `json:"watcher_restart_batch,omitempty"`
+	// ReloadMode defines what happens to PHP on config reloads: "full" (default) restarts everything,
+	// "workers" only adds, replaces and removes the workers that changed if the other options are the same, it cannot be used with metrics
+	ReloadMode string `json:"reload_mode,omitempty"`
+	// AbortOnDisconnect interrupts PHP scripts as soon as the client disconnects, unless they called ignore_user_abort(true)
+	AbortOnDisconnect bool `json:"abort_on_disconnect,omitempty"`
+	// RequestTimeout is the maximum duration of the requests of the sites that don't set their own request_timeout. Default: 0 (no timeout)
+	RequestTimeout time.Duration `json:"request_timeout,omitempty"`
+	// RequestTimeoutGracePeriod is the time a script has to yield once interrupted by its request timeout before the client receives a 504. Default: 5s
+	RequestTimeoutGracePeriod time.Duration `json:"request_timeout_grace_period,omitempty"`
+	// CacheMaxMemory limits the memory used by the shared cache (frankenphp_cache_*() functions). Default: 64MB
+	CacheMaxMemory int64 `json:"cache_max_memory,omitempty"`
+	// SendfileRoots are the directories frankenphp_sendfile() can serve files from
+	SendfileRoots []string `json:"sendfile_roots,omitempty"`
+	// Schedules runs PHP scripts periodically on spare threads
+	Schedules []scheduleConfig `json:"schedules,omitempty"`
 
 	metrics frankenphp.Metrics
 	logger  *slog.Logger
@@ -65,6 +89,10 @@ func (f *FrankenPHPApp) Provision(ctx caddy.Context) error {
 		f.metrics = frankenphp.NewPrometheusMetrics(ctx.GetMetricsRegistry())
 	}
 
+	if f.ReloadMode == reloadModeWorkers && f.metrics != nil {
+		return errReloadModeWorkersWithMetrics
+	}
+
 	return nil
 }
 
@@ -112,15 +140,57 @@ func (f *FrankenPHPApp) Start() error {
 		frankenphp.WithMetrics(f.metrics),
 		frankenphp.WithPhpIni(f.PhpIni),
 		frankenphp.WithMaxWaitTime(f.MaxWaitTime),
+		frankenphp.WithMaxQueueLength(f.MaxQueueLength),
+		frankenphp.WithScalingPolicy(f.Scaling.policy()),
+		frankenphp.WithScaleDownMode(frankenphp.ScaleDownMode(f.ScaleDownMode)),
+		frankenphp.WithAbortOnClientDisconnect(f.AbortOnDisconnect),
+		frankenphp.WithDefaultRequestTimeout(f.RequestTimeout),
+		frankenphp.WithRequestTimeoutGracePeriod(f.RequestTimeoutGracePeriod),
+		frankenphp.WithCacheMaxMemory(f.CacheMaxMemory),
+		frankenphp.WithSendfileRoots(f.SendfileRoots...),
+	}
+	if f.WatcherRestartBatch != "" {
+		batch, err := frankenphp.ParseRestartBatch(f.WatcherRestartBatch)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, frankenphp.WithWatcherRestartBatch(batch))
 	}
-	for _, w := range append(f.Workers) {
-		opts = append(opts, frankenphp.WithWorkers(w.Name, repl.ReplaceKnown(w.FileName, ""), w.Num, w.Env, w.Watch))
+
+	for _, sc := range f.Schedules {
+		sc.Script = repl.ReplaceKnown(sc.Script, "")
+		opts = append(opts, sc.option())
 	}
 
+	workers := make([]workerConfig, 0, len(f.Workers))
+	for _, w := range f.Workers {
+		w.FileName = repl.ReplaceKnown(w.FileName, "")
+		workers = append(workers, w)
+		opts = append(opts, frankenphp.WithWorkers(w.Name, w.FileName, w.Num, w.Env, w.Watch, w.options()...))
+	}
+
+	config, err := newRunningConfig(f, workers)
+	if err != nil {
+		return err
+	}
+
+	if f.ReloadMode == reloadModeWorkers && config.canReloadWorkers(lastConfig) {
+		err := config.reloadWorkers(lastConfig)
+		if err == nil {
+			lastConfig = config
+
+			return nil
+		}
+
+		f.logger.Warn("unable to only reload the changed workers, restarting PHP", slog.Any("error", err))
+	}
+
+	lastConfig = nil
 	frankenphp.Shutdown()
 	if err := frankenphp.Init(opts...); err != nil {
 		return err
 	}
+	lastConfig = config
 
 	return nil
 }
@@ -139,6 +209,17 @@ func (f *FrankenPHPApp) Stop() error {
 	f.Workers = nil
 	f.NumThreads = 0
 	f.MaxWaitTime = 0
+	f.MaxQueueLength = 0
+	f.Scaling = nil
+	f.ScaleDownMode = ""
+	f.WatcherRestartBatch = ""
+	f.ReloadMode = ""
+	f.AbortOnDisconnect = false
+	f.RequestTimeout = 0
+	f.RequestTimeoutGracePeriod = 0
+	f.CacheMaxMemory = 0
+	f.SendfileRoots = nil
+	f.Schedules = nil
 
 	return nil
 }
@@ -187,6 +268,17 @@ func (f *FrankenPHPApp) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 				}
 
 				f.MaxWaitTime = v
+			case "max_queue_length":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+
+				v, err := strconv.ParseUint(d.Val(), 10, 32)
+				if err != nil {
+					return err
+				}
+
+				f.MaxQueueLength = int(v)
 			case "php_ini":
 				parseIniLine := func(d *caddyfile.Dispenser) error {
 					key := d.Val()
@@ -223,6 +315,109 @@ func (f *FrankenPHPApp) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 					}
 				}
 
+			case "scale_down_mode":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+
+				switch v := frankenphp.ScaleDownMode(d.Val()); v {
+				case frankenphp.ScaleDownModeInactive, frankenphp.ScaleDownModeStop:
+					f.ScaleDownMode = string(v)
+				default:
+					return fmt.Errorf(`scale_down_mode must be "inactive" or "stop", got %q`, d.Val())
+				}
+			case "watcher_restart_batch":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+
+				if _, err := frankenphp.ParseRestartBatch(d.Val()); err != nil {
+					return err
+				}
+
+				f.WatcherRestartBatch = d.Val()
+			case "reload_mode":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+
+				switch d.Val() {
+				case reloadModeFull, reloadModeWorkers:
+					f.ReloadMode = d.Val()
+				default:
+					return fmt.Errorf(`reload_mode must be "full" or "workers", got %q`, d.Val())
+				}
+			case "abort_on_disconnect":
+				if d.NextArg() {
+					return d.ArgErr()
+				}
+
+				f.AbortOnDisconnect = true
+			case "request_timeout":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+
+				v, err := time.ParseDuration(d.Val())
+				if err != nil {
+					return errors.New("request_timeout must be a valid duration (example: 30s)")
+				}
+
+				f.RequestTimeout = v
+			case "request_timeout_grace_period":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+
+				v, err := time.ParseDuration(d.Val())
+				if err != nil {
+					return errors.New("request_timeout_grace_period must be a valid duration (example: 5s)")
+				}
+
+				f.RequestTimeoutGracePeriod = v
+			case "cache_max_memory":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+
+				v, err := humanize.ParseBytes(d.Val())
+				if err != nil {
+					return errors.New("cache_max_memory must be a valid size (example: 128MB)")
+				}
+
+				f.CacheMaxMemory = int64(v)
+			case "sendfile_root":
+				roots := d.RemainingArgs()
+				if len(roots) == 0 {
+					return d.ArgErr()
+				}
+
+				for _, root := range roots {
+					if frankenphp.EmbeddedAppPath != "" && filepath.IsLocal(root) {
+						root = filepath.Join(frankenphp.EmbeddedAppPath, root)
+					}
+
+					f.SendfileRoots = append(f.SendfileRoots, root)
+				}
+			case "schedule":
+				sc, err := parseScheduleConfig(d)
+				if err != nil {
+					return err
+				}
+				for _, existing := range f.Schedules {
+					if existing.Name == sc.Name {
+						return fmt.Errorf("scheduled jobs must not have duplicate names: %q", sc.Name)
+					}
+				}
+
+				f.Schedules = append(f.Schedules, sc)
+			case "scaling":
+				sc, err := parseScalingConfig(d)
+				if err != nil {
+					return err
+				}
+
+				f.Scaling = sc
 			case "worker":
 				wc, err := parseWorkerConfig(d)
 				if err != nil {
@@ -243,7 +438,7 @@ func (f *FrankenPHPApp) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 
 				f.Workers = append(f.Workers, wc)
 			default:
-				allowedDirectives := "num_threads, max_threads, php_ini, worker, max_wait_time"
+				allowedDirectives := "num_threads, max_threads, php_ini, worker, max_wait_time, max_queue_length, scaling, scale_down_mode, watcher_restart_batch, reload_mode, abort_on_disconnect, request_timeout, request_timeout_grace_period, schedule, cache_max_memory, sendfile_root"
 				return wrongSubDirectiveError("frankenphp", allowedDirectives, d.Val())
 			}
 		}
@@ -11,6 +11,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/caddyserver/caddy/v2"
 	"github.com/caddyserver/caddy/v2/caddytest"
@@ -390,6 +391,60 @@ func TestPHPServerDirective(t *testing.T) {
 	tester.AssertGetResponse("http://localhost:"+testPort+"/not-found.txt", http.StatusOK, "I am by birth a Genevese (i not set)")
 }
 
+func TestPHPServerDirectiveWithPriorities(t *testing.T) {
+	tester := caddytest.NewTester(t)
+	tester.InitServer(`
+		{
+			skip_install_trust
+			admin localhost:2999
+			http_port `+testPort+`
+			https_port 9443
+
+			frankenphp {
+				num_threads 1
+			}
+		}
+
+		localhost:`+testPort+` {
+			root ../testdata
+			@hello path /hello.php
+			php_server {
+				priority @hello 10
+				priority -1
+			}
+		}
+		`, "caddyfile")
+
+	var wg sync.WaitGroup
+	var finished atomic.Int32
+	get := func(path string, expectedBody string) *atomic.Int32 {
+		order := &atomic.Int32{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tester.AssertGetResponse("http://localhost:"+testPort+path, http.StatusOK, expectedBody)
+			order.Store(finished.Add(1))
+		}()
+
+		return order
+	}
+
+	// the only thread is busy, the next requests are queued in the order they are sent
+	get("/sleep.php?sleep=500", "slept for 500 ms and worked for 0 iterations")
+	time.Sleep(100 * time.Millisecond)
+	low1 := get("/sleep.php?sleep=200", "slept for 200 ms and worked for 0 iterations")
+	time.Sleep(50 * time.Millisecond)
+	low2 := get("/sleep.php?sleep=200", "slept for 200 ms and worked for 0 iterations")
+	time.Sleep(50 * time.Millisecond)
+	hello := get("/hello.php", "Hello from PHP")
+	wg.Wait()
+
+	// the prioritized request is dispatched as soon as the thread is released, before the requests queued earlier
+	require.Equal(t, int32(2), hello.Load())
+	require.Greater(t, low1.Load(), hello.Load())
+	require.Greater(t, low2.Load(), hello.Load())
+}
+
 func TestPHPServerDirectiveDisableFileServer(t *testing.T) {
 	tester := caddytest.NewTester(t)
 	tester.InitServer(`
@@ -1316,3 +1371,94 @@ func TestWorkerRestart(t *testing.T) {
 			"frankenphp_worker_restarts",
 		))
 }
+
+func TestReloadOnlyChangedWorkers(t *testing.T) {
+	config := func(extraWorker string) string {
+		return `
+		{
+			skip_install_trust
+			admin localhost:2999
+			http_port ` + testPort + `
+
+			frankenphp {
+				num_threads 2
+				max_threads 4
+				reload_mode workers
+				worker {
+					name counter
+					file ../testdata/worker-with-counter.php
+					num 1
+				}
+				` + extraWorker + `
+			}
+		}
+
+		localhost:` + testPort + ` {
+			route {
+				root ../testdata
+				rewrite worker-with-counter.php
+				php
+			}
+		}
+		`
+	}
+
+	tester := caddytest.NewTester(t)
+	tester.InitServer(config(""), "caddyfile")
+	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:1")
+	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:2")
+
+	tester.InitServer(config(`worker {
+					name added
+					file ../testdata/worker-with-env.php
+					num 1
+				}`), "caddyfile")
+
+	// the unchanged worker was not restarted
+	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:3")
+
+	workerThreads := map[string]int{}
+	for _, thread := range frankenphp.DebugState().ThreadDebugStates {
+		workerThreads[thread.WorkerName]++
+	}
+	require.Equal(t, 1, workerThreads["counter"])
+	require.Equal(t, 1, workerThreads["added"])
+
+	tester.InitServer(config(""), "caddyfile")
+	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:4")
+}
+
+func TestHealthCheckDirective(t *testing.T) {
+	tester := caddytest.NewTester(t)
+	tester.InitServer(`
+		{
+			skip_install_trust
+			admin localhost:2999
+			http_port `+testPort+`
+
+			frankenphp {
+				num_threads 2
+				worker ../testdata/worker-with-counter.php 1
+			}
+		}
+
+		localhost:`+testPort+` {
+			frankenphp_health /healthz/live live
+			frankenphp_health /healthz/ready {
+				max_queue_depth 10
+			}
+
+			route {
+				root ../testdata
+				rewrite worker-with-counter.php
+				php
+			}
+		}
+		`, "caddyfile")
+
+	tester.AssertGetResponse("http://localhost:"+testPort+"/healthz/live", http.StatusOK, "live\n")
+	tester.AssertGetResponse("http://localhost:"+testPort+"/healthz/ready", http.StatusOK, "ready\n")
+
+	// the probes are not handled by the worker
+	tester.AssertGetResponse("http://localhost:"+testPort+"/", http.StatusOK, "requests:1")
+}
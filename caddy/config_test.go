@@ -1,9 +1,13 @@
 ﻿package caddy
 
 import (
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/caddyserver/caddy/v2"
 	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/dunglas/frankenphp"
 	"github.com/stretchr/testify/require"
 )
 
@@ -220,3 +224,401 @@ func TestModuleWorkerWithCustomName(t *testing.T) {
 	require.Equal(t, "m#custom-worker-name", module.Workers[0].Name, "Worker should have the custom name, prefixed with m#")
 	require.Equal(t, "m#custom-worker-name", app.Workers[0].Name, "Worker should have the custom name, prefixed with m#")
 }
+
+func TestModuleWorkerWithMaxRequests(t *testing.T) {
+	// Create a test configuration with max_requests
+	configWithMaxRequests := `
+	{
+		php {
+			worker {
+				file ../testdata/worker-with-counter.php
+				num 1
+				max_requests 100
+			}
+		}
+	}`
+
+	// Parse the configuration
+	d := caddyfile.NewTestDispenser(configWithMaxRequests)
+	module := &FrankenPHPModule{}
+
+	// Unmarshal the configuration
+	err := module.UnmarshalCaddyfile(d)
+
+	// Verify that no error was returned
+	require.NoError(t, err, "Expected no error when configuring a worker with max_requests")
+
+	// Verify that max_requests was set correctly
+	require.Len(t, module.Workers, 1, "Expected one worker to be added to the module")
+	require.Equal(t, 100, module.Workers[0].MaxRequests, "Worker should have the configured max_requests")
+}
+
+func TestModuleWorkerWithWebSocket(t *testing.T) {
+	module := &FrankenPHPModule{}
+	err := module.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		php {
+			worker {
+				file ../testdata/websocket.php
+				num 1
+				websocket
+			}
+		}
+	}`))
+
+	require.NoError(t, err, "Expected no error when configuring a WebSocket worker")
+	require.Len(t, module.Workers, 1, "Expected one worker to be added to the module")
+	require.True(t, module.Workers[0].WebSocket, "Worker should be in WebSocket mode")
+
+	module = &FrankenPHPModule{}
+	err = module.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		php {
+			worker {
+				file ../testdata/websocket.php
+				websocket yes
+			}
+		}
+	}`))
+	require.Error(t, err, "Expected an error when passing an argument to websocket")
+}
+
+func TestModuleWorkerWithTasks(t *testing.T) {
+	module := &FrankenPHPModule{}
+	err := module.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		php {
+			worker {
+				file ../testdata/task-worker.php
+				num 1
+				tasks
+			}
+		}
+	}`))
+
+	require.NoError(t, err, "Expected no error when configuring a task worker")
+	require.Len(t, module.Workers, 1, "Expected one worker to be added to the module")
+	require.True(t, module.Workers[0].Tasks, "Worker should be in task mode")
+}
+
+func TestModuleWorkerWithWarmup(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`
+	{
+		php {
+			worker {
+				file ../testdata/worker-with-counter.php
+				warmup /
+				warmup /api/products {
+					method post
+					header Accept application/json
+				}
+				num 1
+			}
+		}
+	}`)
+	module := &FrankenPHPModule{}
+
+	require.NoError(t, module.UnmarshalCaddyfile(d))
+	require.Len(t, module.Workers, 1)
+	require.Equal(t, []warmupConfig{
+		{Path: "/"},
+		{Path: "/api/products", Method: "POST", Header: http.Header{"Accept": []string{"application/json"}}},
+	}, module.Workers[0].Warmup)
+	require.Equal(t, 1, module.Workers[0].Num)
+
+	err := (&FrankenPHPModule{}).UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		php {
+			worker {
+				file ../testdata/worker-with-counter.php
+				warmup products
+			}
+		}
+	}`))
+	require.Error(t, err, "Expected an error for a warmup path without a leading slash")
+}
+
+func TestModuleWorkerWithCrashLoopPolicy(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`
+	{
+		php {
+			worker {
+				file ../testdata/worker-with-counter.php
+				crash_loop_policy disable
+				unavailable_page ../testdata/503.html
+				min_backoff 50ms
+				max_backoff 5s
+				max_consecutive_failures 3
+			}
+		}
+	}`)
+	module := &FrankenPHPModule{}
+
+	require.NoError(t, module.UnmarshalCaddyfile(d))
+	require.Len(t, module.Workers, 1)
+	require.Equal(t, "disable", module.Workers[0].CrashLoopPolicy)
+	require.Equal(t, "../testdata/503.html", module.Workers[0].UnavailablePage)
+	require.Equal(t, 50*time.Millisecond, module.Workers[0].MinBackoff)
+	require.Equal(t, 5*time.Second, module.Workers[0].MaxBackoff)
+	require.Equal(t, 3, module.Workers[0].MaxConsecutiveFailures)
+
+	err := (&FrankenPHPModule{}).UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		php {
+			worker {
+				file ../testdata/worker-with-counter.php
+				crash_loop_policy ignore
+			}
+		}
+	}`))
+	require.Error(t, err, "Expected an error for an unknown crash_loop_policy")
+
+	err = (&FrankenPHPModule{}).UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		php {
+			worker {
+				file ../testdata/worker-with-counter.php
+				min_backoff 2s
+				max_backoff 1s
+			}
+		}
+	}`))
+	require.Error(t, err, "Expected an error when min_backoff is greater than max_backoff")
+}
+
+func TestModuleRequestTimeout(t *testing.T) {
+	module := &FrankenPHPModule{}
+	require.NoError(t, module.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		php {
+			request_timeout 30s
+		}
+	}`)))
+	require.Equal(t, caddy.Duration(30*time.Second), module.RequestTimeout)
+
+	err := (&FrankenPHPModule{}).UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		php {
+			request_timeout forever
+		}
+	}`))
+	require.Error(t, err, "Expected an error for an invalid request_timeout")
+
+	app := &FrankenPHPApp{}
+	require.NoError(t, app.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		frankenphp {
+			request_timeout 1m
+			request_timeout_grace_period 2s
+		}
+	}`)))
+	require.Equal(t, time.Minute, app.RequestTimeout)
+	require.Equal(t, 2*time.Second, app.RequestTimeoutGracePeriod)
+
+	err = (&FrankenPHPApp{}).UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		frankenphp {
+			request_timeout forever
+		}
+	}`))
+	require.Error(t, err, "Expected an error for an invalid global request_timeout")
+}
+
+func TestGlobalScalingConfiguration(t *testing.T) {
+	// Create a test configuration tuning the scaling policy
+	configWithScaling := `
+	{
+		frankenphp {
+			max_threads auto
+			scaling {
+				min_stall_time 1ms
+				max_cpu_usage 0.9
+				upscale_count 4
+				max_idle_time 30s
+			}
+		}
+	}`
+
+	// Parse the configuration
+	d := caddyfile.NewTestDispenser(configWithScaling)
+	app := &FrankenPHPApp{}
+
+	// Unmarshal the configuration
+	err := app.UnmarshalCaddyfile(d)
+
+	// Verify that no error was returned
+	require.NoError(t, err, "Expected no error when configuring the scaling policy")
+
+	// Verify that the configured values were applied to the default policy
+	policy := app.Scaling.policy().(*frankenphp.CPUScalingPolicy)
+	require.Equal(t, time.Millisecond, policy.MinStallTime)
+	require.Equal(t, 0.9, policy.MaxCPUUsage)
+	require.Equal(t, 4, policy.UpscaleCount)
+	require.Equal(t, 30*time.Second, policy.MaxIdleTime)
+	require.Equal(t, frankenphp.NewCPUScalingPolicy().DownscaleCheckTime, policy.DownscaleCheckTime, "Unset values should keep their defaults")
+}
+
+func TestGlobalScaleDownModeMustBeValid(t *testing.T) {
+	app := &FrankenPHPApp{}
+	require.NoError(t, app.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		frankenphp {
+			scale_down_mode stop
+		}
+	}`)))
+	require.Equal(t, "stop", app.ScaleDownMode)
+
+	app = &FrankenPHPApp{}
+	err := app.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		frankenphp {
+			scale_down_mode terminate
+		}
+	}`))
+	require.Error(t, err, "Expected an error for an unknown scale_down_mode")
+}
+
+func TestGlobalReloadModeMustBeValid(t *testing.T) {
+	app := &FrankenPHPApp{}
+	require.NoError(t, app.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		frankenphp {
+			reload_mode workers
+		}
+	}`)))
+	require.Equal(t, "workers", app.ReloadMode)
+
+	app = &FrankenPHPApp{}
+	err := app.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		frankenphp {
+			reload_mode partial
+		}
+	}`))
+	require.Error(t, err, "Expected an error for an unknown reload_mode")
+}
+
+func TestGlobalScheduleConfiguration(t *testing.T) {
+	app := &FrankenPHPApp{}
+	require.NoError(t, app.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		frankenphp {
+			schedule cleanup {
+				cron "*/5 * * * *"
+				script bin/cleanup.php
+				args --verbose --limit=10
+				env APP_ENV prod
+			}
+			schedule report {
+				cron @daily
+				script bin/report.php
+			}
+		}
+	}`)))
+
+	require.Len(t, app.Schedules, 2)
+	require.Equal(t, scheduleConfig{
+		Name:   "cleanup",
+		Cron:   "*/5 * * * *",
+		Script: "bin/cleanup.php",
+		Args:   []string{"--verbose", "--limit=10"},
+		Env:    map[string]string{"APP_ENV": "prod"},
+	}, app.Schedules[0])
+	require.Equal(t, "report", app.Schedules[1].Name)
+	require.Equal(t, "@daily", app.Schedules[1].Cron)
+}
+
+func TestGlobalScheduleMustBeValid(t *testing.T) {
+	for name, config := range map[string]string{
+		"invalid cron expression": `schedule job {
+			cron "61 * * * *"
+			script job.php
+		}`,
+		"missing cron expression": `schedule job {
+			script job.php
+		}`,
+		"missing script": `schedule job {
+			cron @hourly
+		}`,
+		"missing name": `schedule {
+			cron @hourly
+			script job.php
+		}`,
+		"duplicate name": `schedule job {
+			cron @hourly
+			script job.php
+		}
+		schedule job {
+			cron @daily
+			script other.php
+		}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := &FrankenPHPApp{}
+			err := app.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		frankenphp {
+			` + config + `
+		}
+	}`))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestGlobalCacheMaxMemory(t *testing.T) {
+	app := &FrankenPHPApp{}
+	require.NoError(t, app.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		frankenphp {
+			cache_max_memory 128MB
+		}
+	}`)))
+	require.Equal(t, int64(128_000_000), app.CacheMaxMemory)
+
+	app = &FrankenPHPApp{}
+	err := app.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		frankenphp {
+			cache_max_memory lots
+		}
+	}`))
+	require.Error(t, err, "Expected an error for an invalid cache_max_memory")
+}
+
+func TestGlobalSendfileRoot(t *testing.T) {
+	app := &FrankenPHPApp{}
+	require.NoError(t, app.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		frankenphp {
+			sendfile_root /var/www/private /srv/downloads
+			sendfile_root /tmp
+		}
+	}`)))
+	require.Equal(t, []string{"/var/www/private", "/srv/downloads", "/tmp"}, app.SendfileRoots)
+
+	app = &FrankenPHPApp{}
+	err := app.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	{
+		frankenphp {
+			sendfile_root
+		}
+	}`))
+	require.Error(t, err, "Expected an error for a sendfile_root without directory")
+}
+
+func TestHealthCheckDirectiveMustBeValid(t *testing.T) {
+	hc := &FrankenPHPHealth{}
+	require.NoError(t, hc.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`
+	frankenphp_health live {
+		max_queue_depth 10
+		max_transition_time 1m
+	}`)))
+	require.Equal(t, "live", hc.Check)
+	require.Equal(t, 10, hc.MaxQueueDepth)
+	require.Equal(t, caddy.Duration(time.Minute), hc.MaxTransitionTime)
+
+	hc = &FrankenPHPHealth{}
+	err := hc.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`frankenphp_health started`))
+	require.Error(t, err, "Expected an error for an unknown health check")
+}
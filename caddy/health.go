@@ -0,0 +1,142 @@
+package caddy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/dunglas/frankenphp"
+)
+
+const (
+	healthCheckReady = "ready"
+	healthCheckLive  = "live"
+)
+
+// FrankenPHPHealth represents the "frankenphp_health" directive in the Caddyfile
+// it answers readiness and liveness probes from the state of the PHP threads, PHP threads are never used to answer them
+//
+//	frankenphp_health /healthz/ready ready {
+//		max_queue_depth 10
+//	}
+//	frankenphp_health /healthz/live live
+type FrankenPHPHealth struct {
+	// Check is the probe to answer: "ready" or "live". Default: ready.
+	Check string `json:"check,omitempty"`
+	// MaxQueueDepth is the number of queued requests above which FrankenPHP is not ready. Default: 0 (unlimited).
+	MaxQueueDepth int `json:"max_queue_depth,omitempty"`
+	// MaxTransitionTime is the time after which a thread still booting or restarting is considered stuck. Default: 30s.
+	MaxTransitionTime caddy.Duration `json:"max_transition_time,omitempty"`
+}
+
+// CaddyModule returns the Caddy module information.
+func (FrankenPHPHealth) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.frankenphp_health",
+		New: func() caddy.Module { return new(FrankenPHPHealth) },
+	}
+}
+
+// Validate ensures the check is known.
+func (hc *FrankenPHPHealth) Validate() error {
+	switch hc.Check {
+	case "", healthCheckReady, healthCheckLive:
+		return nil
+	}
+
+	return fmt.Errorf(`the health check must be "ready" or "live", got %q`, hc.Check)
+}
+
+// check returns an error describing why FrankenPHP is not ready or not live
+func (hc *FrankenPHPHealth) check() error {
+	if hc.Check == healthCheckLive {
+		return frankenphp.CheckLiveness()
+	}
+
+	return frankenphp.CheckReadiness(frankenphp.ReadinessOptions{
+		MaxQueueDepth:     hc.MaxQueueDepth,
+		MaxTransitionTime: time.Duration(hc.MaxTransitionTime),
+	})
+}
+
+// ServeHTTP answers the probe without calling the next handlers.
+func (hc *FrankenPHPHealth) ServeHTTP(w http.ResponseWriter, _ *http.Request, _ caddyhttp.Handler) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := hc.check(); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, err = w.Write([]byte(err.Error() + "\n"))
+
+		return err
+	}
+
+	check := hc.Check
+	if check == "" {
+		check = healthCheckReady
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write([]byte(check + "\n"))
+
+	return err
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler.
+func (hc *FrankenPHPHealth) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		if d.NextArg() {
+			hc.Check = d.Val()
+			if err := hc.Validate(); err != nil {
+				return err
+			}
+		}
+
+		if d.NextArg() {
+			return d.ArgErr()
+		}
+
+		for d.NextBlock(0) {
+			switch d.Val() {
+			case "max_queue_depth":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+
+				v, err := strconv.ParseUint(d.Val(), 10, 32)
+				if err != nil {
+					return err
+				}
+
+				hc.MaxQueueDepth = int(v)
+			case "max_transition_time":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+
+				v, err := caddy.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("max_transition_time must be a valid duration (example: 30s)")
+				}
+
+				hc.MaxTransitionTime = caddy.Duration(v)
+			default:
+				return wrongSubDirectiveError("frankenphp_health", "max_queue_depth, max_transition_time", d.Val())
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseHealthCheck(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	hc := &FrankenPHPHealth{}
+	err := hc.UnmarshalCaddyfile(h.Dispenser)
+
+	return hc, err
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/caddyserver/caddy/v2"
 	"github.com/caddyserver/caddy/v2/caddyconfig"
@@ -39,6 +40,10 @@ type FrankenPHPModule struct {
 	Env map[string]string `json:"env,omitempty"`
 	// Workers configures the worker scripts to start.
 	Workers []workerConfig `json:"workers,omitempty"`
+	// Priorities assigns priorities to matching requests, higher priorities are served first when all threads are busy. The first matching priority is used.
+	Priorities []requestPriority `json:"priorities,omitempty"`
+	// RequestTimeout sets the maximum duration of a request, including the time spent in I/O. The script is interrupted with a catchable error once exceeded, and the client receives a 504 if it does not yield within the grace period. Default: 0 (uses the request_timeout global option).
+	RequestTimeout caddy.Duration `json:"request_timeout,omitempty"`
 
 	resolvedDocumentRoot        string
 	preparedEnv                 frankenphp.PreparedEnv
@@ -115,6 +120,12 @@ func (f *FrankenPHPModule) Provision(ctx caddy.Context) error {
 		}
 	}
 
+	for i := range f.Priorities {
+		if err := f.Priorities[i].provision(ctx); err != nil {
+			return fmt.Errorf("loading priority matchers: %w", err)
+		}
+	}
+
 	if f.preparedEnv == nil {
 		f.preparedEnv = frankenphp.PrepareEnv(f.Env)
 
@@ -171,6 +182,18 @@ func (f *FrankenPHPModule) ServeHTTP(w http.ResponseWriter, r *http.Request, _ c
 		}
 	}
 
+	priority := 0
+	for _, p := range f.Priorities {
+		match, err := p.matcherSets.AnyMatchWithError(r)
+		if err != nil {
+			return caddyhttp.Error(http.StatusInternalServerError, err)
+		}
+		if match {
+			priority = p.Priority
+			break
+		}
+	}
+
 	fr, err := frankenphp.NewRequestWithContext(
 		r,
 		documentRootOption,
@@ -178,6 +201,8 @@ func (f *FrankenPHPModule) ServeHTTP(w http.ResponseWriter, r *http.Request, _ c
 		frankenphp.WithRequestPreparedEnv(env),
 		frankenphp.WithOriginalRequest(&origReq),
 		frankenphp.WithWorkerName(workerName),
+		frankenphp.WithRequestPriority(priority),
+		frankenphp.WithRequestTimeout(time.Duration(f.RequestTimeout)),
 	)
 
 	if err = frankenphp.ServeHTTP(w, fr); err != nil {
@@ -230,6 +255,21 @@ func (f *FrankenPHPModule) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 				}
 				f.ResolveRootSymlink = &v
 
+			case "request_timeout":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+
+				v, err := caddy.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("request_timeout must be a valid duration (example: 30s)")
+				}
+				if d.NextArg() {
+					return d.ArgErr()
+				}
+
+				f.RequestTimeout = caddy.Duration(v)
+
 			case "worker":
 				for d.NextBlock(1) {
 				}
@@ -239,7 +279,7 @@ func (f *FrankenPHPModule) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 				continue
 
 			default:
-				allowedDirectives := "root, split, env, resolve_root_symlink, worker"
+				allowedDirectives := "root, split, env, resolve_root_symlink, request_timeout, worker"
 				return wrongSubDirectiveError("php or php_server", allowedDirectives, d.Val())
 			}
 		}
@@ -290,7 +330,14 @@ func (f *FrankenPHPModule) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 // parseCaddyfile unmarshals tokens from h into a new Middleware.
 func parseCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
 	m := &FrankenPHPModule{}
-	err := m.UnmarshalCaddyfile(h.Dispenser)
+
+	priorities, err := extractPriorities(h, h.Dispenser)
+	if err != nil {
+		return nil, err
+	}
+	m.Priorities = priorities
+
+	err = m.UnmarshalCaddyfile(h.Dispenser)
 
 	return m, err
 }
@@ -419,6 +466,12 @@ func parsePhpServer(h httpcaddyfile.Helper) ([]httpcaddyfile.ConfigValue, error)
 	// unmarshaler can read it from the start
 	dispenser.Reset()
 
+	// priorities need the named matchers of the site block
+	phpsrv.Priorities, err = extractPriorities(h, dispenser)
+	if err != nil {
+		return nil, err
+	}
+
 	if frankenphp.EmbeddedAppPath != "" {
 		if phpsrv.Root == "" {
 			phpsrv.Root = filepath.Join(frankenphp.EmbeddedAppPath, defaultDocumentRoot)
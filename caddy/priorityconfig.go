@@ -0,0 +1,105 @@
+package caddy
+
+import (
+	"strconv"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// requestPriority represents the "priority" subdirective of the "php_server" and "php" directives
+// requests matching the matcher get the given priority when they have to wait for a thread
+//
+//	@health path /healthz
+//	php_server {
+//		priority @health 10
+//	}
+type requestPriority struct {
+	// MatcherSetsRaw are the matchers the request must match. Default: all requests.
+	MatcherSetsRaw caddyhttp.RawMatcherSets `json:"match,omitempty" caddy:"namespace=http.matchers"`
+	// Priority of the matching requests, higher priorities are served first. Default: 0.
+	Priority int `json:"priority,omitempty"`
+
+	matcherSets caddyhttp.MatcherSets
+}
+
+// provision loads the matchers of the priority
+func (rp *requestPriority) provision(ctx caddy.Context) error {
+	if rp.MatcherSetsRaw == nil {
+		return nil
+	}
+
+	matchers, err := ctx.LoadModule(rp, "MatcherSetsRaw")
+	if err != nil {
+		return err
+	}
+
+	return rp.matcherSets.FromInterface(matchers)
+}
+
+// extractPriorities parses and removes the "priority" subdirectives from the dispenser
+// they are parsed separately since named matchers are only available through the helper
+func extractPriorities(h httpcaddyfile.Helper, d *caddyfile.Dispenser) ([]requestPriority, error) {
+	var priorities []requestPriority
+	for d.Next() {
+		for d.NextBlock(0) {
+			if d.Nesting() != 1 || d.Val() != "priority" {
+				continue
+			}
+
+			tokens := []caddyfile.Token{d.Token()}
+			for d.NextArg() {
+				tokens = append(tokens, d.Token())
+			}
+			d.DeleteN(len(tokens))
+
+			rp, err := parsePriority(h.WithDispenser(caddyfile.NewDispenser(tokens)))
+			if err != nil {
+				return nil, err
+			}
+
+			priorities = append(priorities, rp)
+		}
+	}
+
+	d.Reset()
+
+	return priorities, nil
+}
+
+// parsePriority parses a "priority [<matcher>] <priority>" line
+func parsePriority(h httpcaddyfile.Helper) (requestPriority, error) {
+	rp := requestPriority{}
+	h.Next() // consume the directive name
+
+	matcherSet, hasMatcher, err := h.MatcherToken()
+	if err != nil {
+		return rp, err
+	}
+
+	if hasMatcher {
+		if matcherSet != nil {
+			rp.MatcherSetsRaw = caddyhttp.RawMatcherSets{matcherSet}
+		}
+
+		if !h.NextArg() {
+			return rp, h.ArgErr()
+		}
+	} else if h.Val() == "priority" {
+		return rp, h.ArgErr()
+	}
+
+	v, err := strconv.Atoi(h.Val())
+	if err != nil {
+		return rp, h.Errf("priority must be an integer, got %q", h.Val())
+	}
+	rp.Priority = v
+
+	if h.NextArg() {
+		return rp, h.ArgErr()
+	}
+
+	return rp, nil
+}
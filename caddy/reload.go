@@ -0,0 +1,119 @@
+package caddy
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+
+	"github.com/dunglas/frankenphp"
+	"github.com/dunglas/frankenphp/internal/fastabs"
+)
+
+const (
+	// reloadModeFull restarts PHP and all workers on every config reload
+	reloadModeFull = "full"
+	// reloadModeWorkers only adds, replaces and removes the workers that changed on config reloads
+	reloadModeWorkers = "workers"
+)
+
+var (
+	errWatchRequiresRestart = errors.New("workers watching files can only be changed by restarting PHP")
+	// metrics are bound to the registry of each config, they can only be swapped by restarting PHP
+	errReloadModeWorkersWithMetrics = errors.New(`"reload_mode workers" cannot be used while metrics are enabled`)
+)
+
+// runningConfig is the configuration FrankenPHP was last started with
+type runningConfig struct {
+	// global holds all options except the workers, PHP must be restarted if they change
+	global string
+	// workers by name, with placeholders replaced
+	workers map[string]workerConfig
+}
+
+// lastConfig is shared between the app instances of consecutive config loads
+var lastConfig *runningConfig
+
+func newRunningConfig(f *FrankenPHPApp, workers []workerConfig) (*runningConfig, error) {
+	global := *f
+	global.Workers = nil
+
+	b, err := json.Marshal(global)
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &runningConfig{global: string(b), workers: make(map[string]workerConfig, len(workers))}
+	for _, w := range workers {
+		rc.workers[runtimeWorkerName(w)] = w
+	}
+
+	return rc, nil
+}
+
+// runtimeWorkerName returns the name FrankenPHP uses for the worker
+func runtimeWorkerName(w workerConfig) string {
+	if w.Name != "" {
+		return w.Name
+	}
+
+	name, _ := fastabs.FastAbs(w.FileName)
+
+	return name
+}
+
+// canReloadWorkers returns true if only the workers differ between the running configuration and rc
+func (rc *runningConfig) canReloadWorkers(running *runningConfig) bool {
+	return running != nil && running.global == rc.global
+}
+
+// reloadWorkers removes the workers that are gone, replaces the changed ones and adds the new ones
+// the other workers keep running untouched
+func (rc *runningConfig) reloadWorkers(running *runningConfig) error {
+	var removed, replaced, added []workerConfig
+	for name, w := range running.workers {
+		if _, ok := rc.workers[name]; !ok {
+			removed = append(removed, w)
+		}
+	}
+	for name, w := range rc.workers {
+		ow, ok := running.workers[name]
+		switch {
+		case !ok:
+			added = append(added, w)
+		case !reflect.DeepEqual(w, ow):
+			if len(ow.Watch) > 0 {
+				return errWatchRequiresRestart
+			}
+			replaced = append(replaced, w)
+		}
+	}
+
+	for _, w := range slices.Concat(removed, replaced, added) {
+		if len(w.Watch) > 0 {
+			return errWatchRequiresRestart
+		}
+	}
+
+	// removing workers first frees threads for the others
+	for _, w := range removed {
+		if err := frankenphp.RemoveWorker(runtimeWorkerName(w)); err != nil {
+			return err
+		}
+	}
+
+	// the new version of a changed worker boots before the old one is stopped, requests are never dropped
+	for _, w := range replaced {
+		if err := frankenphp.ReplaceWorker(w.Name, w.FileName, w.Num, w.Env, w.options()...); err != nil {
+			return err
+		}
+	}
+
+	for _, w := range added {
+		if err := frankenphp.AddWorker(w.Name, w.FileName, w.Num, w.Env, w.options()...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
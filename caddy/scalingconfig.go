@@ -0,0 +1,137 @@
+package caddy
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/dunglas/frankenphp"
+)
+
+// scalingConfig represents the "scaling" directive in the Caddyfile
+// it tunes the default CPU based scaling policy
+//
+//	frankenphp {
+//		max_threads auto
+//		scaling {
+//			min_stall_time 1ms
+//			upscale_count 4
+//		}
+//	}
+type scalingConfig struct {
+	// MinStallTime is the time a request has to be stalled before adding threads. Default: 5ms.
+	MinStallTime time.Duration `json:"min_stall_time,omitempty"`
+	// CPUProbeTime is the time during which CPU usage is probed before adding threads. Default: 120ms.
+	CPUProbeTime time.Duration `json:"cpu_probe_time,omitempty"`
+	// MaxCPUUsage is the CPU usage (between 0 and 1) above which no threads are added. Default: 0.8.
+	MaxCPUUsage float64 `json:"max_cpu_usage,omitempty"`
+	// UpscaleCount is the number of threads added at once. Default: 1.
+	UpscaleCount int `json:"upscale_count,omitempty"`
+	// DownscaleInterval is how often idle threads are checked. Default: 5s.
+	DownscaleInterval time.Duration `json:"downscale_interval,omitempty"`
+	// MaxIdleTime is the time after which an idle autoscaled thread is removed. Default: 5s.
+	MaxIdleTime time.Duration `json:"max_idle_time,omitempty"`
+	// MaxDownscaleCount is the maximum number of threads removed per check. Default: 10.
+	MaxDownscaleCount int `json:"max_downscale_count,omitempty"`
+}
+
+func parseScalingConfig(d *caddyfile.Dispenser) (*scalingConfig, error) {
+	sc := &scalingConfig{}
+
+	parseDuration := func(name string) (time.Duration, error) {
+		if !d.NextArg() {
+			return 0, d.ArgErr()
+		}
+
+		v, err := time.ParseDuration(d.Val())
+		if err != nil {
+			return 0, errors.New(name + " must be a valid duration (example: 10ms)")
+		}
+
+		return v, nil
+	}
+
+	parseCount := func() (int, error) {
+		if !d.NextArg() {
+			return 0, d.ArgErr()
+		}
+
+		v, err := strconv.ParseUint(d.Val(), 10, 32)
+		if err != nil {
+			return 0, err
+		}
+
+		return int(v), nil
+	}
+
+	for d.NextBlock(1) {
+		var err error
+		switch d.Val() {
+		case "min_stall_time":
+			sc.MinStallTime, err = parseDuration("min_stall_time")
+		case "cpu_probe_time":
+			sc.CPUProbeTime, err = parseDuration("cpu_probe_time")
+		case "max_cpu_usage":
+			if !d.NextArg() {
+				return nil, d.ArgErr()
+			}
+
+			v, parseErr := strconv.ParseFloat(d.Val(), 64)
+			if parseErr != nil || v <= 0 || v > 1 {
+				return nil, errors.New("max_cpu_usage must be a number between 0 and 1 (example: 0.8)")
+			}
+
+			sc.MaxCPUUsage = v
+		case "upscale_count":
+			sc.UpscaleCount, err = parseCount()
+		case "downscale_interval":
+			sc.DownscaleInterval, err = parseDuration("downscale_interval")
+		case "max_idle_time":
+			sc.MaxIdleTime, err = parseDuration("max_idle_time")
+		case "max_downscale_count":
+			sc.MaxDownscaleCount, err = parseCount()
+		default:
+			allowedDirectives := "min_stall_time, cpu_probe_time, max_cpu_usage, upscale_count, downscale_interval, max_idle_time, max_downscale_count"
+			return nil, wrongSubDirectiveError("scaling", allowedDirectives, d.Val())
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sc, nil
+}
+
+// policy returns the default scaling policy with the configured values applied
+func (sc *scalingConfig) policy() frankenphp.ScalingPolicy {
+	p := frankenphp.NewCPUScalingPolicy()
+	if sc == nil {
+		return p
+	}
+
+	if sc.MinStallTime > 0 {
+		p.MinStallTime = sc.MinStallTime
+	}
+	if sc.CPUProbeTime > 0 {
+		p.CPUProbeTime = sc.CPUProbeTime
+	}
+	if sc.MaxCPUUsage > 0 {
+		p.MaxCPUUsage = sc.MaxCPUUsage
+	}
+	if sc.UpscaleCount > 0 {
+		p.UpscaleCount = sc.UpscaleCount
+	}
+	if sc.DownscaleInterval > 0 {
+		p.DownscaleCheckTime = sc.DownscaleInterval
+	}
+	if sc.MaxIdleTime > 0 {
+		p.MaxIdleTime = sc.MaxIdleTime
+	}
+	if sc.MaxDownscaleCount > 0 {
+		p.MaxDownscaleCount = sc.MaxDownscaleCount
+	}
+
+	return p
+}
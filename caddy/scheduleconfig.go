@@ -0,0 +1,107 @@
+package caddy
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/dunglas/frankenphp"
+	"github.com/dunglas/frankenphp/internal/cron"
+)
+
+// scheduleConfig represents the "schedule" directive in the Caddyfile
+// it runs a PHP script periodically on a spare thread
+//
+//	frankenphp {
+//		schedule cleanup {
+//			cron "*/5 * * * *"
+//			script bin/cleanup.php
+//			args --verbose
+//			env APP_ENV prod
+//		}
+//	}
+type scheduleConfig struct {
+	// Name of the job, used in logs and metrics
+	Name string `json:"name"`
+	// Cron is the schedule of the job, e.g. "*/5 * * * *" or "@hourly"
+	Cron string `json:"cron"`
+	// Script is the path of the PHP script to run
+	Script string `json:"script"`
+	// Args are exposed to the script as $argv
+	Args []string `json:"args,omitempty"`
+	// Env sets extra environment variables for the script
+	Env map[string]string `json:"env,omitempty"`
+}
+
+func parseScheduleConfig(d *caddyfile.Dispenser) (scheduleConfig, error) {
+	sc := scheduleConfig{}
+
+	if !d.NextArg() {
+		return sc, errors.New(`"schedule" requires a name (example: schedule cleanup { ... })`)
+	}
+	sc.Name = d.Val()
+
+	if d.NextArg() {
+		return sc, d.ArgErr()
+	}
+
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "cron":
+			if !d.NextArg() {
+				return sc, d.ArgErr()
+			}
+
+			if _, err := cron.Parse(d.Val()); err != nil {
+				return sc, err
+			}
+
+			sc.Cron = d.Val()
+		case "script":
+			if !d.NextArg() {
+				return sc, d.ArgErr()
+			}
+
+			sc.Script = d.Val()
+		case "args":
+			sc.Args = append(sc.Args, d.RemainingArgs()...)
+		case "env":
+			args := d.RemainingArgs()
+			if len(args) != 2 {
+				return sc, d.ArgErr()
+			}
+			if sc.Env == nil {
+				sc.Env = make(map[string]string)
+			}
+			sc.Env[args[0]] = args[1]
+		default:
+			allowedDirectives := "cron, script, args, env"
+			return sc, wrongSubDirectiveError("schedule", allowedDirectives, d.Val())
+		}
+	}
+
+	if sc.Cron == "" {
+		return sc, fmt.Errorf("schedule %q: the cron expression is required", sc.Name)
+	}
+	if sc.Script == "" {
+		return sc, fmt.Errorf("schedule %q: the script is required", sc.Name)
+	}
+
+	if frankenphp.EmbeddedAppPath != "" && filepath.IsLocal(sc.Script) {
+		sc.Script = filepath.Join(frankenphp.EmbeddedAppPath, sc.Script)
+	}
+
+	return sc, nil
+}
+
+// option returns the scheduled job as a FrankenPHP option
+func (sc scheduleConfig) option() frankenphp.Option {
+	return frankenphp.WithScheduledJob(frankenphp.ScheduledJob{
+		Name:     sc.Name,
+		Schedule: sc.Cron,
+		Script:   sc.Script,
+		Args:     sc.Args,
+		Env:      sc.Env,
+	})
+}
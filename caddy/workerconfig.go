@@ -2,11 +2,15 @@ package caddy
 
 import (
 	"errors"
+	"net/http"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
 	"github.com/dunglas/frankenphp"
+	"github.com/dustin/go-humanize"
 )
 
 // workerConfig represents the "worker" directive in the Caddyfile
@@ -29,6 +33,47 @@ type workerConfig struct {
 	Env map[string]string `json:"env,omitempty"`
 	// Directories to watch for file changes
 	Watch []string `json:"watch,omitempty"`
+	// MaxRequests sets the number of requests a worker thread handles before restarting. Default: 0 (unlimited).
+	MaxRequests int `json:"max_requests,omitempty"`
+	// MaxMemory sets the memory usage (in bytes) above which a worker thread is restarted after a request. Default: 0 (unlimited).
+	MaxMemory int64 `json:"max_memory,omitempty"`
+	// MinThreads sets the minimum number of threads guaranteed to this worker, idle threads above it can be downscaled. Default: num.
+	MinThreads int `json:"min_threads,omitempty"`
+	// MaxThreads limits the number of threads this worker can scale up to. Default: 0 (only limited by the global max_threads).
+	MaxThreads int `json:"max_threads,omitempty"`
+	// MaxQueueLength limits the number of requests waiting for a thread, further requests are rejected with a 503. Default: 0 (unlimited).
+	MaxQueueLength int `json:"max_queue_length,omitempty"`
+	// Warmup lists the requests replayed on each thread after the worker script has booted and before it receives traffic.
+	Warmup []warmupConfig `json:"warmup,omitempty"`
+	// CrashLoopPolicy is applied once the worker script failed to boot MaxConsecutiveFailures times in a row: panic, disable or retry-forever. Default: panic.
+	CrashLoopPolicy string `json:"crash_loop_policy,omitempty"`
+	// UnavailablePage is the file sent with the 503 responses while the worker is disabled.
+	UnavailablePage string `json:"unavailable_page,omitempty"`
+	// MinBackoff is the initial delay before booting the worker script again after a failure. Default: 100ms.
+	MinBackoff time.Duration `json:"min_backoff,omitempty"`
+	// MaxBackoff is the maximum delay before booting the worker script again after a failure. Default: 1s.
+	MaxBackoff time.Duration `json:"max_backoff,omitempty"`
+	// MaxConsecutiveFailures is the number of failed boots in a row after which the crash loop policy applies. Default: 6.
+	MaxConsecutiveFailures int `json:"max_consecutive_failures,omitempty"`
+	// WebSocket upgrades the requests sent to the worker and passes the events of the connections to frankenphp_handle_websocket().
+	WebSocket bool `json:"websocket,omitempty"`
+	// Tasks passes the tasks sent to the worker to frankenphp_handle_task(), HTTP requests are never routed to it.
+	Tasks bool `json:"tasks,omitempty"`
+}
+
+// warmupConfig represents the "warmup" subdirective of a worker
+//
+//	warmup /products {
+//		method POST
+//		header Accept application/json
+//	}
+type warmupConfig struct {
+	// Path of the request, including the query string
+	Path string `json:"path"`
+	// Method of the request. Default: GET.
+	Method string `json:"method,omitempty"`
+	// Header of the request
+	Header http.Header `json:"header,omitempty"`
 }
 
 func parseWorkerConfig(d *caddyfile.Dispenser) (workerConfig, error) {
@@ -94,8 +139,124 @@ func parseWorkerConfig(d *caddyfile.Dispenser) (workerConfig, error) {
 			} else {
 				wc.Watch = append(wc.Watch, d.Val())
 			}
+		case "max_requests":
+			if !d.NextArg() {
+				return wc, d.ArgErr()
+			}
+
+			v, err := strconv.ParseUint(d.Val(), 10, 32)
+			if err != nil {
+				return wc, err
+			}
+
+			wc.MaxRequests = int(v)
+		case "max_memory":
+			if !d.NextArg() {
+				return wc, d.ArgErr()
+			}
+
+			v, err := humanize.ParseBytes(d.Val())
+			if err != nil {
+				return wc, errors.New("max_memory must be a valid size (example: 128MB)")
+			}
+
+			wc.MaxMemory = int64(v)
+		case "min_threads":
+			if !d.NextArg() {
+				return wc, d.ArgErr()
+			}
+
+			v, err := strconv.ParseUint(d.Val(), 10, 32)
+			if err != nil {
+				return wc, err
+			}
+
+			wc.MinThreads = int(v)
+		case "max_threads":
+			if !d.NextArg() {
+				return wc, d.ArgErr()
+			}
+
+			v, err := strconv.ParseUint(d.Val(), 10, 32)
+			if err != nil {
+				return wc, err
+			}
+
+			wc.MaxThreads = int(v)
+		case "max_queue_length":
+			if !d.NextArg() {
+				return wc, d.ArgErr()
+			}
+
+			v, err := strconv.ParseUint(d.Val(), 10, 32)
+			if err != nil {
+				return wc, err
+			}
+
+			wc.MaxQueueLength = int(v)
+		case "warmup":
+			wu, err := parseWarmupConfig(d)
+			if err != nil {
+				return wc, err
+			}
+
+			wc.Warmup = append(wc.Warmup, wu)
+		case "crash_loop_policy":
+			if !d.NextArg() {
+				return wc, d.ArgErr()
+			}
+
+			switch policy := frankenphp.CrashLoopPolicy(d.Val()); policy {
+			case frankenphp.CrashLoopPolicyPanic, frankenphp.CrashLoopPolicyDisable, frankenphp.CrashLoopPolicyRetryForever:
+				wc.CrashLoopPolicy = string(policy)
+			default:
+				return wc, errors.New(`crash_loop_policy must be "panic", "disable" or "retry-forever"`)
+			}
+		case "unavailable_page":
+			if !d.NextArg() {
+				return wc, d.ArgErr()
+			}
+			wc.UnavailablePage = d.Val()
+		case "min_backoff", "max_backoff":
+			if !d.NextArg() {
+				return wc, d.ArgErr()
+			}
+
+			backoff, err := time.ParseDuration(d.Val())
+			if err != nil || backoff <= 0 {
+				return wc, errors.New(v + " must be a valid duration (example: 500ms)")
+			}
+
+			if v == "min_backoff" {
+				wc.MinBackoff = backoff
+			} else {
+				wc.MaxBackoff = backoff
+			}
+		case "max_consecutive_failures":
+			if !d.NextArg() {
+				return wc, d.ArgErr()
+			}
+
+			v, err := strconv.ParseUint(d.Val(), 10, 32)
+			if err != nil {
+				return wc, err
+			}
+
+			wc.MaxConsecutiveFailures = int(v)
+		case "websocket":
+			if d.NextArg() {
+				return wc, d.ArgErr()
+			}
+
+			wc.WebSocket = true
+		case "tasks":
+			if d.NextArg() {
+				return wc, d.ArgErr()
+			}
+
+			wc.Tasks = true
 		default:
-			allowedDirectives := "name, file, num, env, watch"
+			allowedDirectives := "name, file, num, env, watch, max_requests, max_memory, min_threads, max_threads, max_queue_length, warmup, crash_loop_policy, unavailable_page, min_backoff, max_backoff, max_consecutive_failures, websocket, tasks"
 			return wc, wrongSubDirectiveError("worker", allowedDirectives, v)
 		}
 	}
@@ -104,9 +265,78 @@ func parseWorkerConfig(d *caddyfile.Dispenser) (workerConfig, error) {
 		return wc, errors.New(`the "file" argument must be specified`)
 	}
 
+	if wc.MaxThreads > 0 && wc.MinThreads > wc.MaxThreads {
+		return wc, errors.New(`"min_threads" must be less than or equal to "max_threads"`)
+	}
+
+	if wc.MaxBackoff > 0 && wc.MinBackoff > wc.MaxBackoff {
+		return wc, errors.New(`"min_backoff" must be less than or equal to "max_backoff"`)
+	}
+
 	if frankenphp.EmbeddedAppPath != "" && filepath.IsLocal(wc.FileName) {
 		wc.FileName = filepath.Join(frankenphp.EmbeddedAppPath, wc.FileName)
 	}
 
 	return wc, nil
 }
+
+func parseWarmupConfig(d *caddyfile.Dispenser) (warmupConfig, error) {
+	wu := warmupConfig{}
+	if !d.NextArg() {
+		return wu, d.ArgErr()
+	}
+	wu.Path = d.Val()
+
+	if !strings.HasPrefix(wu.Path, "/") {
+		return wu, errors.New(`warmup paths must start with a slash (example: /products)`)
+	}
+
+	if d.NextArg() {
+		return wu, d.ArgErr()
+	}
+
+	for d.NextBlock(2) {
+		switch d.Val() {
+		case "method":
+			if !d.NextArg() {
+				return wu, d.ArgErr()
+			}
+			wu.Method = strings.ToUpper(d.Val())
+		case "header":
+			args := d.RemainingArgs()
+			if len(args) != 2 {
+				return wu, d.ArgErr()
+			}
+			if wu.Header == nil {
+				wu.Header = make(http.Header)
+			}
+			wu.Header.Add(args[0], args[1])
+		default:
+			return wu, wrongSubDirectiveError("warmup", "method, header", d.Val())
+		}
+	}
+
+	return wu, nil
+}
+
+// options returns the worker options passed to FrankenPHP
+func (wc workerConfig) options() []frankenphp.WorkerOption {
+	warmup := make([]frankenphp.WarmupRequest, 0, len(wc.Warmup))
+	for _, wu := range wc.Warmup {
+		warmup = append(warmup, frankenphp.WarmupRequest{Path: wu.Path, Method: wu.Method, Header: wu.Header})
+	}
+
+	return []frankenphp.WorkerOption{
+		frankenphp.WithWorkerMaxRequests(wc.MaxRequests),
+		frankenphp.WithWorkerMaxMemory(wc.MaxMemory),
+		frankenphp.WithWorkerMinThreads(wc.MinThreads),
+		frankenphp.WithWorkerMaxThreads(wc.MaxThreads),
+		frankenphp.WithWorkerMaxQueueLength(wc.MaxQueueLength),
+		frankenphp.WithWorkerWarmup(warmup...),
+		frankenphp.WithWorkerCrashLoopPolicy(frankenphp.CrashLoopPolicy(wc.CrashLoopPolicy)),
+		frankenphp.WithWorkerBackoff(wc.MinBackoff, wc.MaxBackoff, wc.MaxConsecutiveFailures),
+		frankenphp.WithWorkerUnavailablePage(wc.UnavailablePage),
+		frankenphp.WithWorkerWebSocket(wc.WebSocket),
+		frankenphp.WithWorkerTasks(wc.Tasks),
+	}
+}
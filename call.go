@@ -0,0 +1,80 @@
+package frankenphp
+
+// #include "frankenphp.h"
+import "C"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// ErrCallFailed is returned when the worker script crashed or threw an exception while handling a call
+var ErrCallFailed = errors.New("PHP callback failed")
+
+// Call invokes the callback of the worker script with the given name in-process, without building an HTTP request.
+// The worker must have been started with WithWorkerTasks and its script must loop on frankenphp_handle_task(),
+// its callback receives the payload as a string and its return value is converted to a string and returned.
+//
+// Calls share the queue and the crash handling of the HTTP requests sent to the worker.
+// If ctx has a deadline, it is enforced as a request timeout: the script is interrupted once it is exceeded,
+// including the time spent waiting for a thread. Call returns as soon as ctx is done: the call is removed
+// from the queue if it is still waiting for a thread, otherwise the result of the callback is discarded.
+func Call(ctx context.Context, workerName string, payload []byte) ([]byte, error) {
+	if !isRunning {
+		return nil, ErrNotRunning
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	worker, err := getTaskWorker(workerName)
+	if err != nil {
+		return nil, err
+	}
+
+	fc, err := newTaskContext(worker, appendPHPString(nil, string(payload)))
+	if err != nil {
+		return nil, err
+	}
+
+	// request hooks receive the context of the caller, e.g. to carry a tracing span
+	fc.request = fc.request.WithContext(ctx)
+	fc.task.cancelled = ctx.Done()
+	if deadline, ok := ctx.Deadline(); ok {
+		fc.timeout = newRequestDeadline(deadline)
+	}
+
+	go worker.handleRequest(fc)
+
+	select {
+	case <-fc.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	switch {
+	case fc.task.rejected:
+		return nil, fmt.Errorf("%w by worker %q", ErrTaskRejected, workerName)
+	case !fc.task.handled:
+		return nil, fmt.Errorf("%w: worker %q", ErrCallFailed, workerName)
+	}
+
+	return fc.task.result, nil
+}
+
+// go_frankenphp_task_result stores the return value of the callback passed to frankenphp_handle_task()
+//
+//export go_frankenphp_task_result
+func go_frankenphp_task_result(threadIndex C.uintptr_t, result *C.char, resultLen C.size_t) {
+	fc := phpThreads[threadIndex].getRequestContext()
+	if fc == nil || fc.task == nil {
+		return
+	}
+
+	fc.task.handled = true
+	if result != nil {
+		fc.task.result = C.GoBytes(unsafe.Pointer(result), C.int(resultLen))
+	}
+}
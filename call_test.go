@@ -0,0 +1,85 @@
+package frankenphp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dunglas/frankenphp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCall(t *testing.T) {
+	runTest(t, func(_ func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		result, err := frankenphp.Call(context.Background(), "workerName", []byte("pricing rules"))
+
+		require.NoError(t, err)
+		assert.Equal(t, "PRICING RULES", string(result))
+	}, &testOptions{workerScript: "call-worker.php", nbWorkers: 2, workerOpts: taskWorkerOpts})
+}
+
+func TestCallCrash(t *testing.T) {
+	runTest(t, func(_ func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		_, err := frankenphp.Call(context.Background(), "workerName", []byte("crash"))
+		assert.ErrorIs(t, err, frankenphp.ErrCallFailed)
+
+		// the worker script is restarted
+		result, err := frankenphp.Call(context.Background(), "workerName", []byte("ok"))
+		require.NoError(t, err)
+		assert.Equal(t, "OK", string(result))
+	}, &testOptions{workerScript: "call-worker.php", nbWorkers: 1, nbParallelRequests: 1, workerOpts: taskWorkerOpts})
+}
+
+func TestCallTimeout(t *testing.T) {
+	runTest(t, func(_ func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := frankenphp.Call(ctx, "workerName", []byte("loop"))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// the script has been interrupted, the worker handles the next call
+		result, err := frankenphp.Call(context.Background(), "workerName", []byte("ok"))
+		require.NoError(t, err)
+		assert.Equal(t, "OK", string(result))
+	}, &testOptions{workerScript: "call-worker.php", nbWorkers: 1, nbParallelRequests: 1, workerOpts: taskWorkerOpts})
+}
+
+func TestCancelledCallIsRemovedFromTheQueue(t *testing.T) {
+	runTest(t, func(_ func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		busy := make(chan error)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			_, err := frankenphp.Call(ctx, "workerName", []byte("loop"))
+			busy <- err
+		}()
+
+		// the only thread is busy, the call is queued until its context is done
+		time.Sleep(100 * time.Millisecond)
+		file := filepath.Join(t.TempDir(), "called")
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := frankenphp.Call(ctx, "workerName", []byte("touch:"+file))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, <-busy, context.DeadlineExceeded)
+
+		result, err := frankenphp.Call(context.Background(), "workerName", []byte("ok"))
+		require.NoError(t, err)
+		assert.Equal(t, "OK", string(result))
+		assert.NoFileExists(t, file, "the cancelled call must not run")
+	}, &testOptions{workerScript: "call-worker.php", nbWorkers: 1, nbParallelRequests: 1, workerOpts: taskWorkerOpts})
+}
+
+func TestCallUnknownWorker(t *testing.T) {
+	runTest(t, func(_ func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		_, err := frankenphp.Call(context.Background(), "unknown", nil)
+		assert.ErrorIs(t, err, frankenphp.ErrWorkerNotFound)
+	}, &testOptions{nbParallelRequests: 1})
+}
@@ -23,6 +23,7 @@ type frankenPHPContext struct {
 	scriptName     string
 	scriptFilename string
 	workerName     string
+	priority       int
 
 	// Whether the request is already closed by us
 	isDone bool
@@ -31,6 +32,25 @@ type frankenPHPContext struct {
 
 	done      chan interface{}
 	startedAt time.Time
+
+	// context returned by the OnRequestStart hooks, nil if the hooks have not been started
+	hooksContext context.Context
+	// stops interrupting the script when the client disconnects
+	stopWatchingDisconnect func() bool
+	// nil if the request has no timeout
+	timeout *requestTimeout
+	// true if the default request timeout must not apply to the request
+	timeoutDisabled bool
+	// nil if this is an HTTP request
+	task *workerTask
+	// nil unless this is an event of a WebSocket connection
+	webSocketEvent *webSocketEvent
+	// nil until the script sends a Server-Sent Event with frankenphp_sse_send()
+	eventStream *eventStream
+	// nil unless the script called frankenphp_sendfile(), the file is then sent once PHP has handled the request
+	sendfile *sendfile
+	// arguments exposed to the script as $argv, only set for scheduled jobs
+	argv []string
 }
 
 // fromContext extracts the frankenPHPContext from a context.
@@ -118,6 +138,9 @@ func (fc *frankenPHPContext) closeContext() {
 		return
 	}
 
+	fc.stopWatchingClientDisconnect()
+	fc.stopTimeout()
+	fc.stopEventStream()
 	close(fc.done)
 	fc.isDone = true
 }
@@ -148,6 +171,11 @@ func (fc *frankenPHPContext) reject(statusCode int, message string) {
 		return
 	}
 
+	if fc.task != nil {
+		fc.task.rejected = true
+		fc.logger.LogAttrs(context.Background(), slog.LevelWarn, "task rejected", slog.String("worker", fc.workerName), slog.Int("status", statusCode))
+	}
+
 	rw := fc.responseWriter
 	if rw != nil {
 		rw.WriteHeader(statusCode)
@@ -161,6 +189,18 @@ func (fc *frankenPHPContext) reject(statusCode int, message string) {
 	fc.closeContext()
 }
 
+// Retry-After header (in seconds) sent when a request is rejected because the queue is full
+const queueFullRetryAfter = "1"
+
+// rejectQueueFull sheds the request when too many requests are already waiting for a thread
+func (fc *frankenPHPContext) rejectQueueFull() {
+	if fc.responseWriter != nil && !fc.isDone {
+		fc.responseWriter.Header().Set("Retry-After", queueFullRetryAfter)
+	}
+
+	fc.reject(http.StatusServiceUnavailable, "Service Unavailable")
+}
+
 func (fc *frankenPHPContext) rejectBadRequest(message string) {
 	fc.reject(http.StatusBadRequest, message)
 }
@@ -0,0 +1,108 @@
+package frankenphp
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// CrashLoopPolicy defines what happens once a worker script failed to reach frankenphp_handle_request() too many times in a row
+type CrashLoopPolicy string
+
+const (
+	// CrashLoopPolicyPanic stops the process if the worker fails on startup, this is the default
+	CrashLoopPolicyPanic CrashLoopPolicy = "panic"
+	// CrashLoopPolicyDisable marks the worker as unhealthy and rejects its requests with a 503 status code
+	// while its threads keep trying to boot in the background, the worker is enabled again once a thread has booted
+	CrashLoopPolicyDisable CrashLoopPolicy = "disable"
+	// CrashLoopPolicyRetryForever marks the worker as unhealthy and keeps its requests waiting while its threads keep trying to boot
+	// requests wait up to max_wait_time, with no limit if it is not set
+	CrashLoopPolicyRetryForever CrashLoopPolicy = "retry-forever"
+)
+
+const (
+	defaultMinBackoff             = 100 * time.Millisecond
+	defaultMaxBackoff             = 1 * time.Second
+	defaultMaxConsecutiveFailures = 6
+)
+
+// newBackoff returns the backoff applied between two failed boots of the worker script
+func (worker *worker) newBackoff() *exponentialBackoff {
+	return &exponentialBackoff{
+		minBackoff:             worker.minBackoff,
+		maxBackoff:             worker.maxBackoff,
+		maxConsecutiveFailures: worker.maxConsecutiveFailures,
+	}
+}
+
+// markUnhealthy flags the worker after too many consecutive failures, it returns false if the worker was already unhealthy
+func (worker *worker) markUnhealthy() bool {
+	worker.healthMu.Lock()
+	defer worker.healthMu.Unlock()
+
+	if worker.unhealthy {
+		return false
+	}
+
+	worker.unhealthy = true
+	close(worker.disabled)
+
+	return true
+}
+
+// markHealthy enables the worker again once one of its threads has reached frankenphp_handle_request()
+func (worker *worker) markHealthy() {
+	worker.healthMu.Lock()
+	defer worker.healthMu.Unlock()
+
+	if !worker.unhealthy {
+		return
+	}
+
+	worker.unhealthy = false
+	worker.disabled = make(chan struct{})
+
+	logger.LogAttrs(context.Background(), slog.LevelInfo, "worker recovered", slog.String("worker", worker.name))
+}
+
+// isUnhealthy returns true while the worker fails to boot
+func (worker *worker) isUnhealthy() bool {
+	worker.healthMu.RLock()
+	defer worker.healthMu.RUnlock()
+
+	return worker.unhealthy
+}
+
+// disabledChan returns a channel closed once the worker is disabled
+// or nil if the crash loop policy never disables the worker
+func (worker *worker) disabledChan() chan struct{} {
+	if worker.crashLoopPolicy != CrashLoopPolicyDisable {
+		return nil
+	}
+
+	worker.healthMu.RLock()
+	defer worker.healthMu.RUnlock()
+
+	return worker.disabled
+}
+
+// isDisabled returns true if the requests of the worker must be rejected
+func (worker *worker) isDisabled() bool {
+	return worker.crashLoopPolicy == CrashLoopPolicyDisable && worker.isUnhealthy()
+}
+
+// rejectUnavailable answers a request of a disabled worker with its 503 page
+func (worker *worker) rejectUnavailable(fc *frankenPHPContext) {
+	if fc.responseWriter != nil && !fc.isDone {
+		fc.responseWriter.Header().Set("Retry-After", queueFullRetryAfter)
+		if worker.unavailablePage != nil {
+			fc.responseWriter.Header().Set("Content-Type", worker.unavailablePageType)
+			fc.reject(http.StatusServiceUnavailable, string(worker.unavailablePage))
+
+			return
+		}
+	}
+
+	fc.reject(http.StatusServiceUnavailable, "Service Unavailable")
+}
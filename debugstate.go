@@ -4,6 +4,7 @@ package frankenphp
 type ThreadDebugState struct {
 	Index                    int
 	Name                     string
+	WorkerName               string
 	State                    string
 	IsWaiting                bool
 	IsBusy                   bool
@@ -38,9 +39,19 @@ func threadDebugState(thread *phpThread) ThreadDebugState {
 	return ThreadDebugState{
 		Index:                    thread.threadIndex,
 		Name:                     thread.name(),
+		WorkerName:               threadWorkerName(thread),
 		State:                    thread.state.name(),
 		IsWaiting:                thread.state.isInWaitingState(),
 		IsBusy:                   !thread.state.isInWaitingState(),
 		WaitingSinceMilliseconds: thread.state.waitTime(),
 	}
 }
+
+// threadWorkerName returns the name of the worker a thread is assigned to or an empty string
+func threadWorkerName(thread *phpThread) string {
+	if worker := getThreadWorker(thread); worker != nil {
+		return worker.name
+	}
+
+	return ""
+}
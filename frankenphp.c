@@ -2,10 +2,12 @@
 #include <Zend/zend_alloc.h>
 #include <Zend/zend_exceptions.h>
 #include <Zend/zend_interfaces.h>
+#include <Zend/zend_smart_str.h>
 #include <Zend/zend_types.h>
 #include <errno.h>
 #include <ext/spl/spl_exceptions.h>
 #include <ext/standard/head.h>
+#include <ext/standard/php_var.h>
 #include <inttypes.h>
 #include <php.h>
 #include <php_config.h>
@@ -66,6 +68,13 @@ frankenphp_config frankenphp_get_config() {
 bool should_filter_var = 0;
 __thread uintptr_t thread_index;
 __thread bool is_worker_thread = false;
+__thread bool is_php_thread = false;
+/* true once the worker request has been aborted because the client
+ * disconnected, only this request is stopped, not the worker script */
+__thread bool is_worker_request_aborted = false;
+bool abort_on_disconnect = false;
+
+static void (*original_interrupt_function)(zend_execute_data *) = NULL;
 __thread zval *os_environment = NULL;
 
 static void frankenphp_free_request_context() {
@@ -384,22 +393,9 @@ PHP_FUNCTION(frankenphp_response_headers) /* {{{ */
 }
 /* }}} */
 
-PHP_FUNCTION(frankenphp_handle_request) {
-  zend_fcall_info fci;
-  zend_fcall_info_cache fcc;
-
-  ZEND_PARSE_PARAMETERS_START(1, 1)
-  Z_PARAM_FUNC(fci, fcc)
-  ZEND_PARSE_PARAMETERS_END();
-
-  if (!is_worker_thread) {
-    /* not a worker, throw an error */
-    zend_throw_exception(
-        spl_ce_RuntimeException,
-        "frankenphp_handle_request() called while not in worker mode", 0);
-    RETURN_THROWS();
-  }
-
+/* Blocks until the worker thread receives a request or a task, returns false
+ * if the worker script must stop */
+static bool frankenphp_worker_wait_for_request(void) {
 #ifdef ZEND_MAX_EXECUTION_TIMERS
   /* Disable timeouts while waiting for a request to handle */
   zend_unset_timeout();
@@ -409,7 +405,7 @@ PHP_FUNCTION(frankenphp_handle_request) {
   if (frankenphp_worker_request_startup() == FAILURE
       /* Shutting down */
       || !has_request) {
-    RETURN_FALSE;
+    return false;
   }
 
 #ifdef ZEND_MAX_EXECUTION_TIMERS
@@ -421,12 +417,17 @@ PHP_FUNCTION(frankenphp_handle_request) {
   }
 #endif
 
-  /* Call the PHP func passed to frankenphp_handle_request() */
-  zval retval = {0};
-  fci.size = sizeof fci;
-  fci.retval = &retval;
-  if (zend_call_function(&fci, &fcc) == SUCCESS) {
-    zval_ptr_dtor(&retval);
+  return true;
+}
+
+/* Closes the request or the task handled by the worker callback */
+static void frankenphp_worker_finish_request(void) {
+  /* the request has been aborted, the worker script keeps running */
+  if (is_worker_request_aborted) {
+    is_worker_request_aborted = false;
+    if (EG(exception) && zend_is_graceful_exit(EG(exception))) {
+      zend_clear_exception();
+    }
   }
 
   /*
@@ -441,6 +442,531 @@ PHP_FUNCTION(frankenphp_handle_request) {
 
   frankenphp_worker_request_shutdown();
   go_frankenphp_finish_worker_request(thread_index);
+}
+
+PHP_FUNCTION(frankenphp_handle_request) {
+  zend_fcall_info fci;
+  zend_fcall_info_cache fcc;
+
+  ZEND_PARSE_PARAMETERS_START(1, 1)
+  Z_PARAM_FUNC(fci, fcc)
+  ZEND_PARSE_PARAMETERS_END();
+
+  if (!is_worker_thread) {
+    /* not a worker, throw an error */
+    zend_throw_exception(
+        spl_ce_RuntimeException,
+        "frankenphp_handle_request() called while not in worker mode", 0);
+    RETURN_THROWS();
+  }
+
+  if (!frankenphp_worker_wait_for_request()) {
+    RETURN_FALSE;
+  }
+
+  /* Call the PHP func passed to frankenphp_handle_request() */
+  zval retval = {0};
+  fci.size = sizeof fci;
+  fci.retval = &retval;
+  if (zend_call_function(&fci, &fcc) == SUCCESS) {
+    zval_ptr_dtor(&retval);
+  }
+
+  frankenphp_worker_finish_request();
+
+  RETURN_TRUE;
+}
+
+/* Unserializes a value serialized by php_var_serialize() or by Go, dst is set
+ * to null on failure */
+static bool frankenphp_unserialize(zval *dst, const char *buf, size_t len) {
+  const unsigned char *p = (const unsigned char *)buf;
+  php_unserialize_data_t var_hash;
+  PHP_VAR_UNSERIALIZE_INIT(var_hash);
+  bool success = php_var_unserialize(dst, &p, p + len, &var_hash);
+  PHP_VAR_UNSERIALIZE_DESTROY(var_hash);
+
+  if (!success) {
+    zval_ptr_dtor(dst);
+    ZVAL_NULL(dst);
+  }
+
+  return success;
+}
+
+PHP_FUNCTION(frankenphp_handle_task) {
+  zend_fcall_info fci;
+  zend_fcall_info_cache fcc;
+
+  ZEND_PARSE_PARAMETERS_START(1, 1)
+  Z_PARAM_FUNC(fci, fcc)
+  ZEND_PARSE_PARAMETERS_END();
+
+  if (!is_worker_thread) {
+    /* not a worker, throw an error */
+    zend_throw_exception(
+        spl_ce_RuntimeException,
+        "frankenphp_handle_task() called while not in worker mode", 0);
+    RETURN_THROWS();
+  }
+
+  if (!frankenphp_worker_wait_for_request()) {
+    RETURN_FALSE;
+  }
+
+  /* the payload is serialized since tasks cross thread boundaries, HTTP
+   * requests routed to a worker that is not a task worker are not passed to
+   * the callback */
+  struct go_frankenphp_task_payload_return serialized =
+      go_frankenphp_task_payload(thread_index);
+  if (serialized.r0 != NULL) {
+    zval payload;
+    ZVAL_NULL(&payload);
+    if (frankenphp_unserialize(&payload, serialized.r0, serialized.r1)) {
+      /* Call the PHP func passed to frankenphp_handle_task() */
+      zval retval = {0};
+      fci.size = sizeof fci;
+      fci.retval = &retval;
+      fci.params = &payload;
+      fci.param_count = 1;
+      if (zend_call_function(&fci, &fcc) == SUCCESS) {
+        /* the return value is only used by frankenphp.Call() */
+        if (!EG(exception) && Z_TYPE(retval) == IS_NULL) {
+          go_frankenphp_task_result(thread_index, NULL, 0);
+        } else if (!EG(exception)) {
+          zend_string *result = zval_try_get_string(&retval);
+          if (result != NULL) {
+            go_frankenphp_task_result(thread_index, ZSTR_VAL(result),
+                                      ZSTR_LEN(result));
+            zend_string_release(result);
+          }
+        }
+        zval_ptr_dtor(&retval);
+      }
+    } else {
+      php_error_docref(NULL, E_WARNING,
+                       "Unable to unserialize the task payload");
+    }
+    zval_ptr_dtor(&payload);
+  }
+
+  frankenphp_worker_finish_request();
+
+  RETURN_TRUE;
+}
+
+PHP_FUNCTION(frankenphp_handle_websocket) {
+  zend_fcall_info fci;
+  zend_fcall_info_cache fcc;
+
+  ZEND_PARSE_PARAMETERS_START(1, 1)
+  Z_PARAM_FUNC(fci, fcc)
+  ZEND_PARSE_PARAMETERS_END();
+
+  if (!is_worker_thread) {
+    /* not a worker, throw an error */
+    zend_throw_exception(
+        spl_ce_RuntimeException,
+        "frankenphp_handle_websocket() called while not in worker mode", 0);
+    RETURN_THROWS();
+  }
+
+  if (!frankenphp_worker_wait_for_request()) {
+    RETURN_FALSE;
+  }
+
+  /* HTTP requests routed to the worker, e.g. warm-up requests, are not passed
+   * to the callback */
+  struct go_frankenphp_websocket_event_return serialized =
+      go_frankenphp_websocket_event(thread_index);
+  if (serialized.r0 != NULL) {
+    zval event;
+    ZVAL_NULL(&event);
+    if (frankenphp_unserialize(&event, serialized.r0, serialized.r1)) {
+      /* Call the PHP func passed to frankenphp_handle_websocket() */
+      zval retval = {0};
+      fci.size = sizeof fci;
+      fci.retval = &retval;
+      fci.params = &event;
+      fci.param_count = 1;
+      if (zend_call_function(&fci, &fcc) == SUCCESS) {
+        zval_ptr_dtor(&retval);
+      }
+    } else {
+      php_error_docref(NULL, E_WARNING,
+                       "Unable to unserialize the WebSocket event");
+    }
+    zval_ptr_dtor(&event);
+  }
+
+  frankenphp_worker_finish_request();
+
+  RETURN_TRUE;
+}
+
+PHP_FUNCTION(frankenphp_ws_send) {
+  zend_long conn_id;
+  zend_string *data;
+  bool binary = false;
+
+  ZEND_PARSE_PARAMETERS_START(2, 3)
+  Z_PARAM_LONG(conn_id)
+  Z_PARAM_STR(data)
+  Z_PARAM_OPTIONAL
+  Z_PARAM_BOOL(binary)
+  ZEND_PARSE_PARAMETERS_END();
+
+  /* false if the connection is closed */
+  RETURN_BOOL(
+      go_frankenphp_ws_send(conn_id, ZSTR_VAL(data), ZSTR_LEN(data), binary));
+}
+
+PHP_FUNCTION(frankenphp_ws_close) {
+  zend_long conn_id;
+
+  ZEND_PARSE_PARAMETERS_START(1, 1)
+  Z_PARAM_LONG(conn_id)
+  ZEND_PARSE_PARAMETERS_END();
+
+  RETURN_BOOL(go_frankenphp_ws_close(conn_id));
+}
+
+PHP_FUNCTION(frankenphp_dispatch_task) {
+  zend_string *worker_name;
+  zval *payload;
+
+  ZEND_PARSE_PARAMETERS_START(2, 2)
+  Z_PARAM_STR(worker_name)
+  Z_PARAM_ZVAL(payload)
+  ZEND_PARSE_PARAMETERS_END();
+
+  smart_str buf = {0};
+  php_serialize_data_t var_hash;
+  PHP_VAR_SERIALIZE_INIT(var_hash);
+  php_var_serialize(&buf, payload, &var_hash);
+  PHP_VAR_SERIALIZE_DESTROY(var_hash);
+
+  /* closures and other non-serializable values */
+  if (EG(exception)) {
+    smart_str_free(&buf);
+    RETURN_THROWS();
+  }
+
+  smart_str_0(&buf);
+  int status = go_frankenphp_dispatch_task(
+      ZSTR_VAL(worker_name), ZSTR_LEN(worker_name), ZSTR_VAL(buf.s),
+      ZSTR_LEN(buf.s));
+  smart_str_free(&buf);
+
+  switch (status) {
+  case FRANKENPHP_TASK_WORKER_NOT_FOUND:
+    zend_throw_exception_ex(
+        spl_ce_RuntimeException, 0,
+        "frankenphp_dispatch_task(): worker \"%s\" not found",
+        ZSTR_VAL(worker_name));
+    RETURN_THROWS();
+  case FRANKENPHP_TASK_NOT_TASK_WORKER:
+    zend_throw_exception_ex(
+        spl_ce_RuntimeException, 0,
+        "frankenphp_dispatch_task(): worker \"%s\" does not handle tasks",
+        ZSTR_VAL(worker_name));
+    RETURN_THROWS();
+  case FRANKENPHP_TASK_REJECTED:
+    RETURN_FALSE;
+  }
+
+  RETURN_TRUE;
+}
+
+PHP_FUNCTION(frankenphp_cache_get) {
+  zend_string *key;
+  zval *default_value = NULL;
+
+  ZEND_PARSE_PARAMETERS_START(1, 2)
+  Z_PARAM_STR(key)
+  Z_PARAM_OPTIONAL
+  Z_PARAM_ZVAL(default_value)
+  ZEND_PARSE_PARAMETERS_END();
+
+  struct go_frankenphp_cache_get_return cached =
+      go_frankenphp_cache_get(thread_index, ZSTR_VAL(key), ZSTR_LEN(key));
+  if (!cached.r2) {
+    if (default_value != NULL) {
+      RETURN_COPY(default_value);
+    }
+    RETURN_NULL();
+  }
+
+  if (!frankenphp_unserialize(return_value, cached.r0, cached.r1)) {
+    php_error_docref(NULL, E_WARNING, "Unable to unserialize the value of %s",
+                     ZSTR_VAL(key));
+  }
+}
+
+PHP_FUNCTION(frankenphp_cache_set) {
+  zend_string *key;
+  zval *value;
+  zend_long ttl = 0;
+
+  ZEND_PARSE_PARAMETERS_START(2, 3)
+  Z_PARAM_STR(key)
+  Z_PARAM_ZVAL(value)
+  Z_PARAM_OPTIONAL
+  Z_PARAM_LONG(ttl)
+  ZEND_PARSE_PARAMETERS_END();
+
+  if (ttl < 0) {
+    zend_argument_value_error(3, "must be greater than or equal to 0");
+    RETURN_THROWS();
+  }
+
+  smart_str buf = {0};
+  php_serialize_data_t var_hash;
+  PHP_VAR_SERIALIZE_INIT(var_hash);
+  php_var_serialize(&buf, value, &var_hash);
+  PHP_VAR_SERIALIZE_DESTROY(var_hash);
+
+  /* closures and other non-serializable values */
+  if (EG(exception)) {
+    smart_str_free(&buf);
+    RETURN_THROWS();
+  }
+
+  smart_str_0(&buf);
+  bool stored = go_frankenphp_cache_set(ZSTR_VAL(key), ZSTR_LEN(key),
+                                        ZSTR_VAL(buf.s), ZSTR_LEN(buf.s), ttl);
+  smart_str_free(&buf);
+
+  RETURN_BOOL(stored);
+}
+
+PHP_FUNCTION(frankenphp_cache_delete) {
+  zend_string *key;
+
+  ZEND_PARSE_PARAMETERS_START(1, 1)
+  Z_PARAM_STR(key)
+  ZEND_PARSE_PARAMETERS_END();
+
+  RETURN_BOOL(go_frankenphp_cache_delete(ZSTR_VAL(key), ZSTR_LEN(key)));
+}
+
+PHP_FUNCTION(frankenphp_cache_increment) {
+  zend_string *key;
+  zend_long step = 1;
+  zend_long ttl = 0;
+
+  ZEND_PARSE_PARAMETERS_START(1, 3)
+  Z_PARAM_STR(key)
+  Z_PARAM_OPTIONAL
+  Z_PARAM_LONG(step)
+  Z_PARAM_LONG(ttl)
+  ZEND_PARSE_PARAMETERS_END();
+
+  if (ttl < 0) {
+    zend_argument_value_error(3, "must be greater than or equal to 0");
+    RETURN_THROWS();
+  }
+
+  /* false if the current value is not an integer */
+  struct go_frankenphp_cache_increment_return result =
+      go_frankenphp_cache_increment(ZSTR_VAL(key), ZSTR_LEN(key), step, ttl);
+  if (!result.r1) {
+    RETURN_FALSE;
+  }
+
+  RETURN_LONG(result.r0);
+}
+
+PHP_FUNCTION(frankenphp_publish) {
+  zend_string *topic;
+  zend_string *payload;
+
+  ZEND_PARSE_PARAMETERS_START(2, 2)
+  Z_PARAM_STR(topic)
+  Z_PARAM_STR(payload)
+  ZEND_PARSE_PARAMETERS_END();
+
+  RETURN_LONG(go_frankenphp_publish(ZSTR_VAL(topic), ZSTR_LEN(topic),
+                                    ZSTR_VAL(payload), ZSTR_LEN(payload)));
+}
+
+PHP_FUNCTION(frankenphp_poll) {
+  zend_string *topic;
+
+  ZEND_PARSE_PARAMETERS_START(1, 1)
+  Z_PARAM_STR(topic)
+  ZEND_PARSE_PARAMETERS_END();
+
+  /* the thread subscribes to the topic on the first call */
+  struct go_frankenphp_poll_return message =
+      go_frankenphp_poll(thread_index, ZSTR_VAL(topic), ZSTR_LEN(topic));
+  if (!message.r2) {
+    RETURN_NULL();
+  }
+  if (message.r1 == 0) {
+    RETURN_EMPTY_STRING();
+  }
+
+  RETURN_STRINGL(message.r0, message.r1);
+}
+
+/* Line breaks would end the field of an event */
+static bool frankenphp_has_line_break(zend_string *s) {
+  return memchr(ZSTR_VAL(s), '\n', ZSTR_LEN(s)) != NULL ||
+         memchr(ZSTR_VAL(s), '\r', ZSTR_LEN(s)) != NULL;
+}
+
+/* Sends the headers of an event stream, unless the script already sent its
+ * headers */
+static bool frankenphp_send_event_stream_headers(void) {
+  if (SG(headers_sent)) {
+    return true;
+  }
+
+  static const char *headers[] = {"Content-Type: text/event-stream",
+                                  "Cache-Control: no-cache",
+                                  "X-Accel-Buffering: no"};
+  for (size_t i = 0; i < sizeof(headers) / sizeof(headers[0]); i++) {
+    sapi_header_line ctr = {0};
+    ctr.line = (char *)headers[i];
+    ctr.line_len = strlen(headers[i]);
+    sapi_header_op(SAPI_HEADER_REPLACE, &ctr);
+  }
+
+  return sapi_send_headers() == SUCCESS;
+}
+
+PHP_FUNCTION(frankenphp_sse_send) {
+  zend_string *event;
+  zend_string *data;
+  zend_string *id = NULL;
+
+  ZEND_PARSE_PARAMETERS_START(2, 3)
+  Z_PARAM_STR(event)
+  Z_PARAM_STR(data)
+  Z_PARAM_OPTIONAL
+  Z_PARAM_STR_OR_NULL(id)
+  ZEND_PARSE_PARAMETERS_END();
+
+  if (frankenphp_has_line_break(event)) {
+    zend_argument_value_error(1, "must not contain line breaks");
+    RETURN_THROWS();
+  }
+  if (id != NULL && frankenphp_has_line_break(id)) {
+    zend_argument_value_error(3, "must not contain line breaks");
+    RETURN_THROWS();
+  }
+
+  if (!frankenphp_send_event_stream_headers()) {
+    RETURN_FALSE;
+  }
+
+  /* the output of the script must be sent before the event */
+  php_output_flush_all();
+
+  RETURN_BOOL(go_frankenphp_sse_send(
+      thread_index, ZSTR_VAL(event), ZSTR_LEN(event), ZSTR_VAL(data),
+      ZSTR_LEN(data), id == NULL ? NULL : ZSTR_VAL(id),
+      id == NULL ? 0 : ZSTR_LEN(id)));
+}
+
+/* Reads an optional string of the options passed to frankenphp_sendfile() */
+static bool frankenphp_sendfile_option(HashTable *options, const char *key,
+                                       zend_string **value) {
+  *value = NULL;
+  if (options == NULL) {
+    return true;
+  }
+
+  zval *zv = zend_hash_str_find(options, key, strlen(key));
+  if (zv == NULL) {
+    return true;
+  }
+  if (Z_TYPE_P(zv) != IS_STRING) {
+    zend_argument_type_error(2,
+                             "option \"%s\" must be of type string, %s given",
+                             key, zend_zval_type_name(zv));
+    return false;
+  }
+
+  *value = Z_STR_P(zv);
+  return true;
+}
+
+PHP_FUNCTION(frankenphp_sendfile) {
+  zend_string *path;
+  HashTable *options = NULL;
+  zend_string *filename, *disposition, *content_type;
+
+  ZEND_PARSE_PARAMETERS_START(1, 2)
+  Z_PARAM_PATH_STR(path)
+  Z_PARAM_OPTIONAL
+  Z_PARAM_ARRAY_HT(options)
+  ZEND_PARSE_PARAMETERS_END();
+
+  if (!frankenphp_sendfile_option(options, "filename", &filename) ||
+      !frankenphp_sendfile_option(options, "disposition", &disposition) ||
+      !frankenphp_sendfile_option(options, "content_type", &content_type)) {
+    RETURN_THROWS();
+  }
+  if (disposition != NULL &&
+      !zend_string_equals_literal_ci(disposition, "attachment") &&
+      !zend_string_equals_literal_ci(disposition, "inline")) {
+    zend_argument_value_error(
+        2, "option \"disposition\" must be \"attachment\" or \"inline\"");
+    RETURN_THROWS();
+  }
+
+  if (go_is_context_done(thread_index)) {
+    RETURN_FALSE;
+  }
+
+  if (SG(headers_sent)) {
+    php_error_docref(NULL, E_WARNING,
+                     "Cannot send the file, headers already sent");
+    RETURN_FALSE;
+  }
+
+  /* relative paths are resolved against the current working directory */
+  char resolved[MAXPATHLEN];
+  if (expand_filepath(ZSTR_VAL(path), resolved) == NULL) {
+    php_error_docref(NULL, E_WARNING, "Cannot resolve the path \"%s\"",
+                     ZSTR_VAL(path));
+    RETURN_FALSE;
+  }
+
+  switch (go_frankenphp_sendfile(
+      thread_index, resolved, strlen(resolved),
+      filename == NULL ? NULL : ZSTR_VAL(filename),
+      filename == NULL ? 0 : ZSTR_LEN(filename),
+      disposition == NULL ? NULL : ZSTR_VAL(disposition),
+      disposition == NULL ? 0 : ZSTR_LEN(disposition),
+      content_type == NULL ? NULL : ZSTR_VAL(content_type),
+      content_type == NULL ? 0 : ZSTR_LEN(content_type))) {
+  case FRANKENPHP_SENDFILE_OK:
+    break;
+  case FRANKENPHP_SENDFILE_NOT_FOUND:
+    php_error_docref(NULL, E_WARNING, "File \"%s\" not found", resolved);
+    RETURN_FALSE;
+  case FRANKENPHP_SENDFILE_FORBIDDEN:
+    php_error_docref(NULL, E_WARNING,
+                     "File \"%s\" is not in an allowed root directory",
+                     resolved);
+    RETURN_FALSE;
+  default:
+    php_error_docref(NULL, E_WARNING,
+                     "Files can only be sent while handling an HTTP request "
+                     "and when a root directory is configured");
+    RETURN_FALSE;
+  }
+
+  /* the file replaces the output of the script, the headers set by the
+   * script are kept */
+  php_output_discard_all();
+  SG(sapi_headers).send_default_content_type = 0;
+  sapi_send_headers();
+
+  go_frankenphp_finish_php_request(thread_index);
 
   RETURN_TRUE;
 }
@@ -537,9 +1063,24 @@ int frankenphp_update_server_context(bool is_worker_request,
   SG(request_info).request_uri = request_uri;
   SG(request_info).proto_num = proto_num;
 
+  /* arguments only apply to the script they have been set for */
+  if (SG(request_info).argc) {
+    SG(request_info).argc = 0;
+    SG(request_info).argv = NULL;
+    PG(register_argc_argv) = INI_BOOL("register_argc_argv");
+  }
+
   return SUCCESS;
 }
 
+/* Exposes arguments to the next script as $argv and $argc, like the CLI does.
+ * Must be called after frankenphp_update_server_context(). */
+void frankenphp_set_script_args(int argc, char **argv) {
+  SG(request_info).argc = argc;
+  SG(request_info).argv = argv;
+  PG(register_argc_argv) = 1;
+}
+
 static int frankenphp_startup(sapi_module_struct *sapi_module) {
   php_import_environment_variables = get_full_env;
 
@@ -841,8 +1382,67 @@ static void set_thread_name(char *thread_name) {
 #endif
 }
 
+/* Called by the Zend VM once EG(vm_interrupt) is set, for instance by Go when
+ * the client disconnects */
+static void frankenphp_interrupt_function(zend_execute_data *execute_data) {
+  if (original_interrupt_function) {
+    original_interrupt_function(execute_data);
+  }
+
+  if (!is_php_thread) {
+    return;
+  }
+
+  if (go_is_request_timed_out(thread_index)) {
+    if (!SG(headers_sent)) {
+      SG(sapi_headers).http_response_code = 504;
+    }
+
+    /* catchable, the thread is restarted if the script doesn't yield within
+     * the grace period */
+    zend_throw_error(NULL, "Maximum request time exceeded");
+    return;
+  }
+
+  if ((PG(connection_status) & PHP_CONNECTION_ABORTED) ||
+      !go_is_client_disconnected(thread_index)) {
+    return;
+  }
+
+  if (abort_on_disconnect && is_worker_thread) {
+    PG(connection_status) |= PHP_CONNECTION_ABORTED;
+    if (PG(ignore_user_abort)) {
+      return;
+    }
+
+    /* only stop the current request: finally blocks and destructors run,
+     * catch blocks are skipped, and the callback returns to the worker */
+    php_output_set_status(PHP_OUTPUT_DISABLED);
+    is_worker_request_aborted = true;
+    if (EG(exception)) {
+      zend_clear_exception();
+    }
+    zend_throw_graceful_exit();
+    return;
+  }
+
+  if (abort_on_disconnect) {
+    /* bails out unless ignore_user_abort() is enabled */
+    php_handle_aborted_connection();
+    return;
+  }
+
+  /* only let connection_aborted() and connection_status() reflect the state */
+  PG(connection_status) |= PHP_CONNECTION_ABORTED;
+}
+
+void frankenphp_interrupt_vm(void *vm_interrupt) {
+  zend_atomic_bool_store((zend_atomic_bool *)vm_interrupt, true);
+}
+
 static void *php_thread(void *arg) {
   thread_index = (uintptr_t)arg;
+  is_php_thread = true;
   char thread_name[16] = {0};
   snprintf(thread_name, 16, "php-%" PRIxPTR, thread_index);
   set_thread_name(thread_name);
@@ -855,6 +1455,8 @@ static void *php_thread(void *arg) {
 #endif
 #endif
 
+  go_frankenphp_set_vm_interrupt(thread_index, &EG(vm_interrupt));
+
   // loop until Go signals to stop
   char *scriptName = NULL;
   while ((scriptName = go_frankenphp_before_script_execution(thread_index))) {
@@ -862,6 +1464,8 @@ static void *php_thread(void *arg) {
                                          frankenphp_execute_script(scriptName));
   }
 
+  go_frankenphp_set_vm_interrupt(thread_index, NULL);
+
 #ifdef ZTS
   ts_free_thread();
 #endif
@@ -922,9 +1526,15 @@ static void *php_main(void *arg) {
   cfg_get_string("filter.default", &default_filter);
   should_filter_var = default_filter != NULL;
 
+  /* interrupt scripts when the client disconnects */
+  original_interrupt_function = zend_interrupt_function;
+  zend_interrupt_function = frankenphp_interrupt_function;
+
   go_frankenphp_main_thread_is_ready();
 
   /* channel closed, shutdown gracefully */
+  zend_interrupt_function = original_interrupt_function;
+  original_interrupt_function = NULL;
   frankenphp_sapi_module.shutdown(&frankenphp_sapi_module);
 
   sapi_shutdown();
@@ -1183,6 +1793,8 @@ int frankenphp_reset_opcache(void) {
 
 int frankenphp_get_current_memory_limit() { return PG(memory_limit); }
 
+size_t frankenphp_get_current_memory_usage() { return zend_memory_usage(true); }
+
 static zend_module_entry *modules = NULL;
 static int modules_len = 0;
 static int (*original_php_register_internal_extensions_func)(void) = NULL;
@@ -43,6 +43,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"unsafe"
@@ -70,7 +71,8 @@ var (
 
 	metrics Metrics = nullMetrics{}
 
-	maxWaitTime time.Duration
+	maxWaitTime    time.Duration
+	maxQueueLength int
 )
 
 type syslogLevel int
@@ -156,11 +158,10 @@ func calculateMaxThreads(opt *opt) (int, int, int, error) {
 
 	var numWorkers int
 	for i, w := range opt.workers {
-		if w.num <= 0 {
-			// https://github.com/dunglas/frankenphp/issues/126
-			opt.workers[i].num = maxProcs
+		if err := resolveWorkerNum(&opt.workers[i], maxProcs); err != nil {
+			return 0, 0, 0, err
 		}
-		metrics.TotalWorkers(w.name, w.num)
+		metrics.TotalWorkers(w.name, opt.workers[i].num)
 
 		numWorkers += opt.workers[i].num
 	}
@@ -215,6 +216,29 @@ func calculateMaxThreads(opt *opt) (int, int, int, error) {
 	return opt.numThreads, numWorkers, opt.maxThreads, nil
 }
 
+// resolveWorkerNum validates the thread limits of a worker and sets its default number of threads
+func resolveWorkerNum(w *workerOpt, maxProcs int) error {
+	if w.maxThreads > 0 && w.minThreads > w.maxThreads {
+		return fmt.Errorf("min_threads (%d) must be less than or equal to max_threads (%d) for worker %q", w.minThreads, w.maxThreads, w.name)
+	}
+
+	if w.num <= 0 {
+		// https://github.com/dunglas/frankenphp/issues/126
+		w.num = maxProcs
+		if w.minThreads > 0 {
+			w.num = w.minThreads
+		} else if w.maxThreads > 0 {
+			w.num = min(maxProcs, w.maxThreads)
+		}
+	} else if w.num < w.minThreads {
+		return fmt.Errorf("num (%d) must be greater than or equal to min_threads (%d) for worker %q", w.num, w.minThreads, w.name)
+	} else if w.maxThreads > 0 && w.num > w.maxThreads {
+		return fmt.Errorf("num (%d) must be less than or equal to max_threads (%d) for worker %q", w.num, w.maxThreads, w.name)
+	}
+
+	return nil
+}
+
 // Init starts the PHP runtime and the configured workers.
 func Init(options ...Option) error {
 	if isRunning {
@@ -254,6 +278,20 @@ func Init(options ...Option) error {
 	}
 
 	maxWaitTime = opt.maxWaitTime
+	maxQueueLength = opt.maxQueueLength
+	watcherRestartBatch = opt.watcherRestartBatch
+	workerHooks = opt.workerHooks
+	requestHooks = opt.requestHooks
+	C.abort_on_disconnect = C.bool(opt.abortOnDisconnect)
+	defaultRequestTimeout = opt.requestTimeout
+	requestTimeoutGracePeriod = opt.requestTimeoutGrace
+	if requestTimeoutGracePeriod <= 0 {
+		requestTimeoutGracePeriod = defaultRequestTimeoutGracePeriod
+	}
+	initCache(opt.cacheMaxMemory)
+	if err := initSendfileRoots(opt.sendfileRoots); err != nil {
+		return err
+	}
 
 	totalThreadCount, workerThreadCount, maxThreadCount, err := calculateMaxThreads(opt)
 	if err != nil {
@@ -293,7 +331,8 @@ func Init(options ...Option) error {
 		return err
 	}
 
-	initAutoScaling(mainThread)
+	initAutoScaling(mainThread, opt.scalingPolicy, opt.scaleDownMode)
+	startScheduledJobs(mainThread, opt.scheduledJobs)
 
 	ctx := context.Background()
 	logger.LogAttrs(ctx, slog.LevelInfo, "FrankenPHP started 🐘", slog.String("php_version", Version().Version), slog.Int("num_threads", mainThread.numThreads), slog.Int("max_threads", mainThread.maxThreads))
@@ -311,8 +350,12 @@ func Shutdown() {
 	}
 
 	drainWatcher()
+	drainScheduledJobs()
+	drainWebSockets()
 	drainAutoScaling()
 	drainPHPThreads()
+	drainCache()
+	drainSendfileRoots()
 
 	metrics.Shutdown()
 
@@ -382,6 +425,16 @@ func updateServerContext(thread *phpThread, fc *frankenPHPContext, isWorkerReque
 		return ErrRequestContextCreation
 	}
 
+	if len(fc.argv) > 0 {
+		cArgv := make([]*C.char, len(fc.argv))
+		for i, arg := range fc.argv {
+			cArgv[i] = thread.pinCString(arg)
+		}
+		thread.Pin(unsafe.SliceData(cArgv))
+
+		C.frankenphp_set_script_args(C.int(len(cArgv)), unsafe.SliceData(cArgv))
+	}
+
 	return nil
 }
 
@@ -397,19 +450,44 @@ func ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) error
 	}
 
 	fc.responseWriter = responseWriter
+	fc.applyDefaultTimeout()
+	if fc.timeout != nil {
+		fc.timeout.writer = newTimeoutResponseWriter(responseWriter)
+		fc.responseWriter = fc.timeout.writer
+	}
 
 	if !fc.validate() {
 		return nil
 	}
 
 	// Detect if a worker is available to handle this request
-	if worker, ok := workers[getWorkerKey(fc.workerName, fc.scriptFilename)]; ok {
+	if worker, ok := getWorker(getWorkerKey(fc.workerName, fc.scriptFilename)); ok {
+		if worker.tasks {
+			// task workers only handle the tasks and calls sent from Go and PHP
+			fc.reject(http.StatusNotFound, "Not Found")
+			return nil
+		}
+
+		if worker.webSocket {
+			// the connection is held until it is closed, the response writer must not be wrapped to hijack it
+			worker.serveWebSocket(fc, responseWriter, request)
+			return nil
+		}
+
 		worker.handleRequest(fc)
+		fc.serveFile()
+		return nil
+	}
+
+	// the worker has been removed at runtime, its script cannot run on regular threads
+	if fc.workerName != "" && isRemovedWorker(fc.workerName) {
+		fc.reject(http.StatusServiceUnavailable, "Service Unavailable")
 		return nil
 	}
 
 	// If no worker was available, send the request to non-worker threads
 	handleRequestWithRegularPHPThreads(fc)
+	fc.serveFile()
 	return nil
 }
 
@@ -515,6 +593,11 @@ func go_write_headers(threadIndex C.uintptr_t, status C.int, headers *C.zend_lli
 		current = current.next
 	}
 
+	if fc.sendfile != nil {
+		// the status code is sent along with the file
+		return C.bool(true)
+	}
+
 	fc.responseWriter.WriteHeader(int(status))
 
 	if status >= 100 && status < 200 {
@@ -643,6 +726,18 @@ func freeArgs(argv []*C.char) {
 	}
 }
 
+// enqueue reserves a place in a queue holding at most maxLength requests (0 means unbounded)
+// it returns false if the queue is full
+func enqueue(queueLength *atomic.Int64, maxLength int) bool {
+	if queueLength.Add(1) > int64(maxLength) && maxLength > 0 {
+		queueLength.Add(-1)
+
+		return false
+	}
+
+	return true
+}
+
 func timeoutChan(timeout time.Duration) <-chan time.Time {
 	if timeout == 0 {
 		return nil
@@ -29,6 +29,20 @@ typedef struct php_variable {
   char *data;
 } php_variable;
 
+typedef enum {
+  FRANKENPHP_TASK_DISPATCHED,
+  FRANKENPHP_TASK_WORKER_NOT_FOUND,
+  FRANKENPHP_TASK_NOT_TASK_WORKER,
+  FRANKENPHP_TASK_REJECTED,
+} frankenphp_task_status;
+
+typedef enum {
+  FRANKENPHP_SENDFILE_OK,
+  FRANKENPHP_SENDFILE_NOT_FOUND,
+  FRANKENPHP_SENDFILE_FORBIDDEN,
+  FRANKENPHP_SENDFILE_UNAVAILABLE,
+} frankenphp_sendfile_status;
+
 typedef struct frankenphp_version {
   unsigned char major_version;
   unsigned char minor_version;
@@ -59,6 +73,7 @@ int frankenphp_update_server_context(bool is_worker_request,
                                      char *path_translated, char *request_uri,
                                      const char *content_type, char *auth_user,
                                      char *auth_password, int proto_num);
+void frankenphp_set_script_args(int argc, char **argv);
 int frankenphp_request_startup();
 int frankenphp_execute_script(char *file_name);
 
@@ -73,7 +88,10 @@ void frankenphp_register_variable_safe(char *key, char *var, size_t val_len,
                                        zval *track_vars_array);
 zend_string *frankenphp_init_persistent_string(const char *string, size_t len);
 int frankenphp_reset_opcache(void);
+void frankenphp_interrupt_vm(void *vm_interrupt);
+extern bool abort_on_disconnect;
 int frankenphp_get_current_memory_limit();
+size_t frankenphp_get_current_memory_usage();
 void frankenphp_add_assoc_str_ex(zval *track_vars_array, char *key,
                                  size_t keylen, zend_string *val);
 
@@ -4,6 +4,38 @@
 
 function frankenphp_handle_request(callable $callback): bool {}
 
+function frankenphp_handle_task(callable $callback): bool {}
+
+function frankenphp_dispatch_task(string $worker, mixed $payload): bool {}
+
+function frankenphp_handle_websocket(callable $callback): bool {}
+
+function frankenphp_ws_send(int $connId, string $data, bool $binary = false): bool {}
+
+function frankenphp_ws_close(int $connId): bool {}
+
+function frankenphp_cache_get(string $key, mixed $default = null): mixed {}
+
+function frankenphp_cache_set(string $key, mixed $value, int $ttl = 0): bool {}
+
+function frankenphp_cache_delete(string $key): bool {}
+
+function frankenphp_cache_increment(string $key, int $step = 1, int $ttl = 0): int|false {}
+
+function frankenphp_publish(string $topic, string $payload): int {}
+
+function frankenphp_poll(string $topic): ?string {}
+
+function frankenphp_sse_send(string $event, string $data, ?string $id = null): bool {}
+
+/**
+ * Ends the PHP side of the request, the file is then sent by FrankenPHP with support for Range and conditional requests.
+ * The file must be in one of the configured root directories.
+ *
+ * @param array{filename?: string, disposition?: 'attachment'|'inline', content_type?: string} $options
+ */
+function frankenphp_sendfile(string $path, array $options = []): bool {}
+
 function headers_send(int $status = 200): int {}
 
 function frankenphp_finish_request(): bool {}
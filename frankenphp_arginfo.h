@@ -1,11 +1,82 @@
 /* This is a generated file, edit the .stub.php file instead.
- * Stub hash: 05ebde17137c559e891362fba6524fad1e0a2dfe */
+ * Stub hash: ebe1ae75701d14be19ece87afcbc500afcdae19f */
 
 ZEND_BEGIN_ARG_WITH_RETURN_TYPE_INFO_EX(arginfo_frankenphp_handle_request, 0, 1,
                                         _IS_BOOL, 0)
 ZEND_ARG_TYPE_INFO(0, callback, IS_CALLABLE, 0)
 ZEND_END_ARG_INFO()
 
+#define arginfo_frankenphp_handle_task arginfo_frankenphp_handle_request
+
+ZEND_BEGIN_ARG_WITH_RETURN_TYPE_INFO_EX(arginfo_frankenphp_dispatch_task, 0, 2,
+                                        _IS_BOOL, 0)
+ZEND_ARG_TYPE_INFO(0, worker, IS_STRING, 0)
+ZEND_ARG_TYPE_INFO(0, payload, IS_MIXED, 0)
+ZEND_END_ARG_INFO()
+
+#define arginfo_frankenphp_handle_websocket arginfo_frankenphp_handle_request
+
+ZEND_BEGIN_ARG_WITH_RETURN_TYPE_INFO_EX(arginfo_frankenphp_ws_send, 0, 2,
+                                        _IS_BOOL, 0)
+ZEND_ARG_TYPE_INFO(0, connId, IS_LONG, 0)
+ZEND_ARG_TYPE_INFO(0, data, IS_STRING, 0)
+ZEND_ARG_TYPE_INFO_WITH_DEFAULT_VALUE(0, binary, _IS_BOOL, 0, "false")
+ZEND_END_ARG_INFO()
+
+ZEND_BEGIN_ARG_WITH_RETURN_TYPE_INFO_EX(arginfo_frankenphp_ws_close, 0, 1,
+                                        _IS_BOOL, 0)
+ZEND_ARG_TYPE_INFO(0, connId, IS_LONG, 0)
+ZEND_END_ARG_INFO()
+
+ZEND_BEGIN_ARG_WITH_RETURN_TYPE_INFO_EX(arginfo_frankenphp_cache_get, 0, 1,
+                                        IS_MIXED, 0)
+ZEND_ARG_TYPE_INFO(0, key, IS_STRING, 0)
+ZEND_ARG_TYPE_INFO_WITH_DEFAULT_VALUE(0, default, IS_MIXED, 0, "null")
+ZEND_END_ARG_INFO()
+
+ZEND_BEGIN_ARG_WITH_RETURN_TYPE_INFO_EX(arginfo_frankenphp_cache_set, 0, 2,
+                                        _IS_BOOL, 0)
+ZEND_ARG_TYPE_INFO(0, key, IS_STRING, 0)
+ZEND_ARG_TYPE_INFO(0, value, IS_MIXED, 0)
+ZEND_ARG_TYPE_INFO_WITH_DEFAULT_VALUE(0, ttl, IS_LONG, 0, "0")
+ZEND_END_ARG_INFO()
+
+ZEND_BEGIN_ARG_WITH_RETURN_TYPE_INFO_EX(arginfo_frankenphp_cache_delete, 0, 1,
+                                        _IS_BOOL, 0)
+ZEND_ARG_TYPE_INFO(0, key, IS_STRING, 0)
+ZEND_END_ARG_INFO()
+
+ZEND_BEGIN_ARG_WITH_RETURN_TYPE_MASK_EX(arginfo_frankenphp_cache_increment, 0,
+                                        1, MAY_BE_LONG | MAY_BE_FALSE)
+ZEND_ARG_TYPE_INFO(0, key, IS_STRING, 0)
+ZEND_ARG_TYPE_INFO_WITH_DEFAULT_VALUE(0, step, IS_LONG, 0, "1")
+ZEND_ARG_TYPE_INFO_WITH_DEFAULT_VALUE(0, ttl, IS_LONG, 0, "0")
+ZEND_END_ARG_INFO()
+
+ZEND_BEGIN_ARG_WITH_RETURN_TYPE_INFO_EX(arginfo_frankenphp_publish, 0, 2,
+                                        IS_LONG, 0)
+ZEND_ARG_TYPE_INFO(0, topic, IS_STRING, 0)
+ZEND_ARG_TYPE_INFO(0, payload, IS_STRING, 0)
+ZEND_END_ARG_INFO()
+
+ZEND_BEGIN_ARG_WITH_RETURN_TYPE_INFO_EX(arginfo_frankenphp_poll, 0, 1,
+                                        IS_STRING, 1)
+ZEND_ARG_TYPE_INFO(0, topic, IS_STRING, 0)
+ZEND_END_ARG_INFO()
+
+ZEND_BEGIN_ARG_WITH_RETURN_TYPE_INFO_EX(arginfo_frankenphp_sse_send, 0, 2,
+                                        _IS_BOOL, 0)
+ZEND_ARG_TYPE_INFO(0, event, IS_STRING, 0)
+ZEND_ARG_TYPE_INFO(0, data, IS_STRING, 0)
+ZEND_ARG_TYPE_INFO_WITH_DEFAULT_VALUE(0, id, IS_STRING, 1, "null")
+ZEND_END_ARG_INFO()
+
+ZEND_BEGIN_ARG_WITH_RETURN_TYPE_INFO_EX(arginfo_frankenphp_sendfile, 0, 1,
+                                        _IS_BOOL, 0)
+ZEND_ARG_TYPE_INFO(0, path, IS_STRING, 0)
+ZEND_ARG_TYPE_INFO_WITH_DEFAULT_VALUE(0, options, IS_ARRAY, 0, "[]")
+ZEND_END_ARG_INFO()
+
 ZEND_BEGIN_ARG_WITH_RETURN_TYPE_INFO_EX(arginfo_headers_send, 0, 0, IS_LONG, 0)
 ZEND_ARG_TYPE_INFO_WITH_DEFAULT_VALUE(0, status, IS_LONG, 0, "200")
 ZEND_END_ARG_INFO()
@@ -31,6 +102,19 @@ ZEND_END_ARG_INFO()
 #define arginfo_apache_response_headers arginfo_frankenphp_response_headers
 
 ZEND_FUNCTION(frankenphp_handle_request);
+ZEND_FUNCTION(frankenphp_handle_task);
+ZEND_FUNCTION(frankenphp_dispatch_task);
+ZEND_FUNCTION(frankenphp_handle_websocket);
+ZEND_FUNCTION(frankenphp_ws_send);
+ZEND_FUNCTION(frankenphp_ws_close);
+ZEND_FUNCTION(frankenphp_cache_get);
+ZEND_FUNCTION(frankenphp_cache_set);
+ZEND_FUNCTION(frankenphp_cache_delete);
+ZEND_FUNCTION(frankenphp_cache_increment);
+ZEND_FUNCTION(frankenphp_publish);
+ZEND_FUNCTION(frankenphp_poll);
+ZEND_FUNCTION(frankenphp_sse_send);
+ZEND_FUNCTION(frankenphp_sendfile);
 ZEND_FUNCTION(headers_send);
 ZEND_FUNCTION(frankenphp_finish_request);
 ZEND_FUNCTION(frankenphp_request_headers);
@@ -39,6 +123,19 @@ ZEND_FUNCTION(frankenphp_response_headers);
 // clang-format off
 static const zend_function_entry ext_functions[] = {
   ZEND_FE(frankenphp_handle_request, arginfo_frankenphp_handle_request)
+  ZEND_FE(frankenphp_handle_task, arginfo_frankenphp_handle_task)
+  ZEND_FE(frankenphp_dispatch_task, arginfo_frankenphp_dispatch_task)
+  ZEND_FE(frankenphp_handle_websocket, arginfo_frankenphp_handle_websocket)
+  ZEND_FE(frankenphp_ws_send, arginfo_frankenphp_ws_send)
+  ZEND_FE(frankenphp_ws_close, arginfo_frankenphp_ws_close)
+  ZEND_FE(frankenphp_cache_get, arginfo_frankenphp_cache_get)
+  ZEND_FE(frankenphp_cache_set, arginfo_frankenphp_cache_set)
+  ZEND_FE(frankenphp_cache_delete, arginfo_frankenphp_cache_delete)
+  ZEND_FE(frankenphp_cache_increment, arginfo_frankenphp_cache_increment)
+  ZEND_FE(frankenphp_publish, arginfo_frankenphp_publish)
+  ZEND_FE(frankenphp_poll, arginfo_frankenphp_poll)
+  ZEND_FE(frankenphp_sse_send, arginfo_frankenphp_sse_send)
+  ZEND_FE(frankenphp_sendfile, arginfo_frankenphp_sendfile)
   ZEND_FE(headers_send, arginfo_headers_send)
   ZEND_FE(frankenphp_finish_request, arginfo_frankenphp_finish_request)
   ZEND_FALIAS(fastcgi_finish_request, frankenphp_finish_request, arginfo_fastcgi_finish_request)
@@ -26,6 +26,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/dunglas/frankenphp"
 	"github.com/dunglas/frankenphp/internal/fastabs"
@@ -46,6 +47,8 @@ type testOptions struct {
 	realServer         bool
 	logger             *slog.Logger
 	initOpts           []frankenphp.Option
+	workerOpts         []frankenphp.WorkerOption
+	requestOpts        []frankenphp.RequestOption
 	phpIni             map[string]string
 }
 
@@ -66,7 +69,7 @@ func runTest(t *testing.T, test func(func(http.ResponseWriter, *http.Request), *
 
 	initOpts := []frankenphp.Option{frankenphp.WithLogger(opts.logger)}
 	if opts.workerScript != "" {
-		initOpts = append(initOpts, frankenphp.WithWorkers("workerName", testDataDir+opts.workerScript, opts.nbWorkers, opts.env, opts.watch))
+		initOpts = append(initOpts, frankenphp.WithWorkers("workerName", testDataDir+opts.workerScript, opts.nbWorkers, opts.env, opts.watch, opts.workerOpts...))
 	}
 	initOpts = append(initOpts, opts.initOpts...)
 	if opts.phpIni != nil {
@@ -78,7 +81,7 @@ func runTest(t *testing.T, test func(func(http.ResponseWriter, *http.Request), *
 	defer frankenphp.Shutdown()
 
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		req, err := frankenphp.NewRequestWithContext(r, frankenphp.WithRequestDocumentRoot(testDataDir, false))
+		req, err := frankenphp.NewRequestWithContext(r, append([]frankenphp.RequestOption{frankenphp.WithRequestDocumentRoot(testDataDir, false)}, opts.requestOpts...)...)
 		assert.NoError(t, err)
 
 		err = frankenphp.ServeHTTP(w, req)
@@ -672,6 +675,106 @@ func TestFailingWorker(t *testing.T) {
 	}, &testOptions{workerScript: "failing-worker.php"})
 }
 
+func TestAbortOnClientDisconnect(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		marker := filepath.Join(t.TempDir(), "status")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequestWithContext(ctx, "GET", "http://example.com/client-disconnect.php?marker="+url.QueryEscape(marker), nil)
+
+		start := time.Now()
+		handler(httptest.NewRecorder(), req)
+
+		assert.Less(t, time.Since(start), 4*time.Second, "the script must be interrupted")
+		assert.NoFileExists(t, marker, "the script must not reach its end")
+	}, &testOptions{nbParallelRequests: 1, initOpts: []frankenphp.Option{frankenphp.WithAbortOnClientDisconnect(true)}})
+}
+
+func TestAbortOnClientDisconnectOnlyStopsTheWorkerRequest(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		marker := filepath.Join(t.TempDir(), "status")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequestWithContext(ctx, "GET", "http://example.com/worker-client-disconnect.php?marker="+url.QueryEscape(marker), nil)
+
+		start := time.Now()
+		handler(httptest.NewRecorder(), req)
+
+		assert.Less(t, time.Since(start), 4*time.Second, "the request must be interrupted")
+		assert.NoFileExists(t, marker, "the request must neither reach its end nor be caught")
+		assert.FileExists(t, marker+".finally")
+
+		// the worker script has not been restarted
+		assert.Equal(t, "requests:2", fetchBody("GET", "http://example.com/worker-client-disconnect.php", handler))
+	}, &testOptions{
+		workerScript:       "worker-client-disconnect.php",
+		nbWorkers:          1,
+		nbParallelRequests: 1,
+		initOpts:           []frankenphp.Option{frankenphp.WithAbortOnClientDisconnect(true)},
+	})
+}
+
+func TestClientDisconnectHonoursIgnoreUserAbort(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		marker := filepath.Join(t.TempDir(), "status")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequestWithContext(ctx, "GET", "http://example.com/client-disconnect.php?ignore=1&marker="+url.QueryEscape(marker), nil)
+
+		start := time.Now()
+		handler(httptest.NewRecorder(), req)
+
+		assert.Less(t, time.Since(start), 4*time.Second, "connection_aborted() must report the disconnection")
+		status, err := os.ReadFile(marker)
+		require.NoError(t, err)
+		assert.Equal(t, "1", string(status))
+	}, &testOptions{nbParallelRequests: 1, initOpts: []frankenphp.Option{frankenphp.WithAbortOnClientDisconnect(true)}})
+}
+
+func TestRequestTimeout_module(t *testing.T) { testRequestTimeout(t, &testOptions{}) }
+func TestRequestTimeout_worker(t *testing.T) {
+	testRequestTimeout(t, &testOptions{workerScript: "request-timeout.php"})
+}
+func testRequestTimeout(t *testing.T, opts *testOptions) {
+	opts.nbParallelRequests = 1
+	opts.requestOpts = []frankenphp.RequestOption{frankenphp.WithRequestTimeout(100 * time.Millisecond)}
+
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		start := time.Now()
+		req := httptest.NewRequest("GET", "http://example.com/request-timeout.php", nil)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		resp := w.Result()
+		body, _ := io.ReadAll(resp.Body)
+
+		assert.Less(t, time.Since(start), 2*time.Second, "the script must be interrupted")
+		assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+		assert.Equal(t, "caught: Maximum request time exceeded", string(body))
+	}, opts)
+}
+
+func TestRequestTimeoutGracePeriod(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		start := time.Now()
+		req := httptest.NewRequest("GET", "http://example.com/request-timeout.php?ignore=1", nil)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		resp := w.Result()
+		body, _ := io.ReadAll(resp.Body)
+
+		assert.Less(t, time.Since(start), 2*time.Second, "the request must be abandoned after the grace period")
+		assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+		assert.Equal(t, "Gateway Timeout", string(body))
+	}, &testOptions{
+		nbParallelRequests: 1,
+		initOpts:           []frankenphp.Option{frankenphp.WithRequestTimeoutGracePeriod(100 * time.Millisecond)},
+		requestOpts:        []frankenphp.RequestOption{frankenphp.WithRequestTimeout(100 * time.Millisecond)},
+	})
+}
+
 func TestEnv(t *testing.T) {
 	testEnv(t, &testOptions{nbParallelRequests: 1})
 }
@@ -0,0 +1,113 @@
+package frankenphp
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultMaxTransitionTime is the time after which a thread that is still booting or restarting is considered stuck
+const defaultMaxTransitionTime = 30 * time.Second
+
+var (
+	ErrNotLive  = errors.New("FrankenPHP is not live")
+	ErrNotReady = errors.New("FrankenPHP is not ready")
+)
+
+// states in which a thread cannot handle requests yet
+var transitionStates = []stateID{
+	stateBooting,
+	stateBootRequested,
+	stateRestarting,
+	stateYielding,
+	stateTransitionRequested,
+	stateTransitionInProgress,
+	stateTransitionComplete,
+}
+
+// states in which a thread has not reached frankenphp_handle_request() since it was assigned to its worker
+var firstBootStates = []stateID{
+	stateBooting,
+	stateBootRequested,
+	stateTransitionRequested,
+	stateTransitionInProgress,
+	stateTransitionComplete,
+}
+
+// ReadinessOptions configures when FrankenPHP is considered ready to receive traffic.
+type ReadinessOptions struct {
+	// MaxQueueDepth is the number of requests waiting for a thread above which FrankenPHP is not ready. Default: 0 (unlimited).
+	MaxQueueDepth int
+	// MaxTransitionTime is the time after which a thread still booting or restarting is considered stuck. Default: 30s.
+	MaxTransitionTime time.Duration
+}
+
+// CheckLiveness returns nil if the PHP runtime and its main thread are running.
+// Threads that are restarting or busy do not make it fail, it only inspects the state of the main thread.
+func CheckLiveness() error {
+	if !isRunning || mainThread == nil || !mainThread.state.is(stateReady) {
+		return fmt.Errorf("%w: the PHP runtime is not running", ErrNotLive)
+	}
+
+	return nil
+}
+
+// CheckReadiness returns nil if all workers have booted, no thread is stuck booting or restarting
+// and the number of queued requests is below MaxQueueDepth.
+// It only inspects the state of the threads, PHP threads are never used to answer it.
+func CheckReadiness(o ReadinessOptions) error {
+	if err := CheckLiveness(); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotReady, err)
+	}
+
+	maxTransitionTime := o.MaxTransitionTime
+	if maxTransitionTime <= 0 {
+		maxTransitionTime = defaultMaxTransitionTime
+	}
+
+	var reasons []string
+	for _, thread := range phpThreads {
+		if state, since := thread.state.current(); slices.Contains(transitionStates, state) && since > maxTransitionTime {
+			reasons = append(reasons, fmt.Sprintf("thread %d has been %s for %s", thread.threadIndex, stateNames[state], since.Truncate(time.Second)))
+		}
+	}
+
+	queueDepth := regularQueueLength.Load()
+	for _, w := range listWorkers() {
+		queueDepth += w.queueLength.Load()
+
+		if w.isUnhealthy() {
+			reasons = append(reasons, fmt.Sprintf("worker %q failed to boot too many times in a row", w.name))
+		} else if !w.hasBooted() {
+			reasons = append(reasons, fmt.Sprintf("worker %q has not booted", w.name))
+		}
+	}
+
+	if o.MaxQueueDepth > 0 && queueDepth > int64(o.MaxQueueDepth) {
+		reasons = append(reasons, fmt.Sprintf("%d requests are queued, the maximum is %d", queueDepth, o.MaxQueueDepth))
+	}
+
+	if len(reasons) > 0 {
+		return fmt.Errorf("%w: %s", ErrNotReady, strings.Join(reasons, ", "))
+	}
+
+	return nil
+}
+
+// hasBooted returns true once at least minThreads threads of the worker have reached frankenphp_handle_request()
+// threads keep counting as booted while they restart, autoscaled threads that are still booting are not required
+func (worker *worker) hasBooted() bool {
+	worker.threadMutex.RLock()
+	defer worker.threadMutex.RUnlock()
+
+	booted := 0
+	for _, thread := range worker.threads {
+		if !slices.Contains(firstBootStates, thread.state.get()) {
+			booted++
+		}
+	}
+
+	return booted >= worker.minThreads
+}
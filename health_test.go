@@ -0,0 +1,24 @@
+package frankenphp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerHasBootedOnceItsMinThreadsHaveBooted(t *testing.T) {
+	threads := []*phpThread{newPHPThread(0), newPHPThread(1), newPHPThread(2)}
+	w := &worker{name: "worker", minThreads: 2, threads: threads}
+
+	threads[0].state.set(stateReady)
+	threads[1].state.set(stateTransitionComplete)
+	threads[2].state.set(stateTransitionComplete)
+	assert.False(t, w.hasBooted())
+
+	// threads keep counting as booted while they restart
+	threads[1].state.set(stateRestarting)
+	assert.True(t, w.hasBooted())
+
+	// the third thread is above min_threads, it does not need to have booted
+	assert.True(t, threads[2].state.is(stateTransitionComplete))
+}
@@ -0,0 +1,80 @@
+package frankenphp
+
+import (
+	"context"
+	"net/http"
+)
+
+// WorkerHooks are called on the PHP thread running the worker script, they block the thread while running.
+type WorkerHooks struct {
+	// OnBoot is called right before the worker script starts on a thread
+	OnBoot func(workerName string, threadIndex int)
+	// OnStop is called once the worker script has stopped on a thread
+	OnStop func(workerName string, threadIndex int, reason StopReason)
+}
+
+// RequestHooks are called on the PHP thread handling the request, they block the thread while running.
+// Warm-up requests don't trigger request hooks.
+type RequestHooks struct {
+	// OnRequestStart is called right before PHP handles the request, ctx is the context of the request.
+	// The returned context is passed to OnRequestEnd, e.g. to carry a tracing span. Returning nil keeps ctx.
+	OnRequestStart func(ctx context.Context, r *http.Request, threadIndex int) context.Context
+	// OnRequestEnd is called once PHP has handled the request, even if the script crashed
+	OnRequestEnd func(ctx context.Context, r *http.Request, threadIndex int)
+}
+
+var (
+	workerHooks  []WorkerHooks
+	requestHooks []RequestHooks
+)
+
+func runWorkerBootHooks(worker *worker, threadIndex int) {
+	for _, h := range workerHooks {
+		if h.OnBoot != nil {
+			h.OnBoot(worker.name, threadIndex)
+		}
+	}
+}
+
+func runWorkerStopHooks(worker *worker, threadIndex int, reason StopReason) {
+	for _, h := range workerHooks {
+		if h.OnStop != nil {
+			h.OnStop(worker.name, threadIndex, reason)
+		}
+	}
+}
+
+// startRequestHooks calls the OnRequestStart hooks, each of them receives the context returned by the previous one
+func (fc *frankenPHPContext) startRequestHooks(threadIndex int) {
+	if len(requestHooks) == 0 {
+		return
+	}
+
+	ctx := fc.request.Context()
+	for _, h := range requestHooks {
+		if h.OnRequestStart == nil {
+			continue
+		}
+
+		if c := h.OnRequestStart(ctx, fc.request, threadIndex); c != nil {
+			ctx = c
+		}
+	}
+
+	fc.hooksContext = ctx
+}
+
+// endRequestHooks calls the OnRequestEnd hooks once per request
+func (fc *frankenPHPContext) endRequestHooks(threadIndex int) {
+	ctx := fc.hooksContext
+	if ctx == nil {
+		return
+	}
+	fc.hooksContext = nil
+
+	for _, h := range requestHooks {
+		if h.OnRequestEnd != nil {
+			h.OnRequestEnd(ctx, fc.request, threadIndex)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package frankenphp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dunglas/frankenphp"
+	"github.com/stretchr/testify/assert"
+)
+
+type hookKey struct{}
+
+type hookRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *hookRecorder) record(event string) {
+	r.mu.Lock()
+	r.events = append(r.events, event)
+	r.mu.Unlock()
+}
+
+func (r *hookRecorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.events...)
+}
+
+func (r *hookRecorder) options() []frankenphp.Option {
+	return []frankenphp.Option{
+		frankenphp.WithWorkerHooks(frankenphp.WorkerHooks{
+			OnBoot: func(workerName string, _ int) {
+				r.record("boot " + workerName)
+			},
+			OnStop: func(workerName string, _ int, reason frankenphp.StopReason) {
+				if reason == frankenphp.StopReasonRestart {
+					r.record("stop " + workerName)
+				}
+			},
+		}),
+		frankenphp.WithRequestHooks(frankenphp.RequestHooks{
+			OnRequestStart: func(ctx context.Context, req *http.Request, _ int) context.Context {
+				r.record("start " + req.URL.Path)
+
+				return context.WithValue(ctx, hookKey{}, req.URL.Path)
+			},
+			OnRequestEnd: func(ctx context.Context, req *http.Request, _ int) {
+				r.record("end " + ctx.Value(hookKey{}).(string))
+			},
+		}),
+	}
+}
+
+func TestWorkerAndRequestHooks(t *testing.T) {
+	recorder := &hookRecorder{}
+
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		assert.Equal(t, "requests:1", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+
+		frankenphp.RestartWorkers()
+
+		assert.Eventually(t, func() bool {
+			return len(recorder.get()) == 5
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, []string{
+			"boot workerName",
+			"start /worker-with-counter.php",
+			"end /worker-with-counter.php",
+			"stop workerName",
+			"boot workerName",
+		}, recorder.get())
+	}, &testOptions{
+		workerScript:       "worker-with-counter.php",
+		nbWorkers:          1,
+		nbParallelRequests: 1,
+		initOpts:           recorder.options(),
+	})
+}
+
+func TestRequestHooksOnRegularThreads(t *testing.T) {
+	recorder := &hookRecorder{}
+
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		fetchBody("GET", "http://example.com/index.php", handler)
+
+		assert.Eventually(t, func() bool {
+			return len(recorder.get()) == 2
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, []string{"start /index.php", "end /index.php"}, recorder.get())
+	}, &testOptions{
+		nbParallelRequests: 1,
+		initOpts:           recorder.options(),
+	})
+}
@@ -0,0 +1,240 @@
+// Package cache implements a concurrency-safe in-memory key/value store with TTLs and LRU eviction.
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// entryOverhead approximates the memory used by an entry in addition to its key and its value
+const entryOverhead = 96
+
+// ErrTooLarge is returned when a value does not fit in the cache even if it was empty
+var ErrTooLarge = errors.New("value larger than the cache capacity")
+
+// EvictionReason tells why an entry has been removed without being deleted explicitly
+type EvictionReason int
+
+const (
+	// EvictionExpired is used for entries removed once their TTL has elapsed
+	EvictionExpired EvictionReason = iota
+	// EvictionCapacity is used for the least recently used entries removed to make room for new ones
+	EvictionCapacity
+)
+
+// Cache is a key/value store bounded by the approximate memory used by its entries.
+// Values are never modified in place, slices returned by Get can be kept after the entry has been replaced.
+type Cache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	items    map[string]*list.Element
+	// most recently used entries first
+	lru *list.List
+	// OnEvict is called with the lock held when entries are evicted, it must not call the cache
+	OnEvict func(reason EvictionReason)
+	now     func() time.Time
+}
+
+type entry struct {
+	key   string
+	value []byte
+	// the zero time if the entry never expires
+	expiresAt time.Time
+}
+
+func (e *entry) size() int64 {
+	return int64(len(e.key)+len(e.value)) + entryOverhead
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// New creates a cache holding at most maxBytes, 0 means unlimited
+func New(maxBytes int64) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+		now:      time.Now,
+	}
+}
+
+// Get returns the value of the key, expired entries are never returned
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.lookup(key)
+	if e == nil {
+		return nil, false
+	}
+
+	return e.value, true
+}
+
+// Set stores the value, a ttl of 0 means that the entry never expires
+func (c *Cache) Set(key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.set(key, value, c.expiresAt(ttl))
+}
+
+// Update atomically replaces the value of the key with the one returned by fn.
+// fn receives the current value and whether the key exists, ttl only applies if the key does not exist yet.
+func (c *Cache) Update(key string, ttl time.Duration, fn func(value []byte, exists bool) ([]byte, error)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var (
+		current   []byte
+		expiresAt time.Time
+	)
+
+	e := c.lookup(key)
+	if e == nil {
+		expiresAt = c.expiresAt(ttl)
+	} else {
+		current, expiresAt = e.value, e.expiresAt
+	}
+
+	value, err := fn(current, e != nil)
+	if err != nil {
+		return err
+	}
+
+	return c.set(key, value, expiresAt)
+}
+
+// Delete removes the key and reports whether it existed
+func (c *Cache) Delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lookup(key) == nil {
+		return false
+	}
+
+	c.remove(c.items[key])
+
+	return true
+}
+
+// Clear removes all entries
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.items)
+	c.lru.Init()
+	c.bytes = 0
+}
+
+// DeleteExpired removes all expired entries, they are otherwise only removed once accessed or evicted
+func (c *Cache) DeleteExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for el := c.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if el.Value.(*entry).expired(now) {
+			c.evict(el, EvictionExpired)
+		}
+		el = prev
+	}
+}
+
+// SetMaxBytes changes the capacity of the cache, evicting entries if necessary. 0 means unlimited.
+func (c *Cache) SetMaxBytes(maxBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxBytes = maxBytes
+	c.evictOverflow()
+}
+
+// Len returns the number of entries, including expired entries that have not been removed yet
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.items)
+}
+
+// Bytes returns the approximate memory used by the entries
+func (c *Cache) Bytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.bytes
+}
+
+// lookup returns the entry of the key and marks it as recently used, expired entries are evicted
+func (c *Cache) lookup(key string) *entry {
+	el, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+
+	e := el.Value.(*entry)
+	if e.expired(c.now()) {
+		c.evict(el, EvictionExpired)
+
+		return nil
+	}
+
+	c.lru.MoveToFront(el)
+
+	return e
+}
+
+func (c *Cache) set(key string, value []byte, expiresAt time.Time) error {
+	e := &entry{key: key, value: value, expiresAt: expiresAt}
+	if c.maxBytes > 0 && e.size() > c.maxBytes {
+		return ErrTooLarge
+	}
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+
+	c.items[key] = c.lru.PushFront(e)
+	c.bytes += e.size()
+	c.evictOverflow()
+
+	return nil
+}
+
+func (c *Cache) expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+
+	return c.now().Add(ttl)
+}
+
+// evictOverflow removes the least recently used entries until the cache fits in its capacity
+func (c *Cache) evictOverflow() {
+	for c.maxBytes > 0 && c.bytes > c.maxBytes {
+		c.evict(c.lru.Back(), EvictionCapacity)
+	}
+}
+
+func (c *Cache) evict(el *list.Element, reason EvictionReason) {
+	c.remove(el)
+
+	if c.OnEvict != nil {
+		c.OnEvict(reason)
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.items, e.key)
+	c.bytes -= e.size()
+}
@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCache returns a cache with a controllable clock
+func newTestCache(maxBytes int64) (*Cache, *time.Time) {
+	now := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c := New(maxBytes)
+	c.now = func() time.Time { return now }
+
+	return c, &now
+}
+
+func TestGetSetDelete(t *testing.T) {
+	c, _ := newTestCache(0)
+
+	_, ok := c.Get("foo")
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set("foo", []byte("bar"), 0))
+	v, ok := c.Get("foo")
+	assert.True(t, ok)
+	assert.Equal(t, "bar", string(v))
+
+	require.NoError(t, c.Set("foo", []byte("baz"), 0))
+	v, _ = c.Get("foo")
+	assert.Equal(t, "baz", string(v))
+	assert.Equal(t, 1, c.Len())
+	assert.Equal(t, int64(len("foo")+len("baz")+entryOverhead), c.Bytes())
+
+	assert.True(t, c.Delete("foo"))
+	assert.False(t, c.Delete("foo"))
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, int64(0), c.Bytes())
+}
+
+func TestTTL(t *testing.T) {
+	c, now := newTestCache(0)
+	var evictions []EvictionReason
+	c.OnEvict = func(reason EvictionReason) { evictions = append(evictions, reason) }
+
+	require.NoError(t, c.Set("foo", []byte("bar"), time.Minute))
+	require.NoError(t, c.Set("forever", []byte("bar"), 0))
+
+	*now = now.Add(59 * time.Second)
+	_, ok := c.Get("foo")
+	assert.True(t, ok)
+
+	*now = now.Add(time.Second)
+	_, ok = c.Get("foo")
+	assert.False(t, ok)
+	_, ok = c.Get("forever")
+	assert.True(t, ok)
+	assert.Equal(t, []EvictionReason{EvictionExpired}, evictions)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestDeleteExpired(t *testing.T) {
+	c, now := newTestCache(0)
+
+	require.NoError(t, c.Set("a", []byte("1"), time.Second))
+	require.NoError(t, c.Set("b", []byte("2"), time.Hour))
+	require.NoError(t, c.Set("c", []byte("3"), time.Second))
+
+	*now = now.Add(time.Minute)
+	c.DeleteExpired()
+
+	assert.Equal(t, 1, c.Len())
+	_, ok := c.Get("b")
+	assert.True(t, ok)
+}
+
+func TestLRUEviction(t *testing.T) {
+	entrySize := int64(2 + entryOverhead)
+	c, _ := newTestCache(3 * entrySize)
+	var evictions []EvictionReason
+	c.OnEvict = func(reason EvictionReason) { evictions = append(evictions, reason) }
+
+	require.NoError(t, c.Set("a", []byte("1"), 0))
+	require.NoError(t, c.Set("b", []byte("2"), 0))
+	require.NoError(t, c.Set("c", []byte("3"), 0))
+
+	// "a" becomes the most recently used entry
+	_, ok := c.Get("a")
+	require.True(t, ok)
+
+	require.NoError(t, c.Set("d", []byte("4"), 0))
+
+	_, ok = c.Get("b")
+	assert.False(t, ok, "the least recently used entry must be evicted")
+	for _, key := range []string{"a", "c", "d"} {
+		_, ok = c.Get(key)
+		assert.True(t, ok, key)
+	}
+	assert.Equal(t, []EvictionReason{EvictionCapacity}, evictions)
+	assert.Equal(t, 3*entrySize, c.Bytes())
+
+	c.SetMaxBytes(entrySize)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestValueTooLarge(t *testing.T) {
+	c, _ := newTestCache(entryOverhead + 10)
+
+	assert.ErrorIs(t, c.Set("foo", make([]byte, 100), 0), ErrTooLarge)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestUpdate(t *testing.T) {
+	c, now := newTestCache(0)
+
+	increment := func(value []byte, exists bool) ([]byte, error) {
+		i := 0
+		if exists {
+			var err error
+			if i, err = strconv.Atoi(string(value)); err != nil {
+				return nil, err
+			}
+		}
+
+		return []byte(strconv.Itoa(i + 1)), nil
+	}
+
+	require.NoError(t, c.Update("counter", time.Minute, increment))
+	require.NoError(t, c.Update("counter", time.Hour, increment))
+	v, _ := c.Get("counter")
+	assert.Equal(t, "2", string(v))
+
+	// the TTL is only set when the key is created
+	*now = now.Add(time.Minute)
+	_, ok := c.Get("counter")
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set("foo", []byte("bar"), 0))
+	assert.Error(t, c.Update("foo", 0, increment))
+	v, _ = c.Get("foo")
+	assert.Equal(t, "bar", string(v), "the value must not change if the update fails")
+}
+
+func TestClear(t *testing.T) {
+	c, _ := newTestCache(0)
+
+	require.NoError(t, c.Set("a", []byte("1"), 0))
+	require.NoError(t, c.Set("b", []byte("2"), 0))
+	c.Clear()
+
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, int64(0), c.Bytes())
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	c := New(100 * entryOverhead)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := strconv.Itoa((i * j) % 200)
+				_ = c.Set(key, []byte(key), time.Millisecond)
+				c.Get(key)
+				if j%10 == 0 {
+					c.Delete(key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, c.Bytes(), int64(100*entryOverhead))
+}
@@ -12,7 +12,7 @@ var cpuCount = runtime.GOMAXPROCS(0)
 // ProbeCPUs probes the CPU usage of the process
 // if CPUs are not busy, most threads are likely waiting for I/O, so we should scale
 // if CPUs are already busy we won't gain much by scaling and want to avoid the overhead of doing so
-func ProbeCPUs(probeTime time.Duration, maxCPUUsage float64, abort chan struct{}) bool {
+func ProbeCPUs(probeTime time.Duration, maxCPUUsage float64, abort <-chan struct{}) bool {
 	var cpuStart, cpuEnd C.struct_timespec
 
 	// note: clock_gettime is a POSIX function
@@ -0,0 +1,52 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2025, time.January, 15, 10, 30, 20, 0, time.UTC)
+
+	tests := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2025, time.January, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, time.January, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2025, time.January, 15, 11, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, time.January, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, time.January, 16, 0, 0, 0, 0, time.UTC)},
+		{"30 2 * * mon-fri", time.Date(2025, time.January, 16, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, time.January, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 mar *", time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{"5/20 10 * * *", time.Date(2025, time.January, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 12 1,20 * *", time.Date(2025, time.January, 20, 12, 0, 0, 0, time.UTC)},
+		// the day of month or the day of week must match when both are restricted
+		{"0 0 31 * fri", time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		s, err := Parse(test.expr)
+		require.NoError(t, err, test.expr)
+		assert.Equal(t, test.expected, s.Next(from), test.expr)
+	}
+}
+
+func TestNextNeverMatches(t *testing.T) {
+	s, err := Parse("0 0 31 2 *")
+	require.NoError(t, err)
+
+	assert.True(t, s.Next(time.Now()).IsZero())
+}
+
+func TestParseInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "*/0 * * * *", "5-1 * * * *", "@every 5m"} {
+		_, err := Parse(expr)
+		assert.ErrorIs(t, err, ErrInvalidExpression, expr)
+	}
+}
@@ -0,0 +1,116 @@
+// Package pubsub implements an in-process publish/subscribe broker with buffered subscribers.
+package pubsub
+
+import (
+	"sync"
+)
+
+// Broker delivers the messages published on a topic to all the subscribers of this topic.
+// Publishing never blocks: messages are dropped for subscribers whose buffer is full.
+type Broker struct {
+	mu            sync.RWMutex
+	topics        map[string]map[*Subscription]struct{}
+	subscriptions int
+}
+
+// Subscription receives the messages published on a topic after it has been created
+type Subscription struct {
+	broker   *Broker
+	topic    string
+	messages chan []byte
+	once     sync.Once
+}
+
+// New creates an empty broker
+func New() *Broker {
+	return &Broker{topics: make(map[string]map[*Subscription]struct{})}
+}
+
+// Subscribe creates a subscription to the topic buffering up to bufferSize messages
+func (b *Broker) Subscribe(topic string, bufferSize int) *Subscription {
+	s := &Subscription{
+		broker:   b,
+		topic:    topic,
+		messages: make(chan []byte, bufferSize),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subscribers, ok := b.topics[topic]
+	if !ok {
+		subscribers = make(map[*Subscription]struct{})
+		b.topics[topic] = subscribers
+	}
+	subscribers[s] = struct{}{}
+	b.subscriptions++
+
+	return s
+}
+
+// Publish sends the message to the subscribers of the topic.
+// It returns the number of subscribers that received the message and the number of subscribers for which it was dropped.
+// The message is shared between subscribers and must not be modified.
+func (b *Broker) Publish(topic string, message []byte) (delivered int, dropped int) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for s := range b.topics[topic] {
+		select {
+		case s.messages <- message:
+			delivered++
+		default:
+			dropped++
+		}
+	}
+
+	return delivered, dropped
+}
+
+// Subscriptions returns the number of active subscriptions, across all topics
+func (b *Broker) Subscriptions() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.subscriptions
+}
+
+// Topic returns the topic of the subscription
+func (s *Subscription) Topic() string {
+	return s.topic
+}
+
+// Messages returns the channel of received messages, it is closed once the subscription is cancelled
+func (s *Subscription) Messages() <-chan []byte {
+	return s.messages
+}
+
+// Poll returns the oldest buffered message without blocking
+func (s *Subscription) Poll() ([]byte, bool) {
+	select {
+	case message, ok := <-s.messages:
+		return message, ok
+	default:
+		return nil, false
+	}
+}
+
+// Unsubscribe cancels the subscription, it is safe to call it several times
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		b := s.broker
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		subscribers := b.topics[s.topic]
+		delete(subscribers, s)
+		if len(subscribers) == 0 {
+			delete(b.topics, s.topic)
+		}
+		b.subscriptions--
+
+		// messages are only sent with the read lock held, so nothing can be sent to the closed channel
+		close(s.messages)
+	})
+}
@@ -0,0 +1,109 @@
+package pubsub
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishSubscribe(t *testing.T) {
+	b := New()
+
+	foo1 := b.Subscribe("foo", 10)
+	foo2 := b.Subscribe("foo", 10)
+	bar := b.Subscribe("bar", 10)
+	assert.Equal(t, 3, b.Subscriptions())
+
+	delivered, dropped := b.Publish("foo", []byte("hello"))
+	assert.Equal(t, 2, delivered)
+	assert.Equal(t, 0, dropped)
+
+	for _, s := range []*Subscription{foo1, foo2} {
+		m, ok := s.Poll()
+		require.True(t, ok)
+		assert.Equal(t, "hello", string(m))
+	}
+
+	_, ok := bar.Poll()
+	assert.False(t, ok, "messages must only be delivered to the subscribers of the topic")
+
+	delivered, _ = b.Publish("baz", []byte("nobody"))
+	assert.Equal(t, 0, delivered)
+}
+
+func TestMessageOrder(t *testing.T) {
+	b := New()
+	s := b.Subscribe("foo", 10)
+
+	b.Publish("foo", []byte("1"))
+	b.Publish("foo", []byte("2"))
+
+	assert.Equal(t, "1", string(<-s.Messages()))
+	assert.Equal(t, "2", string(<-s.Messages()))
+}
+
+func TestDropWhenBufferIsFull(t *testing.T) {
+	b := New()
+	slow := b.Subscribe("foo", 1)
+	fast := b.Subscribe("foo", 10)
+
+	b.Publish("foo", []byte("1"))
+	delivered, dropped := b.Publish("foo", []byte("2"))
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, 1, dropped)
+
+	m, _ := slow.Poll()
+	assert.Equal(t, "1", string(m))
+	_, ok := slow.Poll()
+	assert.False(t, ok)
+
+	assert.Len(t, fast.Messages(), 2, "other subscribers must not be affected by a slow subscriber")
+}
+
+func TestUnsubscribe(t *testing.T) {
+	b := New()
+	s := b.Subscribe("foo", 10)
+	b.Publish("foo", []byte("1"))
+
+	s.Unsubscribe()
+	s.Unsubscribe()
+	assert.Equal(t, 0, b.Subscriptions())
+
+	delivered, _ := b.Publish("foo", []byte("2"))
+	assert.Equal(t, 0, delivered)
+
+	// buffered messages can still be read before the channel is closed
+	m, ok := <-s.Messages()
+	assert.True(t, ok)
+	assert.Equal(t, "1", string(m))
+	_, ok = <-s.Messages()
+	assert.False(t, ok)
+}
+
+func TestConcurrentPublishAndUnsubscribe(t *testing.T) {
+	b := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				b.Publish("foo", []byte("message"))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s := b.Subscribe("foo", 1)
+				s.Poll()
+				s.Unsubscribe()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 0, b.Subscriptions())
+}
@@ -23,8 +23,12 @@ type Metrics interface {
 	ReadyWorker(name string)
 	// StopWorker collects stopped workers
 	StopWorker(name string, reason StopReason)
+	// RecycleWorker collects worker threads restarted for exceeding their memory limit
+	RecycleWorker(name string)
 	// TotalWorkers collects expected workers
 	TotalWorkers(name string, num int)
+	// RemoveWorker resets the metrics of a worker removed at runtime
+	RemoveWorker(name string)
 	// TotalThreads collects total threads
 	TotalThreads(num int)
 	// StartRequest collects started requests
@@ -40,6 +44,34 @@ type Metrics interface {
 	DequeuedWorkerRequest(name string)
 	QueuedRequest()
 	DequeuedRequest()
+	// RejectedWorkerRequest collects worker requests rejected because the queue was full
+	RejectedWorkerRequest(name string)
+	// RejectedRequest collects regular requests rejected because the queue was full
+	RejectedRequest()
+	// FailedWorkerWarmup collects warm-up requests that failed before a worker thread was ready
+	FailedWorkerWarmup(name string)
+	// TimedOutWorkerRequest collects worker requests that exceeded their request timeout
+	TimedOutWorkerRequest(name string)
+	// TimedOutRequest collects regular requests that exceeded their request timeout
+	TimedOutRequest()
+	// StartScheduledJob collects started runs of scheduled jobs
+	StartScheduledJob(name string)
+	// StopScheduledJob collects finished runs of scheduled jobs
+	StopScheduledJob(name string, duration time.Duration, success bool)
+	// SkippedScheduledJob collects runs of scheduled jobs skipped because the previous run was still in progress or no thread was available
+	SkippedScheduledJob(name string)
+	// CacheHit collects reads of the shared cache that found a value
+	CacheHit()
+	// CacheMiss collects reads of the shared cache that found no value
+	CacheMiss()
+	// EvictedCacheEntry collects entries removed from the shared cache because they expired or to free memory
+	EvictedCacheEntry(expired bool)
+	// CacheUsage collects the number of entries and the approximate memory used by the shared cache
+	CacheUsage(entries int, bytes int64)
+	// PublishedMessage collects messages published on a topic, with the number of subscribers that received and dropped them
+	PublishedMessage(delivered int, dropped int)
+	// PubSubSubscriptions collects the number of active subscriptions to topics
+	PubSubSubscriptions(num int)
 }
 
 type nullMetrics struct{}
@@ -53,9 +85,15 @@ func (n nullMetrics) ReadyWorker(string) {
 func (n nullMetrics) StopWorker(string, StopReason) {
 }
 
+func (n nullMetrics) RecycleWorker(string) {
+}
+
 func (n nullMetrics) TotalWorkers(string, int) {
 }
 
+func (n nullMetrics) RemoveWorker(string) {
+}
+
 func (n nullMetrics) TotalThreads(int) {
 }
 
@@ -81,6 +119,26 @@ func (n nullMetrics) DequeuedWorkerRequest(string) {}
 func (n nullMetrics) QueuedRequest()   {}
 func (n nullMetrics) DequeuedRequest() {}
 
+func (n nullMetrics) RejectedWorkerRequest(string) {}
+func (n nullMetrics) RejectedRequest()             {}
+
+func (n nullMetrics) FailedWorkerWarmup(string) {}
+
+func (n nullMetrics) TimedOutWorkerRequest(string) {}
+func (n nullMetrics) TimedOutRequest()             {}
+
+func (n nullMetrics) StartScheduledJob(string)                     {}
+func (n nullMetrics) StopScheduledJob(string, time.Duration, bool) {}
+func (n nullMetrics) SkippedScheduledJob(string)                   {}
+
+func (n nullMetrics) CacheHit()              {}
+func (n nullMetrics) CacheMiss()             {}
+func (n nullMetrics) EvictedCacheEntry(bool) {}
+func (n nullMetrics) CacheUsage(int, int64)  {}
+
+func (n nullMetrics) PublishedMessage(int, int) {}
+func (n nullMetrics) PubSubSubscriptions(int)   {}
+
 type PrometheusMetrics struct {
 	registry           prometheus.Registerer
 	totalThreads       prometheus.Counter
@@ -90,10 +148,28 @@ type PrometheusMetrics struct {
 	readyWorkers       *prometheus.GaugeVec
 	workerCrashes      *prometheus.CounterVec
 	workerRestarts     *prometheus.CounterVec
+	workerRecycles     *prometheus.CounterVec
 	workerRequestTime  *prometheus.CounterVec
 	workerRequestCount *prometheus.CounterVec
 	workerQueueDepth   *prometheus.GaugeVec
+	workerRejected     *prometheus.CounterVec
+	workerWarmupFails  *prometheus.CounterVec
+	workerTimedOut     *prometheus.CounterVec
 	queueDepth         prometheus.Gauge
+	rejectedRequests   prometheus.Counter
+	timedOutRequests   prometheus.Counter
+	scheduledJobRuns   *prometheus.CounterVec
+	scheduledJobSkips  *prometheus.CounterVec
+	scheduledJobTime   *prometheus.CounterVec
+	cacheHits          prometheus.Counter
+	cacheMisses        prometheus.Counter
+	cacheEvictions     *prometheus.CounterVec
+	cacheEntries       prometheus.Gauge
+	cacheMemory        prometheus.Gauge
+	pubSubPublished    prometheus.Counter
+	pubSubDelivered    prometheus.Counter
+	pubSubDropped      prometheus.Counter
+	pubSubSubscribers  prometheus.Gauge
 	mu                 sync.Mutex
 }
 
@@ -135,6 +211,14 @@ func (m *PrometheusMetrics) StopWorker(name string, reason StopReason) {
 	}
 }
 
+func (m *PrometheusMetrics) RecycleWorker(name string) {
+	if m.workerRecycles == nil {
+		return
+	}
+
+	m.workerRecycles.WithLabelValues(name).Inc()
+}
+
 func (m *PrometheusMetrics) TotalWorkers(string, int) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -204,6 +288,19 @@ func (m *PrometheusMetrics) TotalWorkers(string, int) {
 		}
 	}
 
+	if m.workerRecycles == nil {
+		m.workerRecycles = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns,
+			Subsystem: sub,
+			Name:      "memory_recycles",
+			Help:      "Number of PHP worker restarts due to exceeding max_memory for this worker",
+		}, basicLabels)
+		if err := m.registry.Register(m.workerRecycles); err != nil &&
+			!errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+			panic(err)
+		}
+	}
+
 	if m.workerRequestTime == nil {
 		m.workerRequestTime = prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: ns,
@@ -239,6 +336,72 @@ func (m *PrometheusMetrics) TotalWorkers(string, int) {
 			panic(err)
 		}
 	}
+
+	if m.workerRejected == nil {
+		m.workerRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns,
+			Subsystem: sub,
+			Name:      "rejected_requests",
+			Help:      "Number of requests rejected because max_queue_length was reached for this worker",
+		}, basicLabels)
+		if err := m.registry.Register(m.workerRejected); err != nil &&
+			!errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+			panic(err)
+		}
+	}
+
+	if m.workerWarmupFails == nil {
+		m.workerWarmupFails = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns,
+			Subsystem: sub,
+			Name:      "warmup_failures",
+			Help:      "Number of failed warm-up requests for this worker",
+		}, basicLabels)
+		if err := m.registry.Register(m.workerWarmupFails); err != nil &&
+			!errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+			panic(err)
+		}
+	}
+
+	if m.workerTimedOut == nil {
+		m.workerTimedOut = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns,
+			Subsystem: sub,
+			Name:      "timed_out_requests",
+			Help:      "Number of requests that exceeded request_timeout for this worker",
+		}, basicLabels)
+		if err := m.registry.Register(m.workerTimedOut); err != nil &&
+			!errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+			panic(err)
+		}
+	}
+}
+
+func (m *PrometheusMetrics) RemoveWorker(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// tests do not register workers before starting them
+	if m.totalWorkers == nil {
+		return
+	}
+
+	for _, v := range []*prometheus.MetricVec{
+		m.totalWorkers.MetricVec,
+		m.busyWorkers.MetricVec,
+		m.readyWorkers.MetricVec,
+		m.workerCrashes.MetricVec,
+		m.workerRestarts.MetricVec,
+		m.workerRecycles.MetricVec,
+		m.workerRequestTime.MetricVec,
+		m.workerRequestCount.MetricVec,
+		m.workerQueueDepth.MetricVec,
+		m.workerRejected.MetricVec,
+		m.workerWarmupFails.MetricVec,
+		m.workerTimedOut.MetricVec,
+	} {
+		v.DeleteLabelValues(name)
+	}
 }
 
 func (m *PrometheusMetrics) TotalThreads(num int) {
@@ -292,10 +455,96 @@ func (m *PrometheusMetrics) DequeuedRequest() {
 	m.queueDepth.Dec()
 }
 
+func (m *PrometheusMetrics) RejectedWorkerRequest(name string) {
+	if m.workerRejected == nil {
+		return
+	}
+	m.workerRejected.WithLabelValues(name).Inc()
+}
+
+func (m *PrometheusMetrics) FailedWorkerWarmup(name string) {
+	if m.workerWarmupFails == nil {
+		return
+	}
+	m.workerWarmupFails.WithLabelValues(name).Inc()
+}
+
+func (m *PrometheusMetrics) RejectedRequest() {
+	m.rejectedRequests.Inc()
+}
+
+func (m *PrometheusMetrics) TimedOutWorkerRequest(name string) {
+	if m.workerTimedOut == nil {
+		return
+	}
+	m.workerTimedOut.WithLabelValues(name).Inc()
+}
+
+func (m *PrometheusMetrics) TimedOutRequest() {
+	m.timedOutRequests.Inc()
+}
+
+func (m *PrometheusMetrics) StartScheduledJob(string) {
+	m.busyThreads.Inc()
+}
+
+func (m *PrometheusMetrics) StopScheduledJob(name string, duration time.Duration, success bool) {
+	m.busyThreads.Dec()
+
+	status := "success"
+	if !success {
+		status = "failure"
+	}
+	m.scheduledJobRuns.WithLabelValues(name, status).Inc()
+	m.scheduledJobTime.WithLabelValues(name).Add(duration.Seconds())
+}
+
+func (m *PrometheusMetrics) SkippedScheduledJob(name string) {
+	m.scheduledJobSkips.WithLabelValues(name).Inc()
+}
+
+func (m *PrometheusMetrics) CacheHit() {
+	m.cacheHits.Inc()
+}
+
+func (m *PrometheusMetrics) CacheMiss() {
+	m.cacheMisses.Inc()
+}
+
+func (m *PrometheusMetrics) EvictedCacheEntry(expired bool) {
+	if expired {
+		m.cacheEvictions.WithLabelValues("expired").Inc()
+
+		return
+	}
+
+	m.cacheEvictions.WithLabelValues("memory").Inc()
+}
+
+func (m *PrometheusMetrics) CacheUsage(entries int, bytes int64) {
+	m.cacheEntries.Set(float64(entries))
+	m.cacheMemory.Set(float64(bytes))
+}
+
+func (m *PrometheusMetrics) PublishedMessage(delivered int, dropped int) {
+	m.pubSubPublished.Inc()
+	m.pubSubDelivered.Add(float64(delivered))
+	m.pubSubDropped.Add(float64(dropped))
+}
+
+func (m *PrometheusMetrics) PubSubSubscriptions(num int) {
+	m.pubSubSubscribers.Set(float64(num))
+}
+
 func (m *PrometheusMetrics) Shutdown() {
 	m.registry.Unregister(m.totalThreads)
 	m.registry.Unregister(m.busyThreads)
 	m.registry.Unregister(m.queueDepth)
+	m.registry.Unregister(m.rejectedRequests)
+	m.registry.Unregister(m.timedOutRequests)
+	for _, c := range m.extraCollectors() {
+		m.registry.Unregister(c)
+	}
 
 	if m.totalWorkers != nil {
 		m.registry.Unregister(m.totalWorkers)
@@ -327,6 +576,11 @@ func (m *PrometheusMetrics) Shutdown() {
 		m.workerRestarts = nil
 	}
 
+	if m.workerRecycles != nil {
+		m.registry.Unregister(m.workerRecycles)
+		m.workerRecycles = nil
+	}
+
 	if m.readyWorkers != nil {
 		m.registry.Unregister(m.readyWorkers)
 		m.readyWorkers = nil
@@ -337,6 +591,21 @@ func (m *PrometheusMetrics) Shutdown() {
 		m.workerQueueDepth = nil
 	}
 
+	if m.workerRejected != nil {
+		m.registry.Unregister(m.workerRejected)
+		m.workerRejected = nil
+	}
+
+	if m.workerWarmupFails != nil {
+		m.registry.Unregister(m.workerWarmupFails)
+		m.workerWarmupFails = nil
+	}
+
+	if m.workerTimedOut != nil {
+		m.registry.Unregister(m.workerTimedOut)
+		m.workerTimedOut = nil
+	}
+
 	m.totalThreads = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "frankenphp_total_threads",
 		Help: "Total number of PHP threads",
@@ -349,6 +618,15 @@ func (m *PrometheusMetrics) Shutdown() {
 		Name: "frankenphp_queue_depth",
 		Help: "Number of regular queued requests",
 	})
+	m.rejectedRequests = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "frankenphp_rejected_requests",
+		Help: "Number of regular requests rejected because max_queue_length was reached",
+	})
+	m.timedOutRequests = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "frankenphp_timed_out_requests",
+		Help: "Number of regular requests that exceeded request_timeout",
+	})
+	m.createExtraMetrics()
 
 	if err := m.registry.Register(m.totalThreads); err != nil &&
 		!errors.As(err, &prometheus.AlreadyRegisteredError{}) {
@@ -364,6 +642,23 @@ func (m *PrometheusMetrics) Shutdown() {
 		!errors.As(err, &prometheus.AlreadyRegisteredError{}) {
 		panic(err)
 	}
+
+	if err := m.registry.Register(m.rejectedRequests); err != nil &&
+		!errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+		panic(err)
+	}
+
+	if err := m.registry.Register(m.timedOutRequests); err != nil &&
+		!errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+		panic(err)
+	}
+
+	for _, c := range m.extraCollectors() {
+		if err := m.registry.Register(c); err != nil &&
+			!errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+			panic(err)
+		}
+	}
 }
 
 func NewPrometheusMetrics(registry prometheus.Registerer) *PrometheusMetrics {
@@ -385,15 +680,28 @@ func NewPrometheusMetrics(registry prometheus.Registerer) *PrometheusMetrics {
 			Name: "frankenphp_queue_depth",
 			Help: "Number of regular queued requests",
 		}),
+		rejectedRequests: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "frankenphp_rejected_requests",
+			Help: "Number of regular requests rejected because max_queue_length was reached",
+		}),
+		timedOutRequests: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "frankenphp_timed_out_requests",
+			Help: "Number of regular requests that exceeded request_timeout",
+		}),
 		totalWorkers:       nil,
 		busyWorkers:        nil,
 		workerRequestTime:  nil,
 		workerRequestCount: nil,
 		workerRestarts:     nil,
 		workerCrashes:      nil,
+		workerRecycles:     nil,
 		readyWorkers:       nil,
 		workerQueueDepth:   nil,
+		workerRejected:     nil,
+		workerWarmupFails:  nil,
+		workerTimedOut:     nil,
 	}
+	m.createExtraMetrics()
 
 	if err := m.registry.Register(m.totalThreads); err != nil &&
 		!errors.As(err, &prometheus.AlreadyRegisteredError{}) {
@@ -410,5 +718,120 @@ func NewPrometheusMetrics(registry prometheus.Registerer) *PrometheusMetrics {
 		panic(err)
 	}
 
+	if err := m.registry.Register(m.rejectedRequests); err != nil &&
+		!errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+		panic(err)
+	}
+
+	if err := m.registry.Register(m.timedOutRequests); err != nil &&
+		!errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+		panic(err)
+	}
+
+	for _, c := range m.extraCollectors() {
+		if err := m.registry.Register(c); err != nil &&
+			!errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+			panic(err)
+		}
+	}
+
 	return m
 }
+
+// createExtraMetrics creates the metrics of scheduled jobs, of the shared cache and of pub/sub
+func (m *PrometheusMetrics) createExtraMetrics() {
+	const ns = "frankenphp"
+
+	m.scheduledJobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: "scheduled_job",
+		Name:      "runs",
+		Help:      "Number of finished runs of this scheduled job, by status",
+	}, []string{"job", "status"})
+	m.scheduledJobSkips = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: "scheduled_job",
+		Name:      "skipped_runs",
+		Help:      "Number of runs of this scheduled job skipped because the previous run was still in progress or no thread was available",
+	}, []string{"job"})
+	m.scheduledJobTime = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: "scheduled_job",
+		Name:      "run_time",
+		Help:      "Total time spent running this scheduled job, in seconds",
+	}, []string{"job"})
+
+	m.cacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: "cache",
+		Name:      "hits",
+		Help:      "Number of reads of the shared cache that found a value",
+	})
+	m.cacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: "cache",
+		Name:      "misses",
+		Help:      "Number of reads of the shared cache that found no value",
+	})
+	m.cacheEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: "cache",
+		Name:      "evictions",
+		Help:      "Number of entries removed from the shared cache because they expired or to free memory",
+	}, []string{"reason"})
+	m.cacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: ns,
+		Subsystem: "cache",
+		Name:      "entries",
+		Help:      "Number of entries in the shared cache",
+	})
+	m.cacheMemory = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: ns,
+		Subsystem: "cache",
+		Name:      "memory_bytes",
+		Help:      "Approximate memory used by the entries of the shared cache",
+	})
+
+	m.pubSubPublished = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: "pubsub",
+		Name:      "published_messages",
+		Help:      "Number of messages published on topics",
+	})
+	m.pubSubDelivered = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: "pubsub",
+		Name:      "delivered_messages",
+		Help:      "Number of messages buffered for a subscriber",
+	})
+	m.pubSubDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: "pubsub",
+		Name:      "dropped_messages",
+		Help:      "Number of messages dropped because the buffer of the subscriber was full",
+	})
+	m.pubSubSubscribers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: ns,
+		Subsystem: "pubsub",
+		Name:      "subscriptions",
+		Help:      "Number of active subscriptions to topics",
+	})
+}
+
+// extraCollectors returns the metrics created by createExtraMetrics
+func (m *PrometheusMetrics) extraCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.scheduledJobRuns,
+		m.scheduledJobSkips,
+		m.scheduledJobTime,
+		m.cacheHits,
+		m.cacheMisses,
+		m.cacheEvictions,
+		m.cacheEntries,
+		m.cacheMemory,
+		m.pubSubPublished,
+		m.pubSubDelivered,
+		m.pubSubDropped,
+		m.pubSubSubscribers,
+	}
+}
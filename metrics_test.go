@@ -8,17 +8,29 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func createPrometheusMetrics() *PrometheusMetrics {
-	return &PrometheusMetrics{
+	m := &PrometheusMetrics{
 		registry:     prometheus.NewRegistry(),
 		totalThreads: prometheus.NewCounter(prometheus.CounterOpts{Name: "frankenphp_total_threads"}),
 		busyThreads:  prometheus.NewGauge(prometheus.GaugeOpts{Name: "frankenphp_busy_threads"}),
 		queueDepth:   prometheus.NewGauge(prometheus.GaugeOpts{Name: "frankenphp_queue_depth"}),
-		mu:           sync.Mutex{},
+		rejectedRequests: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "frankenphp_rejected_requests",
+			Help: "Number of regular requests rejected because max_queue_length was reached",
+		}),
+		timedOutRequests: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "frankenphp_timed_out_requests",
+			Help: "Number of regular requests that exceeded request_timeout",
+		}),
+		mu: sync.Mutex{},
 	}
+	m.createExtraMetrics()
+
+	return m
 }
 
 func TestPrometheusMetrics_TotalWorkers(t *testing.T) {
@@ -29,6 +41,7 @@ func TestPrometheusMetrics_TotalWorkers(t *testing.T) {
 	require.Nil(t, m.readyWorkers)
 	require.Nil(t, m.workerCrashes)
 	require.Nil(t, m.workerRestarts)
+	require.Nil(t, m.workerRecycles)
 	require.Nil(t, m.workerRequestTime)
 	require.Nil(t, m.workerRequestCount)
 
@@ -39,6 +52,7 @@ func TestPrometheusMetrics_TotalWorkers(t *testing.T) {
 	require.NotNil(t, m.readyWorkers)
 	require.NotNil(t, m.workerCrashes)
 	require.NotNil(t, m.workerRestarts)
+	require.NotNil(t, m.workerRecycles)
 	require.NotNil(t, m.workerRequestTime)
 	require.NotNil(t, m.workerRequestCount)
 }
@@ -193,3 +207,179 @@ func TestPrometheusMetrics_TestStopReasonCrash(t *testing.T) {
 
 	}
 }
+
+func TestPrometheusMetrics_RecycleWorker(t *testing.T) {
+	m := createPrometheusMetrics()
+	m.TotalWorkers("test_worker", 2)
+	m.RecycleWorker("test_worker")
+
+	expect := `
+		# HELP frankenphp_worker_memory_recycles Number of PHP worker restarts due to exceeding max_memory for this worker
+		# TYPE frankenphp_worker_memory_recycles counter
+		frankenphp_worker_memory_recycles{worker="test_worker"} 1
+	`
+
+	require.NoError(t, testutil.CollectAndCompare(m.workerRecycles, strings.NewReader(expect)))
+}
+
+func TestPrometheusMetrics_RemoveWorker(t *testing.T) {
+	m := createPrometheusMetrics()
+	m.TotalWorkers("test_worker", 1)
+	m.TotalWorkers("other_worker", 1)
+	m.StartWorker("test_worker")
+	m.StartWorker("other_worker")
+	m.RecycleWorker("test_worker")
+
+	m.RemoveWorker("test_worker")
+
+	expect := `
+		# HELP frankenphp_total_workers Total number of PHP workers for this worker
+		# TYPE frankenphp_total_workers gauge
+		frankenphp_total_workers{worker="other_worker"} 1
+	`
+
+	require.NoError(t, testutil.CollectAndCompare(m.totalWorkers, strings.NewReader(expect)))
+	assert.Equal(t, 0, testutil.CollectAndCount(m.workerRecycles))
+}
+
+func TestPrometheusMetrics_RejectedRequests(t *testing.T) {
+	m := createPrometheusMetrics()
+	m.TotalWorkers("test_worker", 2)
+	m.RejectedWorkerRequest("test_worker")
+	m.RejectedRequest()
+
+	expectWorker := `
+		# HELP frankenphp_worker_rejected_requests Number of requests rejected because max_queue_length was reached for this worker
+		# TYPE frankenphp_worker_rejected_requests counter
+		frankenphp_worker_rejected_requests{worker="test_worker"} 1
+	`
+	expectRegular := `
+		# HELP frankenphp_rejected_requests Number of regular requests rejected because max_queue_length was reached
+		# TYPE frankenphp_rejected_requests counter
+		frankenphp_rejected_requests 1
+	`
+
+	require.NoError(t, testutil.CollectAndCompare(m.workerRejected, strings.NewReader(expectWorker)))
+	require.NoError(t, testutil.CollectAndCompare(m.rejectedRequests, strings.NewReader(expectRegular)))
+}
+
+func TestPrometheusMetrics_FailedWorkerWarmup(t *testing.T) {
+	m := createPrometheusMetrics()
+
+	// ignored until the worker is registered
+	m.FailedWorkerWarmup("test_worker")
+	require.Nil(t, m.workerWarmupFails)
+
+	m.TotalWorkers("test_worker", 1)
+	m.FailedWorkerWarmup("test_worker")
+
+	expect := `
+		# HELP frankenphp_worker_warmup_failures Number of failed warm-up requests for this worker
+		# TYPE frankenphp_worker_warmup_failures counter
+		frankenphp_worker_warmup_failures{worker="test_worker"} 1
+	`
+
+	require.NoError(t, testutil.CollectAndCompare(m.workerWarmupFails, strings.NewReader(expect)))
+}
+
+func TestPrometheusMetrics_TimedOutRequests(t *testing.T) {
+	m := createPrometheusMetrics()
+	m.TotalWorkers("test_worker", 2)
+	m.TimedOutWorkerRequest("test_worker")
+	m.TimedOutRequest()
+
+	expectWorker := `
+		# HELP frankenphp_worker_timed_out_requests Number of requests that exceeded request_timeout for this worker
+		# TYPE frankenphp_worker_timed_out_requests counter
+		frankenphp_worker_timed_out_requests{worker="test_worker"} 1
+	`
+	expectRegular := `
+		# HELP frankenphp_timed_out_requests Number of regular requests that exceeded request_timeout
+		# TYPE frankenphp_timed_out_requests counter
+		frankenphp_timed_out_requests 1
+	`
+
+	require.NoError(t, testutil.CollectAndCompare(m.workerTimedOut, strings.NewReader(expectWorker)))
+	require.NoError(t, testutil.CollectAndCompare(m.timedOutRequests, strings.NewReader(expectRegular)))
+}
+
+func TestPrometheusMetrics_ScheduledJobs(t *testing.T) {
+	m := createPrometheusMetrics()
+	m.StartScheduledJob("test_job")
+	m.StopScheduledJob("test_job", time.Second, true)
+	m.StartScheduledJob("test_job")
+	m.StopScheduledJob("test_job", time.Second, false)
+	m.SkippedScheduledJob("test_job")
+
+	expectRuns := `
+		# HELP frankenphp_scheduled_job_runs Number of finished runs of this scheduled job, by status
+		# TYPE frankenphp_scheduled_job_runs counter
+		frankenphp_scheduled_job_runs{job="test_job",status="failure"} 1
+		frankenphp_scheduled_job_runs{job="test_job",status="success"} 1
+	`
+	expectSkips := `
+		# HELP frankenphp_scheduled_job_skipped_runs Number of runs of this scheduled job skipped because the previous run was still in progress or no thread was available
+		# TYPE frankenphp_scheduled_job_skipped_runs counter
+		frankenphp_scheduled_job_skipped_runs{job="test_job"} 1
+	`
+	expectTime := `
+		# HELP frankenphp_scheduled_job_run_time Total time spent running this scheduled job, in seconds
+		# TYPE frankenphp_scheduled_job_run_time counter
+		frankenphp_scheduled_job_run_time{job="test_job"} 2
+	`
+
+	require.NoError(t, testutil.CollectAndCompare(m.scheduledJobRuns, strings.NewReader(expectRuns)))
+	require.NoError(t, testutil.CollectAndCompare(m.scheduledJobSkips, strings.NewReader(expectSkips)))
+	require.NoError(t, testutil.CollectAndCompare(m.scheduledJobTime, strings.NewReader(expectTime)))
+}
+
+func TestPrometheusMetrics_Cache(t *testing.T) {
+	m := createPrometheusMetrics()
+	m.CacheHit()
+	m.CacheHit()
+	m.CacheMiss()
+	m.EvictedCacheEntry(true)
+	m.EvictedCacheEntry(false)
+	m.CacheUsage(3, 1024)
+
+	expectHits := `
+		# HELP frankenphp_cache_hits Number of reads of the shared cache that found a value
+		# TYPE frankenphp_cache_hits counter
+		frankenphp_cache_hits 2
+	`
+	expectEvictions := `
+		# HELP frankenphp_cache_evictions Number of entries removed from the shared cache because they expired or to free memory
+		# TYPE frankenphp_cache_evictions counter
+		frankenphp_cache_evictions{reason="expired"} 1
+		frankenphp_cache_evictions{reason="memory"} 1
+	`
+	expectMemory := `
+		# HELP frankenphp_cache_memory_bytes Approximate memory used by the entries of the shared cache
+		# TYPE frankenphp_cache_memory_bytes gauge
+		frankenphp_cache_memory_bytes 1024
+	`
+
+	require.NoError(t, testutil.CollectAndCompare(m.cacheHits, strings.NewReader(expectHits)))
+	require.NoError(t, testutil.CollectAndCompare(m.cacheEvictions, strings.NewReader(expectEvictions)))
+	require.NoError(t, testutil.CollectAndCompare(m.cacheMemory, strings.NewReader(expectMemory)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.cacheMisses))
+	assert.Equal(t, float64(3), testutil.ToFloat64(m.cacheEntries))
+}
+
+func TestPrometheusMetrics_PubSub(t *testing.T) {
+	m := createPrometheusMetrics()
+	m.PublishedMessage(2, 1)
+	m.PublishedMessage(0, 0)
+	m.PubSubSubscriptions(3)
+
+	expectDropped := `
+		# HELP frankenphp_pubsub_dropped_messages Number of messages dropped because the buffer of the subscriber was full
+		# TYPE frankenphp_pubsub_dropped_messages counter
+		frankenphp_pubsub_dropped_messages 1
+	`
+
+	require.NoError(t, testutil.CollectAndCompare(m.pubSubDropped, strings.NewReader(expectDropped)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.pubSubPublished))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.pubSubDelivered))
+	assert.Equal(t, float64(3), testutil.ToFloat64(m.pubSubSubscribers))
+}
@@ -1,32 +1,68 @@
 package frankenphp
 
 import (
+	"fmt"
 	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
 // Option instances allow to configure FrankenPHP.
 type Option func(h *opt) error
 
+// WorkerOption instances allow to configure a FrankenPHP worker.
+type WorkerOption func(*workerOpt) error
+
 // opt contains the available options.
 //
 // If you change this, also update the Caddy module and the documentation.
 type opt struct {
-	numThreads  int
-	maxThreads  int
-	workers     []workerOpt
-	logger      *slog.Logger
-	metrics     Metrics
-	phpIni      map[string]string
-	maxWaitTime time.Duration
+	numThreads          int
+	maxThreads          int
+	workers             []workerOpt
+	logger              *slog.Logger
+	metrics             Metrics
+	phpIni              map[string]string
+	maxWaitTime         time.Duration
+	scalingPolicy       ScalingPolicy
+	scaleDownMode       ScaleDownMode
+	maxQueueLength      int
+	watcherRestartBatch RestartBatch
+	workerHooks         []WorkerHooks
+	requestHooks        []RequestHooks
+	abortOnDisconnect   bool
+	requestTimeout      time.Duration
+	requestTimeoutGrace time.Duration
+	scheduledJobs       []*scheduledJob
+	cacheMaxMemory      int64
+	sendfileRoots       []string
 }
 
 type workerOpt struct {
-	name     string
-	fileName string
-	num      int
-	env      PreparedEnv
-	watch    []string
+	name           string
+	fileName       string
+	num            int
+	env            PreparedEnv
+	watch          []string
+	maxRequests    int
+	maxMemory      int64
+	minThreads     int
+	maxThreads     int
+	maxQueueLength int
+	warmup         []WarmupRequest
+	webSocket      bool
+	tasks          bool
+
+	crashLoopPolicy        CrashLoopPolicy
+	minBackoff             time.Duration
+	maxBackoff             time.Duration
+	maxConsecutiveFailures int
+	unavailablePage        []byte
+	unavailablePageType    string
 }
 
 // WithNumThreads configures the number of PHP threads to start.
@@ -55,9 +91,293 @@ func WithMetrics(m Metrics) Option {
 }
 
 // WithWorkers configures the PHP workers to start
-func WithWorkers(name string, fileName string, num int, env map[string]string, watch []string) Option {
+func WithWorkers(name string, fileName string, num int, env map[string]string, watch []string, options ...WorkerOption) Option {
+	return func(o *opt) error {
+		worker, err := newWorkerOpt(name, fileName, num, env, watch, options...)
+		if err != nil {
+			return err
+		}
+
+		o.workers = append(o.workers, worker)
+
+		return nil
+	}
+}
+
+func newWorkerOpt(name string, fileName string, num int, env map[string]string, watch []string, options ...WorkerOption) (workerOpt, error) {
+	worker := workerOpt{
+		name:     name,
+		fileName: fileName,
+		num:      num,
+		env:      PrepareEnv(env),
+		watch:    watch,
+	}
+
+	for _, option := range options {
+		if err := option(&worker); err != nil {
+			return worker, err
+		}
+	}
+
+	return worker, nil
+}
+
+// WithWorkerMaxRequests restarts a worker thread after it has handled the given number of requests.
+// This is useful to mitigate memory leaks in long-running worker scripts. Default: 0 (unlimited).
+func WithWorkerMaxRequests(maxRequests int) WorkerOption {
+	return func(w *workerOpt) error {
+		if maxRequests < 0 {
+			return fmt.Errorf("max_requests must not be negative, got %d", maxRequests)
+		}
+		w.maxRequests = maxRequests
+
+		return nil
+	}
+}
+
+// WithWorkerMaxMemory restarts a worker thread once the memory used by its PHP script
+// exceeds the given amount of bytes after a request. Default: 0 (unlimited).
+func WithWorkerMaxMemory(maxMemory int64) WorkerOption {
+	return func(w *workerOpt) error {
+		if maxMemory < 0 {
+			return fmt.Errorf("max_memory must not be negative, got %d", maxMemory)
+		}
+		w.maxMemory = maxMemory
+
+		return nil
+	}
+}
+
+// WithWorkerMinThreads sets the minimum amount of threads guaranteed to a worker.
+// Idle threads above it can be downscaled, including the ones started on boot. Default: num.
+func WithWorkerMinThreads(minThreads int) WorkerOption {
+	return func(w *workerOpt) error {
+		if minThreads < 0 {
+			return fmt.Errorf("min_threads must not be negative, got %d", minThreads)
+		}
+		w.minThreads = minThreads
+
+		return nil
+	}
+}
+
+// WithWorkerMaxThreads limits the amount of threads a worker can scale up to.
+// Default: 0 (only bound by the global max_threads).
+func WithWorkerMaxThreads(maxThreads int) WorkerOption {
+	return func(w *workerOpt) error {
+		if maxThreads < 0 {
+			return fmt.Errorf("max_threads must not be negative, got %d", maxThreads)
+		}
+		w.maxThreads = maxThreads
+
+		return nil
+	}
+}
+
+// WithWorkerMaxQueueLength limits the amount of requests waiting for a thread of this worker.
+// Once the queue is full, requests are rejected with a 503 status code. Default: 0 (unlimited).
+func WithWorkerMaxQueueLength(maxQueueLength int) WorkerOption {
+	return func(w *workerOpt) error {
+		if maxQueueLength < 0 {
+			return fmt.Errorf("max_queue_length must not be negative, got %d", maxQueueLength)
+		}
+		w.maxQueueLength = maxQueueLength
+
+		return nil
+	}
+}
+
+// WithWorkerWarmup replays the given requests on each thread after its worker script has booted.
+// The thread only receives traffic once all of them have been handled, failures are logged but do not prevent booting.
+func WithWorkerWarmup(requests ...WarmupRequest) WorkerOption {
+	return func(w *workerOpt) error {
+		for _, r := range requests {
+			if !strings.HasPrefix(r.Path, "/") {
+				return fmt.Errorf("warm-up request paths must start with a slash, got %q", r.Path)
+			}
+		}
+		w.warmup = append(w.warmup, requests...)
+
+		return nil
+	}
+}
+
+// WithWorkerWebSocket makes the worker handle WebSocket connections: requests are upgraded by FrankenPHP,
+// and the open, message and close events of each connection are passed to the worker script through frankenphp_handle_websocket().
+// Requests that are not WebSocket upgrades are rejected with a 426 status code.
+func WithWorkerWebSocket(enabled bool) WorkerOption {
+	return func(w *workerOpt) error {
+		w.webSocket = enabled
+
+		return nil
+	}
+}
+
+// WithWorkerTasks makes the worker handle the tasks sent with DispatchTask, Call and frankenphp_dispatch_task(),
+// its script loops on frankenphp_handle_task(). Task workers never receive HTTP requests.
+func WithWorkerTasks(enabled bool) WorkerOption {
+	return func(w *workerOpt) error {
+		w.tasks = enabled
+
+		return nil
+	}
+}
+
+// WithWorkerCrashLoopPolicy configures what happens once the worker script failed to reach frankenphp_handle_request()
+// too many times in a row. Defaults to CrashLoopPolicyPanic.
+func WithWorkerCrashLoopPolicy(policy CrashLoopPolicy) WorkerOption {
+	return func(w *workerOpt) error {
+		switch policy {
+		case "", CrashLoopPolicyPanic, CrashLoopPolicyDisable, CrashLoopPolicyRetryForever:
+			w.crashLoopPolicy = policy
+
+			return nil
+		}
+
+		return fmt.Errorf("unknown crash loop policy %q, must be %q, %q or %q", policy, CrashLoopPolicyPanic, CrashLoopPolicyDisable, CrashLoopPolicyRetryForever)
+	}
+}
+
+// WithWorkerBackoff configures the exponential backoff between two failed boots of the worker script
+// and the number of consecutive failures after which the crash loop policy applies.
+// Zero values keep the defaults: 100ms, 1s and 6 failures.
+func WithWorkerBackoff(minBackoff time.Duration, maxBackoff time.Duration, maxConsecutiveFailures int) WorkerOption {
+	return func(w *workerOpt) error {
+		if minBackoff < 0 || maxBackoff < 0 || maxConsecutiveFailures < 0 {
+			return fmt.Errorf("backoff durations and max consecutive failures must not be negative")
+		}
+		if maxBackoff > 0 && minBackoff > maxBackoff {
+			return fmt.Errorf("min backoff (%s) must be less than or equal to max backoff (%s)", minBackoff, maxBackoff)
+		}
+		w.minBackoff = minBackoff
+		w.maxBackoff = maxBackoff
+		w.maxConsecutiveFailures = maxConsecutiveFailures
+
+		return nil
+	}
+}
+
+// WithWorkerUnavailablePage sets the file sent with the 503 responses of a worker disabled by CrashLoopPolicyDisable.
+func WithWorkerUnavailablePage(fileName string) WorkerOption {
+	return func(w *workerOpt) error {
+		if fileName == "" {
+			return nil
+		}
+
+		page, err := os.ReadFile(fileName)
+		if err != nil {
+			return fmt.Errorf("unable to read the unavailable page: %w", err)
+		}
+
+		w.unavailablePage = page
+		w.unavailablePageType = mime.TypeByExtension(filepath.Ext(fileName))
+		if w.unavailablePageType == "" {
+			w.unavailablePageType = http.DetectContentType(page)
+		}
+
+		return nil
+	}
+}
+
+// WithWorkerHooks registers functions called when a worker script boots and stops on a thread.
+// Can be passed more than once, hooks are called in the order they were registered.
+func WithWorkerHooks(hooks WorkerHooks) Option {
+	return func(o *opt) error {
+		o.workerHooks = append(o.workerHooks, hooks)
+
+		return nil
+	}
+}
+
+// WithRequestHooks registers functions called before and after PHP handles a request.
+// Can be passed more than once, hooks are called in the order they were registered.
+func WithRequestHooks(hooks RequestHooks) Option {
+	return func(o *opt) error {
+		o.requestHooks = append(o.requestHooks, hooks)
+
+		return nil
+	}
+}
+
+// WithAbortOnClientDisconnect interrupts the running PHP script as soon as the client disconnects,
+// unless the script called ignore_user_abort(true). In worker mode, only the current request is stopped.
+// By default, scripts only stop when writing to a closed connection.
+func WithAbortOnClientDisconnect(abort bool) Option {
+	return func(o *opt) error {
+		o.abortOnDisconnect = abort
+
+		return nil
+	}
+}
+
+// WithDefaultRequestTimeout sets the maximum duration of the HTTP requests that don't have a timeout
+// set with the WithRequestTimeout request option, see WithRequestTimeout. Default: 0 (no timeout).
+func WithDefaultRequestTimeout(timeout time.Duration) Option {
+	return func(o *opt) error {
+		o.requestTimeout = timeout
+
+		return nil
+	}
+}
+
+// WithRequestTimeoutGracePeriod sets the time a script has to yield once interrupted by its request timeout,
+// the client receives a 504 status code once it is exceeded. Default: 5s.
+func WithRequestTimeoutGracePeriod(gracePeriod time.Duration) Option {
+	return func(o *opt) error {
+		o.requestTimeoutGrace = gracePeriod
+
+		return nil
+	}
+}
+
+// WithScheduledJob runs a PHP script periodically according to a cron expression.
+// Runs use spare threads (inactive or autoscaled threads, never the threads of workers) and never overlap:
+// a run is skipped if the previous one is still in progress or if no spare thread is available.
+func WithScheduledJob(job ScheduledJob) Option {
 	return func(o *opt) error {
-		o.workers = append(o.workers, workerOpt{name, fileName, num, PrepareEnv(env), watch})
+		j, err := newScheduledJob(job)
+		if err != nil {
+			return err
+		}
+
+		for _, existing := range o.scheduledJobs {
+			if existing.name == j.name {
+				return fmt.Errorf("two scheduled jobs cannot have the same name: %q", j.name)
+			}
+		}
+
+		o.scheduledJobs = append(o.scheduledJobs, j)
+
+		return nil
+	}
+}
+
+// WithCacheMaxMemory limits the approximate memory used by the shared cache (frankenphp_cache_*() functions),
+// the least recently used entries are evicted once it is reached. Default: 64MB.
+func WithCacheMaxMemory(maxMemory int64) Option {
+	return func(o *opt) error {
+		if maxMemory < 0 {
+			return fmt.Errorf("cache max memory must not be negative, got %d", maxMemory)
+		}
+
+		o.cacheMaxMemory = maxMemory
+
+		return nil
+	}
+}
+
+// WithSendfileRoots sets the directories frankenphp_sendfile() can serve files from, including their subdirectories.
+// Can be passed more than once. frankenphp_sendfile() is disabled if no root directory is configured.
+func WithSendfileRoots(roots ...string) Option {
+	return func(o *opt) error {
+		for _, root := range roots {
+			resolved, err := resolveSendfileRoot(root)
+			if err != nil {
+				return fmt.Errorf("invalid sendfile root %q: %w", root, err)
+			}
+
+			o.sendfileRoots = append(o.sendfileRoots, resolved)
+		}
 
 		return nil
 	}
@@ -88,3 +408,54 @@ func WithMaxWaitTime(maxWaitTime time.Duration) Option {
 		return nil
 	}
 }
+
+// WithMaxQueueLength limits the amount of requests waiting for a regular thread.
+// Once the queue is full, requests are rejected with a 503 status code. Default: 0 (unlimited).
+func WithMaxQueueLength(maxQueueLength int) Option {
+	return func(o *opt) error {
+		if maxQueueLength < 0 {
+			return fmt.Errorf("max_queue_length must not be negative, got %d", maxQueueLength)
+		}
+		o.maxQueueLength = maxQueueLength
+
+		return nil
+	}
+}
+
+// WithScalingPolicy configures the policy deciding when threads are added or removed at runtime.
+// Defaults to the CPUScalingPolicy.
+func WithScalingPolicy(policy ScalingPolicy) Option {
+	return func(o *opt) error {
+		o.scalingPolicy = policy
+
+		return nil
+	}
+}
+
+// WithScaleDownMode configures what happens to autoscaled threads that have been idle for too long.
+// Defaults to ScaleDownModeInactive.
+func WithScaleDownMode(mode ScaleDownMode) Option {
+	return func(o *opt) error {
+		switch mode {
+		case "", ScaleDownModeInactive, ScaleDownModeStop:
+			o.scaleDownMode = mode
+
+			return nil
+		}
+
+		return fmt.Errorf("unknown scale down mode %q, must be %q or %q", mode, ScaleDownModeInactive, ScaleDownModeStop)
+	}
+}
+
+// WithWatcherRestartBatch configures workers to be restarted in batches of threads when watched files change.
+// By default, all threads are restarted at once.
+func WithWatcherRestartBatch(batch RestartBatch) Option {
+	return func(o *opt) error {
+		if batch.Threads < 0 || batch.Percent < 0 || batch.Percent > 100 {
+			return ErrInvalidRestartBatch
+		}
+		o.watcherRestartBatch = batch
+
+		return nil
+	}
+}
@@ -182,8 +182,8 @@ func TestFinishBootingAWorkerScript(t *testing.T) {
 
 func TestReturnAnErrorIf2WorkersHaveTheSameFileName(t *testing.T) {
 	workers = make(map[string]*worker)
-	_, err1 := newWorker(workerOpt{fileName: "filename.php"})
-	_, err2 := newWorker(workerOpt{fileName: "filename.php"})
+	_, err1 := newWorker(workerOpt{fileName: "filename.php"}, nil)
+	_, err2 := newWorker(workerOpt{fileName: "filename.php"}, nil)
 
 	assert.NoError(t, err1)
 	assert.Error(t, err2, "two workers cannot have the same filename")
@@ -191,8 +191,8 @@ func TestReturnAnErrorIf2WorkersHaveTheSameFileName(t *testing.T) {
 
 func TestReturnAnErrorIf2ModuleWorkersHaveTheSameName(t *testing.T) {
 	workers = make(map[string]*worker)
-	_, err1 := newWorker(workerOpt{fileName: "filename.php", name: "workername"})
-	_, err2 := newWorker(workerOpt{fileName: "filename2.php", name: "workername"})
+	_, err1 := newWorker(workerOpt{fileName: "filename.php", name: "workername"}, nil)
+	_, err2 := newWorker(workerOpt{fileName: "filename2.php", name: "workername"}, nil)
 
 	assert.NoError(t, err1)
 	assert.Error(t, err2, "two workers cannot have the same name")
@@ -205,7 +205,7 @@ func getDummyWorker(fileName string) *worker {
 	worker, _ := newWorker(workerOpt{
 		fileName: testDataPath + "/" + fileName,
 		num:      1,
-	})
+	}, nil)
 	return worker
 }
 
@@ -268,6 +268,13 @@ func TestCorrectThreadCalculation(t *testing.T) {
 	// not enough max_threads
 	testThreadCalculationError(t, &opt{numThreads: 2, maxThreads: 1})
 	testThreadCalculationError(t, &opt{maxThreads: 1, workers: oneWorkerThread})
+
+	// per-worker thread bounds
+	testThreadCalculation(t, 4, 4, &opt{numThreads: 4, workers: []workerOpt{{minThreads: 3}}})
+	testThreadCalculation(t, 3, 10, &opt{maxThreads: 10, workers: []workerOpt{{maxThreads: 2}}})
+	testThreadCalculationError(t, &opt{workers: []workerOpt{{num: 1, minThreads: 2}}})
+	testThreadCalculationError(t, &opt{workers: []workerOpt{{num: 3, maxThreads: 2}}})
+	testThreadCalculationError(t, &opt{workers: []workerOpt{{minThreads: 3, maxThreads: 2}}})
 }
 
 func testThreadCalculation(t *testing.T, expectedNumThreads int, expectedMaxThreads int, o *opt) {
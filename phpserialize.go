@@ -0,0 +1,323 @@
+package frankenphp
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// serializePHP encodes a Go value in the format of PHP's serialize(), so that it can be unserialized on a PHP thread.
+// Slices become lists and maps become associative arrays, map keys must be strings or integers.
+func serializePHP(v any) ([]byte, error) {
+	return appendPHPValue(nil, reflect.ValueOf(v))
+}
+
+func appendPHPValue(b []byte, v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return append(b, "N;"...), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(b, "N;"...), nil
+		}
+
+		return appendPHPValue(b, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, "b:1;"...), nil
+		}
+
+		return append(b, "b:0;"...), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendPHPInt(b, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if u > math.MaxInt64 {
+			return nil, fmt.Errorf("unable to convert %d to a PHP integer", u)
+		}
+
+		return appendPHPInt(b, int64(u)), nil
+	case reflect.Float32, reflect.Float64:
+		return appendPHPFloat(b, v.Float()), nil
+	case reflect.String:
+		return appendPHPString(b, v.String()), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendPHPString(b, string(v.Bytes())), nil
+		}
+
+		b = append(b, "a:"...)
+		b = strconv.AppendInt(b, int64(v.Len()), 10)
+		b = append(b, ":{"...)
+		for i := 0; i < v.Len(); i++ {
+			b = appendPHPInt(b, int64(i))
+
+			var err error
+			if b, err = appendPHPValue(b, v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+
+		return append(b, '}'), nil
+	case reflect.Map:
+		keys := v.MapKeys()
+		// sort the keys to get a stable encoding
+		slices.SortFunc(keys, compareMapKeys)
+
+		b = append(b, "a:"...)
+		b = strconv.AppendInt(b, int64(len(keys)), 10)
+		b = append(b, ":{"...)
+		for _, k := range keys {
+			var err error
+			switch k.Kind() {
+			case reflect.String:
+				b = appendPHPString(b, k.String())
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				b = appendPHPInt(b, k.Int())
+			default:
+				return nil, fmt.Errorf("unable to convert a map with %s keys to a PHP array", k.Type())
+			}
+
+			if b, err = appendPHPValue(b, v.MapIndex(k)); err != nil {
+				return nil, err
+			}
+		}
+
+		return append(b, '}'), nil
+	}
+
+	return nil, fmt.Errorf("unable to convert %s to a PHP value", v.Type())
+}
+
+// compareMapKeys sorts the keys of a map, unsupported key types are reported while encoding them
+func compareMapKeys(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	}
+
+	return 0
+}
+
+func appendPHPInt(b []byte, i int64) []byte {
+	b = append(b, "i:"...)
+	b = strconv.AppendInt(b, i, 10)
+
+	return append(b, ';')
+}
+
+func appendPHPFloat(b []byte, f float64) []byte {
+	b = append(b, "d:"...)
+	switch {
+	case math.IsNaN(f):
+		b = append(b, "NAN"...)
+	case math.IsInf(f, 1):
+		b = append(b, "INF"...)
+	case math.IsInf(f, -1):
+		b = append(b, "-INF"...)
+	default:
+		b = strconv.AppendFloat(b, f, 'g', -1, 64)
+	}
+
+	return append(b, ';')
+}
+
+func appendPHPString(b []byte, s string) []byte {
+	b = append(b, "s:"...)
+	b = strconv.AppendInt(b, int64(len(s)), 10)
+	b = append(b, ":\""...)
+	b = append(b, s...)
+
+	return append(b, "\";"...)
+}
+
+// unserializePHP decodes a value in the format of PHP's serialize().
+// Lists become []any, other arrays become map[string]any, integers are int64 and floats are float64.
+// Objects and references are not supported.
+func unserializePHP(b []byte) (any, error) {
+	v, rest, err := parsePHPValue(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("unexpected trailing data after the serialized PHP value")
+	}
+
+	return v, nil
+}
+
+func parsePHPValue(b []byte) (any, []byte, error) {
+	if len(b) < 2 {
+		return nil, nil, errUnexpectedEndOfPHPValue
+	}
+
+	switch b[0] {
+	case 'N':
+		if b[1] != ';' {
+			return nil, nil, fmt.Errorf("invalid serialized PHP null")
+		}
+
+		return nil, b[2:], nil
+	case 'b':
+		s, rest, err := readPHPToken(b, ';')
+		if err != nil || (s != "0" && s != "1") {
+			return nil, nil, fmt.Errorf("invalid serialized PHP boolean")
+		}
+
+		return s == "1", rest, nil
+	case 'i':
+		s, rest, err := readPHPToken(b, ';')
+		if err != nil {
+			return nil, nil, err
+		}
+
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid serialized PHP integer: %w", err)
+		}
+
+		return i, rest, nil
+	case 'd':
+		s, rest, err := readPHPToken(b, ';')
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var f float64
+		switch s {
+		case "NAN":
+			f = math.NaN()
+		case "INF":
+			f = math.Inf(1)
+		case "-INF":
+			f = math.Inf(-1)
+		default:
+			if f, err = strconv.ParseFloat(s, 64); err != nil {
+				return nil, nil, fmt.Errorf("invalid serialized PHP float: %w", err)
+			}
+		}
+
+		return f, rest, nil
+	case 's':
+		s, rest, err := parsePHPString(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return s, rest, nil
+	case 'a':
+		return parsePHPArray(b)
+	}
+
+	return nil, nil, fmt.Errorf("unable to convert serialized PHP values of type %q", b[0])
+}
+
+var errUnexpectedEndOfPHPValue = errors.New("unexpected end of the serialized PHP value")
+
+// readPHPToken reads the token between the type prefix (e.g. "i:") and the delimiter
+func readPHPToken(b []byte, delimiter byte) (string, []byte, error) {
+	if len(b) < 2 || b[1] != ':' {
+		return "", nil, fmt.Errorf("invalid serialized PHP value")
+	}
+
+	i := bytes.IndexByte(b[2:], delimiter)
+	if i < 0 {
+		return "", nil, errUnexpectedEndOfPHPValue
+	}
+
+	return string(b[2 : 2+i]), b[3+i:], nil
+}
+
+func parsePHPLength(b []byte) (int, []byte, error) {
+	s, rest, err := readPHPToken(b, ':')
+	if err != nil {
+		return 0, nil, err
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, nil, fmt.Errorf("invalid serialized PHP length %q", s)
+	}
+
+	return n, rest, nil
+}
+
+func parsePHPString(b []byte) (string, []byte, error) {
+	n, rest, err := parsePHPLength(b)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(rest) < n+3 {
+		return "", nil, errUnexpectedEndOfPHPValue
+	}
+	if rest[0] != '"' || rest[n+1] != '"' || rest[n+2] != ';' {
+		return "", nil, fmt.Errorf("invalid serialized PHP string")
+	}
+
+	return string(rest[1 : n+1]), rest[n+3:], nil
+}
+
+func parsePHPArray(b []byte) (any, []byte, error) {
+	n, rest, err := parsePHPLength(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(rest) == 0 || rest[0] != '{' {
+		return nil, nil, fmt.Errorf("invalid serialized PHP array")
+	}
+	rest = rest[1:]
+
+	keys := make([]any, n)
+	values := make([]any, n)
+	isList := true
+	for i := 0; i < n; i++ {
+		if len(rest) == 0 || (rest[0] != 'i' && rest[0] != 's') {
+			return nil, nil, fmt.Errorf("invalid serialized PHP array key")
+		}
+
+		if keys[i], rest, err = parsePHPValue(rest); err != nil {
+			return nil, nil, err
+		}
+		if values[i], rest, err = parsePHPValue(rest); err != nil {
+			return nil, nil, err
+		}
+
+		if k, ok := keys[i].(int64); !ok || k != int64(i) {
+			isList = false
+		}
+	}
+
+	if len(rest) == 0 || rest[0] != '}' {
+		return nil, nil, fmt.Errorf("invalid serialized PHP array")
+	}
+	rest = rest[1:]
+
+	if isList {
+		return values, rest, nil
+	}
+
+	m := make(map[string]any, n)
+	for i, k := range keys {
+		switch k := k.(type) {
+		case int64:
+			m[strconv.FormatInt(k, 10)] = values[i]
+		case string:
+			m[k] = values[i]
+		}
+	}
+
+	return m, rest, nil
+}
@@ -0,0 +1,93 @@
+package frankenphp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSerializePHP(t *testing.T) {
+	tests := []struct {
+		value    any
+		expected string
+	}{
+		{nil, "N;"},
+		{true, "b:1;"},
+		{false, "b:0;"},
+		{42, "i:42;"},
+		{uint8(7), "i:7;"},
+		{1.5, "d:1.5;"},
+		{math.Inf(-1), "d:-INF;"},
+		{"héllo", `s:6:"héllo";`},
+		{[]byte("raw"), `s:3:"raw";`},
+		{[]string{"a", "b"}, `a:2:{i:0;s:1:"a";i:1;s:1:"b";}`},
+		{map[string]any{"b": 1, "a": []any{nil}}, `a:2:{s:1:"a";a:1:{i:0;N;}s:1:"b";i:1;}`},
+		{map[int]bool{2: true, 1: false}, `a:2:{i:1;b:0;i:2;b:1;}`},
+	}
+
+	for _, test := range tests {
+		b, err := serializePHP(test.value)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, string(b))
+	}
+}
+
+func TestSerializePHPUnsupportedValue(t *testing.T) {
+	_, err := serializePHP(func() {})
+	assert.Error(t, err)
+
+	_, err = serializePHP(map[float64]string{1: "a"})
+	assert.Error(t, err)
+}
+
+func TestUnserializePHP(t *testing.T) {
+	tests := []struct {
+		serialized string
+		expected   any
+	}{
+		{"N;", nil},
+		{"b:1;", true},
+		{"b:0;", false},
+		{"i:-42;", int64(-42)},
+		{"d:1.5;", 1.5},
+		{"d:INF;", math.Inf(1)},
+		{`s:6:"héllo";`, "héllo"},
+		{`s:3:"a;b";`, "a;b"},
+		{`a:2:{i:0;s:1:"a";i:1;s:1:"b";}`, []any{"a", "b"}},
+		{`a:2:{s:1:"a";a:1:{i:0;N;}i:5;i:1;}`, map[string]any{"a": []any{nil}, "5": int64(1)}},
+		{"a:0:{}", []any{}},
+	}
+
+	for _, test := range tests {
+		v, err := unserializePHP([]byte(test.serialized))
+		require.NoError(t, err, test.serialized)
+		assert.Equal(t, test.expected, v, test.serialized)
+	}
+}
+
+func TestUnserializePHPRoundTrip(t *testing.T) {
+	value := map[string]any{"list": []any{int64(1), "two", 3.5}, "nested": map[string]any{"ok": true}}
+
+	b, err := serializePHP(value)
+	require.NoError(t, err)
+
+	v, err := unserializePHP(b)
+	require.NoError(t, err)
+	assert.Equal(t, value, v)
+}
+
+func TestUnserializePHPInvalidValue(t *testing.T) {
+	for _, serialized := range []string{
+		"",
+		"i:42",
+		`s:10:"short";`,
+		`a:1:{i:0;i:1;`,
+		`O:8:"stdClass":0:{}`,
+		"i:1;i:2;",
+	} {
+		_, err := unserializePHP([]byte(serialized))
+		assert.Error(t, err, serialized)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"runtime"
 	"sync"
 	"unsafe"
+
+	"github.com/dunglas/frankenphp/internal/pubsub"
 )
 
 // representation of the actual underlying PHP thread
@@ -22,6 +24,11 @@ type phpThread struct {
 	handler      threadHandler
 	state        *threadState
 	sandboxedEnv map[string]*C.zend_string
+	// pointer to EG(vm_interrupt) of the thread, nil while the thread is not running
+	vmInterrupt   unsafe.Pointer
+	vmInterruptMu sync.Mutex
+	// topics polled by the script with frankenphp_poll(), only accessed from the PHP thread
+	subscriptions map[string]*pubsub.Subscription
 }
 
 // interface that defines how the callbacks from the C thread should be handled
@@ -95,6 +102,17 @@ func (thread *phpThread) setHandler(handler threadHandler) {
 	thread.state.set(stateTransitionComplete)
 }
 
+// transitionToInactive converts the thread to an inactive thread from the PHP thread itself,
+// going through the same states as setHandler, the inactive handler then takes over in beforeScriptExecution
+func (thread *phpThread) transitionToInactive() {
+	thread.handlerMu.Lock()
+	defer thread.handlerMu.Unlock()
+
+	thread.state.set(stateTransitionInProgress)
+	thread.handler = &inactiveThread{thread: thread}
+	thread.state.set(stateTransitionComplete)
+}
+
 // transition to a new handler safely
 // is triggered by setHandler and executed on the PHP thread
 func (thread *phpThread) transitionToNewHandler() string {
@@ -153,6 +171,7 @@ func go_frankenphp_after_script_execution(threadIndex C.uintptr_t, exitStatus C.
 		panic(ErrScriptExecution)
 	}
 	thread.handler.afterScriptExecution(int(exitStatus))
+	thread.unsubscribeAll()
 
 	// unpin all memory used during script execution
 	thread.Unpin()
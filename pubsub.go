@@ -0,0 +1,109 @@
+package frankenphp
+
+// #include "frankenphp.h"
+import "C"
+import (
+	"unsafe"
+
+	"github.com/dunglas/frankenphp/internal/pubsub"
+)
+
+// subscriptionBufferSize is the number of messages a subscription buffers, further messages are dropped until it is read
+const subscriptionBufferSize = 256
+
+var broker = pubsub.New()
+
+// Subscription receives the messages published on a topic by PHP scripts (frankenphp_publish()) or by Publish.
+type Subscription struct {
+	subscription *pubsub.Subscription
+}
+
+// Subscribe creates a subscription to the topic, only messages published after the subscription are received.
+// Up to 256 messages are buffered, further messages are dropped until the subscriber reads them.
+// Unsubscribe must be called once the subscription is no longer used.
+func Subscribe(topic string) *Subscription {
+	s := &Subscription{subscription: broker.Subscribe(topic, subscriptionBufferSize)}
+	updatePubSubMetrics()
+
+	return s
+}
+
+// Messages returns the channel of received messages, it is closed by Unsubscribe
+func (s *Subscription) Messages() <-chan []byte {
+	return s.subscription.Messages()
+}
+
+// Unsubscribe stops receiving messages
+func (s *Subscription) Unsubscribe() {
+	s.subscription.Unsubscribe()
+	updatePubSubMetrics()
+}
+
+// Publish sends the payload to the subscribers of the topic, in Go and in PHP threads (frankenphp_poll()).
+// It never blocks and returns the number of subscribers that received the payload,
+// subscribers whose buffer is full don't receive it. The payload must not be modified once published.
+func Publish(topic string, payload []byte) int {
+	delivered, dropped := broker.Publish(topic, payload)
+	metrics.PublishedMessage(delivered, dropped)
+
+	return delivered
+}
+
+func updatePubSubMetrics() {
+	metrics.PubSubSubscriptions(broker.Subscriptions())
+}
+
+// poll returns the next message of the topic for the PHP thread, the thread subscribes to the topic on the first call
+func (thread *phpThread) poll(topic string) ([]byte, bool) {
+	s, ok := thread.subscriptions[topic]
+	if !ok {
+		if thread.subscriptions == nil {
+			thread.subscriptions = make(map[string]*pubsub.Subscription)
+		}
+
+		thread.subscriptions[topic] = broker.Subscribe(topic, subscriptionBufferSize)
+		updatePubSubMetrics()
+
+		return nil, false
+	}
+
+	return s.Poll()
+}
+
+// unsubscribeAll cancels the subscriptions of the PHP thread once its script has ended
+func (thread *phpThread) unsubscribeAll() {
+	if len(thread.subscriptions) == 0 {
+		return
+	}
+
+	for topic, s := range thread.subscriptions {
+		s.Unsubscribe()
+		delete(thread.subscriptions, topic)
+	}
+	updatePubSubMetrics()
+}
+
+//export go_frankenphp_publish
+func go_frankenphp_publish(topic *C.char, topicLen C.size_t, payload *C.char, payloadLen C.size_t) C.zend_long {
+	return C.zend_long(Publish(C.GoStringN(topic, C.int(topicLen)), C.GoBytes(unsafe.Pointer(payload), C.int(payloadLen))))
+}
+
+// go_frankenphp_poll returns the next message of the topic, the message is pinned until the end of the request
+//
+//export go_frankenphp_poll
+func go_frankenphp_poll(threadIndex C.uintptr_t, topic *C.char, topicLen C.size_t) (*C.char, C.size_t, C.bool) {
+	thread := phpThreads[threadIndex]
+
+	message, ok := thread.poll(C.GoStringN(topic, C.int(topicLen)))
+	if !ok {
+		return nil, 0, C.bool(false)
+	}
+	if len(message) == 0 {
+		return nil, 0, C.bool(true)
+	}
+
+	p := unsafe.SliceData(message)
+	thread.Pin(p)
+
+	return (*C.char)(unsafe.Pointer(p)), C.size_t(len(message)), C.bool(true)
+}
@@ -0,0 +1,66 @@
+package frankenphp_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dunglas/frankenphp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPubSubRoundTrip_module(t *testing.T) { testPubSubRoundTrip(t, &testOptions{}) }
+func TestPubSubRoundTrip_worker(t *testing.T) {
+	testPubSubRoundTrip(t, &testOptions{workerScript: "pubsub.php"})
+}
+func testPubSubRoundTrip(t *testing.T, opts *testOptions) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		body := fetchBody("GET", fmt.Sprintf("http://example.com/pubsub.php?action=roundtrip&topic=roundtrip%d", i), handler)
+
+		assert.Equal(t, "array (\n  0 => 'first',\n  1 => 'second',\n  2 => NULL,\n)", body)
+	}, opts)
+}
+
+func TestPublishFromPHP(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		s := frankenphp.Subscribe("from-php")
+		defer s.Unsubscribe()
+
+		assert.Equal(t, "1", fetchBody("GET", "http://example.com/pubsub.php?action=publish&topic=from-php&payload=hello", handler))
+
+		select {
+		case m := <-s.Messages():
+			assert.Equal(t, "hello", string(m))
+		case <-time.After(time.Second):
+			t.Fatal("the message published from PHP was not received")
+		}
+	}, &testOptions{nbParallelRequests: 1})
+}
+
+func TestPublishFromGo(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		url := "http://example.com/pubsub.php?action=poll&topic=from-go"
+
+		// the first call subscribes the worker thread to the topic
+		assert.Equal(t, "NULL", fetchBody("GET", url, handler))
+
+		assert.Equal(t, 1, frankenphp.Publish("from-go", []byte("hello")))
+		assert.Equal(t, "'hello'", fetchBody("GET", url, handler))
+		assert.Equal(t, "NULL", fetchBody("GET", url, handler))
+	}, &testOptions{workerScript: "pubsub.php", nbWorkers: 1, nbParallelRequests: 1})
+}
+
+func TestPublishDropsMessagesOfSlowSubscribers(t *testing.T) {
+	s := frankenphp.Subscribe("slow")
+	defer s.Unsubscribe()
+
+	delivered := 0
+	for i := 0; i < 300; i++ {
+		delivered += frankenphp.Publish("slow", []byte("message"))
+	}
+
+	assert.Equal(t, 256, delivered)
+	assert.Len(t, s.Messages(), 256)
+}
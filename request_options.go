@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/dunglas/frankenphp/internal/fastabs"
 )
@@ -132,3 +133,30 @@ func WithWorkerName(name string) RequestOption {
 		return nil
 	}
 }
+
+// WithRequestPriority sets the priority of the request when it has to wait for a thread.
+// Requests with a higher priority are served first, the default priority is 0.
+func WithRequestPriority(priority int) RequestOption {
+	return func(o *frankenPHPContext) error {
+		o.priority = priority
+
+		return nil
+	}
+}
+
+// WithRequestTimeout sets the maximum duration of the request, including the time spent in I/O.
+// Once exceeded, the script is interrupted with a catchable error. If it does not yield within the grace period,
+// the client receives a 504 status code. The thread cannot be reclaimed while the script is blocked:
+// it stays busy until the script yields, a worker script is then restarted.
+// A zero timeout uses the timeout set with WithDefaultRequestTimeout, a negative timeout disables it.
+func WithRequestTimeout(timeout time.Duration) RequestOption {
+	return func(o *frankenPHPContext) error {
+		o.timeout = nil
+		o.timeoutDisabled = timeout < 0
+		if timeout > 0 {
+			o.timeout = newRequestTimeout(timeout)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+package frankenphp
+
+import (
+	"slices"
+	"sync"
+)
+
+// after this many consecutive dispatches that skipped the oldest queued request
+// in favor of a higher priority one, the oldest request is dispatched first
+const maxPriorityStreak = 8
+
+// requestQueue orders the requests waiting for a thread
+// the request that is next in line is the only one allowed to be dispatched,
+// it is the oldest request with the highest priority
+type requestQueue struct {
+	mu       sync.Mutex
+	requests []*queuedRequest // in order of arrival
+	next     *queuedRequest
+	streak   int // number of consecutive dispatches that skipped the oldest request
+}
+
+type queuedRequest struct {
+	fc       *frankenPHPContext
+	promoted chan struct{} // closed once the request is next in line
+}
+
+// push adds a request to the queue
+func (q *requestQueue) push(fc *frankenPHPContext) *queuedRequest {
+	r := &queuedRequest{fc: fc, promoted: make(chan struct{})}
+
+	q.mu.Lock()
+	q.requests = append(q.requests, r)
+	if q.next == nil {
+		q.promoteNext()
+	}
+	q.mu.Unlock()
+
+	return r
+}
+
+// remove takes a request out of the queue once it was dispatched or has timed out
+func (q *requestQueue) remove(r *queuedRequest) {
+	q.mu.Lock()
+	q.requests = slices.DeleteFunc(q.requests, func(queued *queuedRequest) bool { return queued == r })
+	if q.next == r {
+		q.next = nil
+		q.promoteNext()
+	}
+	q.mu.Unlock()
+}
+
+// promoteNext selects the request that is next in line, must be called with mu locked
+func (q *requestQueue) promoteNext() {
+	if len(q.requests) == 0 {
+		return
+	}
+
+	oldest := q.requests[0]
+	next := oldest
+	// starvation guard: let the oldest request through if it was skipped too often
+	if q.streak < maxPriorityStreak {
+		for _, r := range q.requests {
+			if r.fc.priority > next.fc.priority {
+				next = r
+			}
+		}
+	}
+
+	if next == oldest {
+		q.streak = 0
+	} else {
+		q.streak++
+	}
+
+	q.next = next
+	close(next.promoted)
+}
+
+// dispatchChan returns the channel to dispatch the request to if it is next in line
+// otherwise it returns a channel that is closed once it is next in line
+func (r *queuedRequest) dispatchChan(requestChan chan *frankenPHPContext) (chan *frankenPHPContext, <-chan struct{}) {
+	select {
+	case <-r.promoted:
+		return requestChan, nil
+	default:
+		return nil, r.promoted
+	}
+}
@@ -0,0 +1,133 @@
+package frankenphp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestQueueServesHigherPrioritiesFirst(t *testing.T) {
+	q := &requestQueue{}
+	first := q.push(&frankenPHPContext{})
+	low := q.push(&frankenPHPContext{priority: -1})
+	regular := q.push(&frankenPHPContext{})
+	high := q.push(&frankenPHPContext{priority: 10})
+
+	// the first request was alone in the queue and is next in line
+	assertIsNextInLine(t, q, first)
+
+	q.remove(first)
+	assertIsNextInLine(t, q, high)
+
+	q.remove(high)
+	assertIsNextInLine(t, q, regular)
+
+	q.remove(regular)
+	assertIsNextInLine(t, q, low)
+
+	q.remove(low)
+	assert.Nil(t, q.next)
+}
+
+func TestRequestQueueDoesNotStarveLowPriorities(t *testing.T) {
+	q := &requestQueue{}
+	next := q.push(&frankenPHPContext{priority: 1})
+	low := q.push(&frankenPHPContext{})
+
+	// keep adding higher priority requests, the low priority request is eventually served
+	for i := 0; i < maxPriorityStreak; i++ {
+		q.push(&frankenPHPContext{priority: 1})
+		q.remove(next)
+		assert.NotSame(t, low, q.next)
+		next = q.next
+	}
+
+	q.push(&frankenPHPContext{priority: 1})
+	q.remove(next)
+	assertIsNextInLine(t, q, low)
+}
+
+func TestQueuedRequestIsOnlyDispatchedWhenNextInLine(t *testing.T) {
+	q := &requestQueue{}
+	requestChan := make(chan *frankenPHPContext)
+	first := q.push(&frankenPHPContext{})
+	second := q.push(&frankenPHPContext{})
+
+	dispatch, promoted := first.dispatchChan(requestChan)
+	assert.Equal(t, requestChan, dispatch)
+	assert.Nil(t, promoted)
+
+	dispatch, promoted = second.dispatchChan(requestChan)
+	assert.Nil(t, dispatch)
+	assert.NotNil(t, promoted)
+
+	q.remove(first)
+	<-promoted
+	dispatch, _ = second.dispatchChan(requestChan)
+	assert.Equal(t, requestChan, dispatch)
+}
+
+func TestWorkerDispatchesQueuedRequestsByPriority(t *testing.T) {
+	w := &worker{name: "priorities", requestChan: make(chan *frankenPHPContext), removed: make(chan struct{})}
+	first := queueWorkerRequest(t, w, 0)
+	low := queueWorkerRequest(t, w, -1)
+	regular := queueWorkerRequest(t, w, 0)
+	high := queueWorkerRequest(t, w, 10)
+	medium := queueWorkerRequest(t, w, 5)
+
+	assertDispatchOrder(t, w, first, high, medium, regular, low)
+}
+
+func TestWorkerDoesNotStarveLowPriorityRequests(t *testing.T) {
+	w := &worker{name: "starvation", requestChan: make(chan *frankenPHPContext), removed: make(chan struct{})}
+	first := queueWorkerRequest(t, w, 1)
+	low := queueWorkerRequest(t, w, 0)
+	high := make([]*frankenPHPContext, maxPriorityStreak+1)
+	for i := range high {
+		high[i] = queueWorkerRequest(t, w, 1)
+	}
+
+	// after maxPriorityStreak higher priority requests, the oldest request is let through
+	expected := append([]*frankenPHPContext{first}, high[:maxPriorityStreak]...)
+	expected = append(expected, low, high[maxPriorityStreak])
+	assertDispatchOrder(t, w, expected...)
+}
+
+// queueWorkerRequest makes a request wait for a thread of the worker, which has none yet
+func queueWorkerRequest(t *testing.T, w *worker, priority int) *frankenPHPContext {
+	t.Helper()
+
+	fc := &frankenPHPContext{priority: priority, done: make(chan interface{}), startedAt: time.Now()}
+	queued := w.queueLength.Load() + 1
+	go w.handleRequest(fc)
+	assert.Eventually(t, func() bool { return w.queueLength.Load() == queued }, time.Second, time.Millisecond)
+
+	return fc
+}
+
+// assertDispatchOrder acts as a thread of the worker that handles requests one at a time
+func assertDispatchOrder(t *testing.T, w *worker, expected ...*frankenPHPContext) {
+	t.Helper()
+
+	for i, fc := range expected {
+		select {
+		case dispatched := <-w.requestChan:
+			assert.Same(t, fc, dispatched, "request %d dispatched out of order", i)
+			close(dispatched.done)
+		case <-time.After(time.Second):
+			t.Fatalf("request %d was not dispatched", i)
+		}
+	}
+}
+
+func assertIsNextInLine(t *testing.T, q *requestQueue, r *queuedRequest) {
+	t.Helper()
+
+	assert.Same(t, r, q.next)
+	select {
+	case <-r.promoted:
+	default:
+		t.Error("the request should have been promoted")
+	}
+}
@@ -0,0 +1,301 @@
+package frankenphp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidRestartBatch   = errors.New(`restart batch must be a positive number of threads (example: 2) or a percentage (example: 25%)`)
+	ErrNotEnoughSpareThreads = errors.New("not enough spare threads to start worker threads, consider raising max_threads")
+
+	errWorkerNotBooted = errors.New("worker script has not reached frankenphp_handle_request()")
+	errThreadDetached  = errors.New("thread stopped or was assigned to another handler while restarting")
+
+	// received by idle worker threads, which reset the opcache from their request context
+	opcacheResets = make(chan chan struct{})
+	// how long a blue/green restart waits for an idle worker thread to reset the opcache
+	opcacheResetTimeout = 5 * time.Second
+)
+
+// RestartBatch limits how many threads of each worker are restarted at once during a rolling restart.
+// The zero value restarts all threads at once.
+type RestartBatch struct {
+	// Threads is the number of threads restarted at once
+	Threads int
+	// Percent is the percentage of the threads of a worker restarted at once, used if Threads is 0
+	Percent int
+}
+
+// ParseRestartBatch parses a number of threads ("2") or a percentage of threads ("25%")
+func ParseRestartBatch(s string) (RestartBatch, error) {
+	if p, ok := strings.CutSuffix(s, "%"); ok {
+		v, err := strconv.Atoi(p)
+		if err != nil || v <= 0 || v > 100 {
+			return RestartBatch{}, ErrInvalidRestartBatch
+		}
+
+		return RestartBatch{Percent: v}, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v <= 0 {
+		return RestartBatch{}, ErrInvalidRestartBatch
+	}
+
+	return RestartBatch{Threads: v}, nil
+}
+
+// size returns the number of threads to restart at once out of numThreads
+func (b RestartBatch) size(numThreads int) int {
+	size := numThreads
+	if b.Threads > 0 {
+		size = b.Threads
+	} else if b.Percent > 0 {
+		size = numThreads * b.Percent / 100
+	}
+
+	return max(size, 1)
+}
+
+// RollingRestartWorkers restarts all workers gracefully, one batch of threads per worker at a time.
+// The other threads keep serving requests, and each batch must reach frankenphp_handle_request()
+// before the next one is restarted. Boot failures are retried with exponential backoff,
+// the restart of a worker is aborted once a thread of the batch has failed max_consecutive_failures times in a row.
+func RollingRestartWorkers(batch RestartBatch) error {
+	// disallow scaling threads while restarting workers
+	scalingMu.Lock()
+	defer scalingMu.Unlock()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, w := range listWorkers() {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			if err := w.rollingRestart(batch); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (worker *worker) rollingRestart(batch RestartBatch) error {
+	worker.threadMutex.RLock()
+	threads := slices.Clone(worker.threads)
+	worker.threadMutex.RUnlock()
+
+	size := batch.size(len(threads))
+	for i := 0; i < len(threads); i += size {
+		if err := restartWorkerThreads(threads[i:min(i+size, len(threads))]); err != nil {
+			logger.LogAttrs(context.Background(), slog.LevelError, "rolling restart aborted", slog.String("worker", worker.name), slog.Int("restarted_threads", i), slog.Any("error", err))
+
+			return fmt.Errorf("rolling restart of worker %q aborted: %w", worker.name, err)
+		}
+	}
+
+	return nil
+}
+
+// restartWorkerThreads restarts the worker scripts of the given threads
+// and waits until all of them have reached frankenphp_handle_request() again
+func restartWorkerThreads(threads []*phpThread) error {
+	drainedThreads := drainThreads(threads)
+	results := make([]chan error, 0, len(drainedThreads))
+	for _, thread := range drainedThreads {
+		result := make(chan error, 1)
+		results = append(results, result)
+
+		// the thread is yielding, its handler can safely be modified
+		thread.handlerMu.Lock()
+		if handler, ok := thread.handler.(*workerThread); ok {
+			handler.bootResult = result
+		} else {
+			result <- errThreadDetached
+		}
+		thread.handlerMu.Unlock()
+
+		thread.drainChan = make(chan struct{})
+		thread.state.set(stateReady)
+	}
+
+	var errs []error
+	for _, result := range results {
+		if err := <-result; err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// BlueGreenRestartWorkers boots a new generation of threads for all workers from the spare threads (up to max_threads).
+// Once every thread of the new generation has reached frankenphp_handle_request(), requests are switched
+// to the new generation at once and the threads of the previous generation are drained and stopped.
+// If the new generation fails to boot, it is discarded and the previous generation keeps serving requests.
+func BlueGreenRestartWorkers() error {
+	// disallow scaling threads while restarting workers
+	scalingMu.Lock()
+	defer scalingMu.Unlock()
+
+	currentWorkers := listWorkers()
+	oldGeneration := make(map[*worker][]*phpThread, len(currentWorkers))
+	needed := 0
+	for _, w := range currentWorkers {
+		w.threadMutex.RLock()
+		oldGeneration[w] = slices.Clone(w.threads)
+		w.threadMutex.RUnlock()
+		needed += len(oldGeneration[w])
+	}
+
+	if spare := countSpareThreads(); spare < needed {
+		return fmt.Errorf("%w: %d needed, %d available", ErrNotEnoughSpareThreads, needed, spare)
+	}
+
+	// without resetting the opcache, the new generation would run the same bytecode when opcache.validate_timestamps is disabled
+	if needed > 0 && !resetOpcache() {
+		logger.LogAttrs(context.Background(), slog.LevelWarn, "no worker thread was idle to reset the opcache, booting the new generation without resetting it", slog.Duration("timeout", opcacheResetTimeout))
+	}
+
+	newGeneration := make(map[*worker][]*phpThread, len(currentWorkers))
+	standby := make(chan struct{})
+	results := make([]chan error, 0, needed)
+	for _, w := range currentWorkers {
+		for range oldGeneration[w] {
+			thread := getInactivePHPThread()
+			if thread == nil {
+				// a thread was started by another part of the server in the meantime
+				discardGeneration(newGeneration)
+
+				return ErrNotEnoughSpareThreads
+			}
+
+			result := make(chan error, 1)
+			results = append(results, result)
+			convertToStandbyWorkerThread(thread, w, result, standby)
+			newGeneration[w] = append(newGeneration[w], thread)
+		}
+	}
+
+	var errs []error
+	for _, result := range results {
+		if err := <-result; err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		discardGeneration(newGeneration)
+		logger.LogAttrs(context.Background(), slog.LevelError, "blue/green restart aborted, keeping the previous generation", slog.Any("error", errors.Join(errs...)))
+
+		return fmt.Errorf("blue/green restart aborted: %w", errors.Join(errs...))
+	}
+
+	// switch all workers to the new generation at once
+	for w, threads := range newGeneration {
+		for _, thread := range threads {
+			w.attachThread(thread)
+		}
+	}
+	close(standby)
+
+	var wg sync.WaitGroup
+	for w, threads := range oldGeneration {
+		for i, thread := range threads {
+			// the new thread takes the place of the old one in the autoscaled threads
+			if j := slices.Index(autoScaledThreads, thread); j >= 0 {
+				autoScaledThreads[j] = newGeneration[w][i]
+			}
+
+			wg.Add(1)
+			go func(thread *phpThread) {
+				defer wg.Done()
+				retireThread(thread)
+			}(thread)
+		}
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// resetOpcache lets an idle worker thread reset the opcache and waits until it is done,
+// scripts are compiled again by the requests started afterwards.
+// It returns false if no worker thread became idle within opcacheResetTimeout, e.g. because all of them
+// are handling long requests or are stuck in a crash loop.
+func resetOpcache() bool {
+	timer := time.NewTimer(opcacheResetTimeout)
+	defer timer.Stop()
+
+	done := make(chan struct{})
+	select {
+	case opcacheResets <- done:
+		<-done
+
+		return true
+	case <-timer.C:
+		return false
+	case <-mainThread.done:
+		return false
+	}
+}
+
+// countSpareThreads returns the number of threads that are not doing any work and can be converted
+func countSpareThreads() int {
+	spare := 0
+	for _, thread := range phpThreads {
+		if thread.state.is(stateReserved) || thread.state.is(stateInactive) {
+			spare++
+		}
+	}
+
+	return spare
+}
+
+// discardGeneration stops the threads of a new generation that failed to boot
+func discardGeneration(generation map[*worker][]*phpThread) {
+	for _, threads := range generation {
+		for _, thread := range threads {
+			retireThread(thread)
+		}
+	}
+}
+
+// retireThread drains a thread that no longer belongs to the current generation
+// and stops it in the same way as downscaling does
+func retireThread(thread *phpThread) {
+	if scaleDownMode == ScaleDownModeStop {
+		thread.shutdown()
+
+		return
+	}
+
+	convertToInactiveThread(thread)
+}
+
+func restartWorkersOnFileChanges() {
+	if watcherRestartBatch == (RestartBatch{}) {
+		RestartWorkers()
+
+		return
+	}
+
+	if err := RollingRestartWorkers(watcherRestartBatch); err != nil {
+		logger.LogAttrs(context.Background(), slog.LevelError, "failed to restart workers after file changes", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,42 @@
+package frankenphp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRestartBatch(t *testing.T) {
+	batch, err := ParseRestartBatch("2")
+	assert.NoError(t, err)
+	assert.Equal(t, RestartBatch{Threads: 2}, batch)
+
+	batch, err = ParseRestartBatch("25%")
+	assert.NoError(t, err)
+	assert.Equal(t, RestartBatch{Percent: 25}, batch)
+
+	for _, invalid := range []string{"", "0", "-1", "0%", "101%", "abc", "%"} {
+		_, err = ParseRestartBatch(invalid)
+		assert.ErrorIs(t, err, ErrInvalidRestartBatch, invalid)
+	}
+}
+
+func TestRestartBatchSize(t *testing.T) {
+	assert.Equal(t, 8, RestartBatch{}.size(8), "the zero value restarts all threads at once")
+	assert.Equal(t, 2, RestartBatch{Threads: 2}.size(8))
+	assert.Equal(t, 2, RestartBatch{Percent: 25}.size(8))
+	assert.Equal(t, 1, RestartBatch{Percent: 10}.size(3), "at least one thread is restarted")
+}
+
+func TestResetOpcacheDoesNotWaitForeverForAnIdleThread(t *testing.T) {
+	defer func(m *phpMainThread, timeout time.Duration) {
+		mainThread = m
+		opcacheResetTimeout = timeout
+	}(mainThread, opcacheResetTimeout)
+	mainThread = &phpMainThread{done: make(chan struct{})}
+	opcacheResetTimeout = 10 * time.Millisecond
+
+	// no worker thread receives the reset
+	assert.False(t, resetOpcache())
+}
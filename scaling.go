@@ -7,12 +7,12 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"slices"
 	"sync"
 	"time"
-
-	"github.com/dunglas/frankenphp/internal/cpu"
 )
 
+// default settings of the CPUScalingPolicy
 const (
 	// requests have to be stalled for at least this amount of time before scaling
 	minStallTime = 5 * time.Millisecond
@@ -28,27 +28,50 @@ const (
 	maxThreadIdleTime = 5 * time.Second
 )
 
+// ScaleDownMode defines what happens to autoscaled threads that have been idle for too long
+type ScaleDownMode string
+
+const (
+	// ScaleDownModeInactive keeps idle threads around as inactive threads, they consume memory but can be reactivated quickly
+	ScaleDownModeInactive ScaleDownMode = "inactive"
+	// ScaleDownModeStop completely stops idle threads and releases their memory
+	// Some PECL extensions like #1296 leak memory when threads stop, only use this mode without them
+	ScaleDownModeStop ScaleDownMode = "stop"
+)
+
 var (
 	ErrMaxThreadsReached = errors.New("max amount of overall threads reached")
 
 	scaleChan         chan *frankenPHPContext
 	autoScaledThreads = []*phpThread{}
 	scalingMu         = new(sync.RWMutex)
+	scalingPolicy     ScalingPolicy
+	scaleDownMode     ScaleDownMode
 )
 
-func initAutoScaling(mainThread *phpMainThread) {
-	if mainThread.maxThreads <= mainThread.numThreads {
+func initAutoScaling(mainThread *phpMainThread, policy ScalingPolicy, mode ScaleDownMode) {
+	if policy == nil {
+		policy = NewCPUScalingPolicy()
+	}
+	scalingPolicy = policy
+
+	if mode == "" {
+		mode = ScaleDownModeInactive
+	}
+	scaleDownMode = mode
+
+	// threads of workers started above their min_threads can be downscaled and started again later
+	if mainThread.maxThreads <= mainThread.numThreads && !slices.ContainsFunc(listWorkers(), (*worker).hasDownScalableThreads) {
 		scaleChan = nil
 		return
 	}
 
 	scalingMu.Lock()
 	scaleChan = make(chan *frankenPHPContext)
-	maxScaledThreads := mainThread.maxThreads - mainThread.numThreads
-	autoScaledThreads = make([]*phpThread, 0, maxScaledThreads)
+	autoScaledThreads = make([]*phpThread, 0, mainThread.maxThreads-mainThread.numThreads)
 	scalingMu.Unlock()
 
-	go startUpscalingThreads(maxScaledThreads, scaleChan, mainThread.done)
+	go startUpscalingThreads(scaleChan, mainThread.done)
 	go startDownScalingThreads(mainThread.done)
 }
 
@@ -87,8 +110,13 @@ func scaleWorkerThread(worker *worker) {
 		return
 	}
 
-	// probe CPU usage before scaling
-	if !cpu.ProbeCPUs(cpuProbeTime, maxCpuUsageForScaling, mainThread.done) {
+	// the worker might have been removed in the meantime
+	if worker.isRemoved() {
+		return
+	}
+
+	// do not scale over the max_threads of the worker
+	if !worker.canScale() {
 		return
 	}
 
@@ -110,11 +138,6 @@ func scaleRegularThread() {
 		return
 	}
 
-	// probe CPU usage before scaling
-	if !cpu.ProbeCPUs(cpuProbeTime, maxCpuUsageForScaling, mainThread.done) {
-		return
-	}
-
 	thread, err := addRegularThread()
 	if err != nil {
 		logger.LogAttrs(context.Background(), slog.LevelWarn, "could not increase max_threads, consider raising this limit", slog.Any("error", err))
@@ -124,40 +147,67 @@ func scaleRegularThread() {
 	autoScaledThreads = append(autoScaledThreads, thread)
 }
 
-func startUpscalingThreads(maxScaledThreads int, scale chan *frankenPHPContext, done chan struct{}) {
+// getThreadWorker returns the worker a thread is assigned to or nil if it is not a worker thread
+func getThreadWorker(thread *phpThread) *worker {
+	thread.handlerMu.Lock()
+	defer thread.handlerMu.Unlock()
+
+	if handler, ok := thread.handler.(*workerThread); ok {
+		return handler.worker
+	}
+
+	return nil
+}
+
+// canUpscale returns false if no thread can be added or if no request is waiting for a thread anymore
+func canUpscale(worker *worker, isWorkerRequest bool) bool {
+	if !mainThread.state.is(stateReady) {
+		return false
+	}
+
+	if !isWorkerRequest {
+		return regularQueueLength.Load() > 0
+	}
+
+	return !worker.isRemoved() && worker.canScale() && worker.queueLength.Load() > 0
+}
+
+func startUpscalingThreads(scale chan *frankenPHPContext, done chan struct{}) {
 	for {
 		scalingMu.Lock()
-		scaledThreadCount := len(autoScaledThreads)
+		spareThreadCount := countSpareThreads()
 		scalingMu.Unlock()
-		if scaledThreadCount >= maxScaledThreads {
+		if spareThreadCount == 0 {
 			// we have reached max_threads, check again later
 			select {
 			case <-done:
 				return
-			case <-time.After(downScaleCheckTime):
+			case <-time.After(scalingPolicy.DownscaleInterval()):
 				continue
 			}
 		}
 
 		select {
 		case fc := <-scale:
-			timeSinceStalled := time.Since(fc.startedAt)
-
-			// if the request has not been stalled long enough, wait and repeat
-			if timeSinceStalled < minStallTime {
-				select {
-				case <-done:
-					return
-				case <-time.After(minStallTime - timeSinceStalled):
-					continue
-				}
+			worker, isWorkerRequest := getWorker(getWorkerKey(fc.workerName, fc.scriptFilename))
+			workerName := ""
+			if isWorkerRequest {
+				workerName = worker.name
 			}
 
-			// if the request has been stalled long enough, scale
-			if worker, ok := workers[getWorkerKey(fc.workerName, fc.scriptFilename)]; ok {
-				scaleWorkerThread(worker)
-			} else {
-				scaleRegularThread()
+			// the scaling policy may block to probe CPU usage, check the cheap conditions first
+			if !canUpscale(worker, isWorkerRequest) {
+				continue
+			}
+
+			// let the scaling policy decide how many threads to add
+			numThreads := scalingPolicy.Upscale(workerName, time.Since(fc.startedAt), done)
+			for i := 0; i < numThreads; i++ {
+				if isWorkerRequest {
+					scaleWorkerThread(worker)
+				} else {
+					scaleRegularThread()
+				}
 			}
 		case <-done:
 			return
@@ -170,50 +220,82 @@ func startDownScalingThreads(done chan struct{}) {
 		select {
 		case <-done:
 			return
-		case <-time.After(downScaleCheckTime):
+		case <-time.After(scalingPolicy.DownscaleInterval()):
 			deactivateThreads()
 		}
 	}
 }
 
+// downScalableThreads returns the autoscaled threads and the threads of workers started above their min_threads
+func downScalableThreads() []*phpThread {
+	threads := slices.Clone(autoScaledThreads)
+	for _, worker := range listWorkers() {
+		if !worker.hasDownScalableThreads() {
+			continue
+		}
+
+		worker.threadMutex.RLock()
+		for _, thread := range worker.threads {
+			if !slices.Contains(threads, thread) {
+				threads = append(threads, thread)
+			}
+		}
+		worker.threadMutex.RUnlock()
+	}
+
+	return threads
+}
+
 // deactivateThreads checks all threads and removes those that have been inactive for too long
 func deactivateThreads() {
-	stoppedThreadCount := 0
 	scalingMu.Lock()
 	defer scalingMu.Unlock()
-	for i := len(autoScaledThreads) - 1; i >= 0; i-- {
-		thread := autoScaledThreads[i]
 
-		// the thread might have been stopped otherwise, remove it
-		if thread.state.is(stateReserved) {
-			autoScaledThreads = append(autoScaledThreads[:i], autoScaledThreads[i+1:]...)
+	// the threads might have been stopped otherwise, remove them
+	autoScaledThreads = slices.DeleteFunc(autoScaledThreads, func(t *phpThread) bool { return t.state.is(stateReserved) })
+
+	idleThreads := make([]*phpThread, 0, len(autoScaledThreads))
+	for _, thread := range downScalableThreads() {
+		if !thread.state.is(stateReady) || thread.state.waitTime() == 0 {
 			continue
 		}
 
-		waitTime := thread.state.waitTime()
-		if stoppedThreadCount > maxTerminationCount || waitTime == 0 {
+		// do not downscale workers below their min_threads
+		if worker := getThreadWorker(thread); worker != nil && !worker.canDownScale() {
 			continue
 		}
 
-		// convert threads to inactive if they have been idle for too long
-		if thread.state.is(stateReady) && waitTime > maxThreadIdleTime.Milliseconds() {
-			logger.LogAttrs(context.Background(), slog.LevelDebug, "auto-converting thread to inactive", slog.Int("threadIndex", thread.threadIndex))
-			convertToInactiveThread(thread)
-			stoppedThreadCount++
-			autoScaledThreads = append(autoScaledThreads[:i], autoScaledThreads[i+1:]...)
+		idleThreads = append(idleThreads, thread)
+	}
 
+	if len(idleThreads) == 0 {
+		return
+	}
+
+	// let the scaling policy decide how many threads to stop, longest idle first
+	slices.SortStableFunc(idleThreads, func(a, b *phpThread) int {
+		return int(b.state.waitTime() - a.state.waitTime())
+	})
+	idleTimes := make([]time.Duration, len(idleThreads))
+	for i, thread := range idleThreads {
+		idleTimes[i] = time.Duration(thread.state.waitTime()) * time.Millisecond
+	}
+
+	numThreads := min(scalingPolicy.Downscale(idleTimes), len(idleThreads))
+	for _, thread := range idleThreads[:numThreads] {
+		// the worker might have reached its min_threads in the meantime
+		if worker := getThreadWorker(thread); worker != nil && !worker.canDownScale() {
 			continue
 		}
 
-		// TODO: Completely stopping threads is more memory efficient
-		// Some PECL extensions like #1296 will prevent threads from fully stopping (they leak memory)
-		// Reactivate this if there is a better solution or workaround
-		// if thread.state.is(stateInactive) && waitTime > maxThreadIdleTime.Milliseconds() {
-		// 	logger.LogAttrs(nil, slog.LevelDebug, "auto-stopping thread", slog.Int("threadIndex", thread.threadIndex))
-		// 	thread.shutdown()
-		// 	stoppedThreadCount++
-		// 	autoScaledThreads = append(autoScaledThreads[:i], autoScaledThreads[i+1:]...)
-		// 	continue
-		// }
+		// stop threads completely, they go back to the reserved state and can be booted again on upscale
+		if scaleDownMode == ScaleDownModeStop {
+			logger.LogAttrs(context.Background(), slog.LevelDebug, "auto-stopping thread", slog.Int("threadIndex", thread.threadIndex))
+			thread.shutdown()
+		} else {
+			logger.LogAttrs(context.Background(), slog.LevelDebug, "auto-converting thread to inactive", slog.Int("threadIndex", thread.threadIndex))
+			convertToInactiveThread(thread)
+		}
+		autoScaledThreads = slices.DeleteFunc(autoScaledThreads, func(t *phpThread) bool { return t == thread })
 	}
 }
@@ -55,6 +55,116 @@ func TestScaleAWorkerThreadUpAndDown(t *testing.T) {
 	Shutdown()
 }
 
+func TestScaleARegularThreadUpAndStopIt(t *testing.T) {
+	assert.NoError(t, Init(
+		WithNumThreads(1),
+		WithMaxThreads(2),
+		WithScaleDownMode(ScaleDownModeStop),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	))
+
+	autoScaledThread := phpThreads[1]
+
+	// scale up
+	scaleRegularThread()
+	assert.Equal(t, stateReady, autoScaledThread.state.get())
+
+	// on down-scale, the thread will be stopped and go back to the reserved state
+	setLongWaitTime(autoScaledThread)
+	deactivateThreads()
+	assert.Equal(t, stateReserved, autoScaledThread.state.get())
+
+	// the thread can be booted again on the next up-scale
+	scaleRegularThread()
+	assert.Equal(t, stateReady, autoScaledThread.state.get())
+	assert.IsType(t, &regularThread{}, autoScaledThread.handler)
+
+	Shutdown()
+}
+
+func TestDoNotScaleAWorkerOverItsMaxThreads(t *testing.T) {
+	workerName := "worker1"
+	workerPath := testDataPath + "/transition-worker-1.php"
+	assert.NoError(t, Init(
+		WithNumThreads(2),
+		WithMaxThreads(3),
+		WithWorkers(workerName, workerPath, 1, map[string]string{}, []string{}, WithWorkerMaxThreads(1)),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	))
+
+	// the worker already has 1 thread, scaling must be skipped
+	scaleWorkerThread(workers[workerPath])
+	assert.Equal(t, 1, workers[workerPath].countThreads())
+	assert.Equal(t, stateReserved, phpThreads[2].state.get())
+
+	Shutdown()
+}
+
+func TestDownscaleAWorkerToItsMinThreads(t *testing.T) {
+	workerName := "worker1"
+	workerPath := testDataPath + "/transition-worker-1.php"
+	assert.NoError(t, Init(
+		WithNumThreads(3),
+		WithWorkers(workerName, workerPath, 2, map[string]string{}, []string{}, WithWorkerMinThreads(1)),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	))
+
+	// the threads started on boot can be downscaled until min_threads is reached
+	w := workers[workerPath]
+	for _, thread := range phpThreads {
+		setLongWaitTime(thread)
+	}
+	deactivateThreads()
+	assert.Equal(t, 1, w.countThreads())
+
+	deactivateThreads()
+	assert.Equal(t, 1, w.countThreads())
+
+	// the downscaled thread can be used again
+	scaleWorkerThread(w)
+	assert.Equal(t, 2, w.countThreads())
+
+	Shutdown()
+}
+
+func TestCPUScalingPolicyDownscalesThreadsIdleForTooLong(t *testing.T) {
+	policy := NewCPUScalingPolicy()
+	policy.MaxIdleTime = time.Second
+	policy.MaxDownscaleCount = 2
+
+	assert.Equal(t, 0, policy.Downscale([]time.Duration{}))
+	assert.Equal(t, 1, policy.Downscale([]time.Duration{2 * time.Second, time.Millisecond}))
+	assert.Equal(t, 2, policy.Downscale([]time.Duration{3 * time.Second, 2 * time.Second, 2 * time.Second}))
+}
+
+type fixedScalingPolicy struct {
+	downscaleCount int
+}
+
+func (p fixedScalingPolicy) Upscale(string, time.Duration, <-chan struct{}) int { return 0 }
+func (p fixedScalingPolicy) DownscaleInterval() time.Duration                   { return time.Hour }
+func (p fixedScalingPolicy) Downscale([]time.Duration) int                      { return p.downscaleCount }
+
+func TestCustomScalingPolicyDecidesOnDownscaling(t *testing.T) {
+	assert.NoError(t, Init(
+		WithNumThreads(1),
+		WithMaxThreads(2),
+		WithScalingPolicy(fixedScalingPolicy{downscaleCount: 0}),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	))
+
+	autoScaledThread := phpThreads[1]
+	scaleRegularThread()
+	assert.IsType(t, &regularThread{}, autoScaledThread.handler)
+
+	// the policy refuses to downscale even though the thread has been idle for long
+	setLongWaitTime(autoScaledThread)
+	deactivateThreads()
+	assert.IsType(t, &regularThread{}, autoScaledThread.handler)
+
+	Shutdown()
+}
+
 func setLongWaitTime(thread *phpThread) {
 	thread.state.mu.Lock()
 	thread.state.waitingSince = time.Now().Add(-time.Hour)
@@ -0,0 +1,88 @@
+package frankenphp
+
+import (
+	"time"
+
+	"github.com/dunglas/frankenphp/internal/cpu"
+)
+
+// ScalingPolicy decides when threads are started at runtime (up to max_threads) and when they are stopped again.
+type ScalingPolicy interface {
+	// Upscale is called with a request that has been stalled waiting for a thread.
+	// workerName is empty if the request is handled by regular threads.
+	// It returns the number of threads to add, 0 to not scale.
+	// It may block (for example to probe CPU usage) but must return once done is closed.
+	Upscale(workerName string, stallTime time.Duration, done <-chan struct{}) int
+	// DownscaleInterval returns how often autoscaled threads are checked for downscaling.
+	DownscaleInterval() time.Duration
+	// Downscale is called every DownscaleInterval with the idle time of all idle autoscaled threads, longest first.
+	// It returns the number of threads to stop, starting with the ones that have been idle the longest.
+	Downscale(idleTimes []time.Duration) int
+}
+
+// CPUScalingPolicy is the default ScalingPolicy.
+// It only scales up if the CPUs are not already busy, since this means most threads are waiting for I/O.
+type CPUScalingPolicy struct {
+	// MinStallTime is the time requests have to be stalled before scaling
+	MinStallTime time.Duration
+	// CPUProbeTime is the time during which CPU usage is probed before scaling
+	CPUProbeTime time.Duration
+	// MaxCPUUsage is the CPU usage (between 0 and 1) over which no threads are added
+	MaxCPUUsage float64
+	// UpscaleCount is the number of threads added at once
+	UpscaleCount int
+	// DownscaleCheckTime is the interval at which idle threads are checked
+	DownscaleCheckTime time.Duration
+	// MaxIdleTime is the time after which an idle autoscaled thread is stopped
+	MaxIdleTime time.Duration
+	// MaxDownscaleCount is the max amount of threads stopped in one check
+	MaxDownscaleCount int
+}
+
+// NewCPUScalingPolicy returns the default ScalingPolicy with its default settings.
+func NewCPUScalingPolicy() *CPUScalingPolicy {
+	return &CPUScalingPolicy{
+		MinStallTime:       minStallTime,
+		CPUProbeTime:       cpuProbeTime,
+		MaxCPUUsage:        maxCpuUsageForScaling,
+		UpscaleCount:       1,
+		DownscaleCheckTime: downScaleCheckTime,
+		MaxIdleTime:        maxThreadIdleTime,
+		MaxDownscaleCount:  maxTerminationCount,
+	}
+}
+
+func (p *CPUScalingPolicy) Upscale(_ string, stallTime time.Duration, done <-chan struct{}) int {
+	// if the request has not been stalled long enough, wait and repeat
+	if stallTime < p.MinStallTime {
+		select {
+		case <-done:
+		case <-time.After(p.MinStallTime - stallTime):
+		}
+
+		return 0
+	}
+
+	// probe CPU usage before scaling
+	if !cpu.ProbeCPUs(p.CPUProbeTime, p.MaxCPUUsage, done) {
+		return 0
+	}
+
+	return p.UpscaleCount
+}
+
+func (p *CPUScalingPolicy) DownscaleInterval() time.Duration {
+	return p.DownscaleCheckTime
+}
+
+func (p *CPUScalingPolicy) Downscale(idleTimes []time.Duration) int {
+	count := 0
+	for _, idleTime := range idleTimes {
+		if count >= p.MaxDownscaleCount || idleTime <= p.MaxIdleTime {
+			break
+		}
+		count++
+	}
+
+	return count
+}
@@ -0,0 +1,323 @@
+package frankenphp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dunglas/frankenphp/internal/cron"
+	"github.com/dunglas/frankenphp/internal/fastabs"
+)
+
+// maxScheduledJobOutput is the maximum number of bytes of the output of a scheduled job that are logged
+const maxScheduledJobOutput = 64 * 1024
+
+// ScheduledJob is a PHP script executed periodically on a spare PHP thread.
+type ScheduledJob struct {
+	// Name of the job, used in logs and metrics
+	Name string
+	// Schedule is a cron expression, e.g. "*/5 * * * *" or "@hourly"
+	Schedule string
+	// Script is the path of the PHP script to execute
+	Script string
+	// Args are exposed to the script as $argv, after the path of the script
+	Args []string
+	// Env contains extra environment variables exposed to the script
+	Env map[string]string
+}
+
+// scheduledJob is a validated ScheduledJob
+type scheduledJob struct {
+	name     string
+	schedule *cron.Schedule
+	fileName string
+	argv     []string
+	env      PreparedEnv
+	// true while a run of the job is in progress, overlapping runs are skipped
+	running atomic.Bool
+}
+
+var (
+	scheduledJobsDone chan struct{}
+	scheduledJobsWG   sync.WaitGroup
+)
+
+func newScheduledJob(job ScheduledJob) (*scheduledJob, error) {
+	if job.Name == "" {
+		return nil, fmt.Errorf("scheduled jobs must have a name")
+	}
+
+	schedule, err := cron.Parse(job.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("scheduled job %q: %w", job.Name, err)
+	}
+
+	fileName, err := fastabs.FastAbs(job.Script)
+	if err != nil {
+		return nil, fmt.Errorf("scheduled job %q: script filename is invalid %q: %w", job.Name, job.Script, err)
+	}
+
+	return &scheduledJob{
+		name:     job.Name,
+		schedule: schedule,
+		fileName: fileName,
+		argv:     append([]string{fileName}, job.Args...),
+		env:      PrepareEnv(job.Env),
+	}, nil
+}
+
+// startScheduledJobs runs each job on its schedule until the scheduled jobs are drained
+func startScheduledJobs(mainThread *phpMainThread, jobs []*scheduledJob) {
+	if len(jobs) == 0 {
+		return
+	}
+
+	if mainThread.maxThreads <= mainThread.numThreads {
+		logger.Warn("scheduled jobs only run on spare threads, set max_threads higher than num_threads to make sure they can run")
+	}
+
+	scheduledJobsDone = make(chan struct{})
+	for _, job := range jobs {
+		scheduledJobsWG.Add(1)
+		go func() {
+			defer scheduledJobsWG.Done()
+			job.loop(scheduledJobsDone)
+		}()
+	}
+}
+
+// drainScheduledJobs stops triggering new runs, runs in progress are stopped with the PHP threads
+func drainScheduledJobs() {
+	if scheduledJobsDone == nil {
+		return
+	}
+
+	close(scheduledJobsDone)
+	scheduledJobsWG.Wait()
+	scheduledJobsDone = nil
+}
+
+func (job *scheduledJob) loop(done chan struct{}) {
+	for {
+		next := job.schedule.Next(time.Now())
+		if next.IsZero() {
+			logger.LogAttrs(context.Background(), slog.LevelWarn, "scheduled job will never run", slog.String("job", job.name))
+
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-done:
+			timer.Stop()
+
+			return
+		case <-timer.C:
+			job.trigger()
+		}
+	}
+}
+
+// trigger starts a run of the job on an inactive thread, threads of workers are never used
+func (job *scheduledJob) trigger() {
+	ctx := context.Background()
+
+	if !job.running.CompareAndSwap(false, true) {
+		metrics.SkippedScheduledJob(job.name)
+		logger.LogAttrs(ctx, slog.LevelWarn, "previous run of the scheduled job is still in progress, skipping", slog.String("job", job.name))
+
+		return
+	}
+
+	fc, err := job.newContext()
+	if err != nil {
+		job.running.Store(false)
+		metrics.SkippedScheduledJob(job.name)
+		logger.LogAttrs(ctx, slog.LevelError, "unable to create the context of the scheduled job", slog.String("job", job.name), slog.Any("error", err))
+
+		return
+	}
+
+	scalingMu.Lock()
+	defer scalingMu.Unlock()
+
+	thread := getInactivePHPThread()
+	if thread == nil {
+		job.running.Store(false)
+		metrics.SkippedScheduledJob(job.name)
+		logger.LogAttrs(ctx, slog.LevelWarn, "no spare thread available to run the scheduled job, skipping", slog.String("job", job.name))
+
+		return
+	}
+
+	convertToScheduledThread(thread, job, fc)
+}
+
+// newContext creates the context of a run, in the same way as the dummy context of the worker
+func (job *scheduledJob) newContext() (*frankenPHPContext, error) {
+	fc, err := newDummyContext(
+		filepath.Base(job.fileName),
+		WithRequestDocumentRoot(filepath.Dir(job.fileName), false),
+		WithRequestPreparedEnv(job.env),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	fc.argv = job.argv
+	fc.responseWriter = &scheduledJobOutput{header: make(http.Header)}
+
+	return fc, nil
+}
+
+// representation of a thread running a single scheduled job, the thread goes back to inactive afterward
+// implements the threadHandler interface
+type scheduledThread struct {
+	thread         *phpThread
+	job            *scheduledJob
+	requestContext *frankenPHPContext
+	startedAt      time.Time
+}
+
+func convertToScheduledThread(thread *phpThread, job *scheduledJob, fc *frankenPHPContext) {
+	thread.setHandler(&scheduledThread{
+		thread:         thread,
+		job:            job,
+		requestContext: fc,
+	})
+}
+
+func (handler *scheduledThread) beforeScriptExecution() string {
+	thread := handler.thread
+
+	switch thread.state.get() {
+	case stateTransitionRequested:
+		return thread.transitionToNewHandler()
+	case stateTransitionComplete:
+		thread.state.set(stateReady)
+
+		return handler.startJob()
+	case stateReady:
+		// the job has run, go back to inactive unless the thread is shutting down in the meantime
+		if thread.state.compareAndSwap(stateReady, stateTransitionComplete) {
+			thread.handlerMu.Lock()
+			thread.handler = &inactiveThread{thread: thread}
+			thread.handlerMu.Unlock()
+
+			return thread.handler.beforeScriptExecution()
+		}
+
+		return handler.beforeScriptExecution()
+	case stateShuttingDown:
+		if handler.requestContext != nil {
+			// the job never started
+			handler.requestContext.closeContext()
+			handler.requestContext = nil
+			handler.job.running.Store(false)
+		}
+
+		// signal to stop
+		return ""
+	}
+	panic("unexpected state: " + thread.state.name())
+}
+
+func (handler *scheduledThread) startJob() string {
+	fc := handler.requestContext
+	job := handler.job
+
+	if err := updateServerContext(handler.thread, fc, false); err != nil {
+		logger.LogAttrs(context.Background(), slog.LevelError, "unable to start the scheduled job", slog.String("job", job.name), slog.Any("error", err))
+		fc.closeContext()
+		handler.requestContext = nil
+		job.running.Store(false)
+		metrics.SkippedScheduledJob(job.name)
+		handler.thread.Unpin()
+
+		return handler.beforeScriptExecution()
+	}
+
+	logger.LogAttrs(context.Background(), slog.LevelDebug, "starting scheduled job", slog.String("job", job.name), slog.Int("thread", handler.thread.threadIndex))
+	metrics.StartScheduledJob(job.name)
+	handler.startedAt = time.Now()
+
+	return fc.scriptFilename
+}
+
+func (handler *scheduledThread) afterScriptExecution(exitStatus int) {
+	fc := handler.requestContext
+	job := handler.job
+	output := fc.responseWriter.(*scheduledJobOutput)
+
+	fc.closeContext()
+	clearSandboxedEnv(handler.thread)
+	handler.requestContext = nil
+
+	duration := time.Since(handler.startedAt)
+
+	attrs := []slog.Attr{
+		slog.String("job", job.name),
+		slog.Int("thread", handler.thread.threadIndex),
+		slog.Int("exit_status", exitStatus),
+		slog.Duration("duration", duration),
+		slog.String("output", output.String()),
+	}
+
+	if exitStatus == 0 {
+		metrics.StopScheduledJob(job.name, duration, true)
+		logger.LogAttrs(context.Background(), slog.LevelInfo, "scheduled job finished", attrs...)
+	} else {
+		metrics.StopScheduledJob(job.name, duration, false)
+		logger.LogAttrs(context.Background(), slog.LevelError, "scheduled job failed", attrs...)
+	}
+
+	job.running.Store(false)
+}
+
+func (handler *scheduledThread) getRequestContext() *frankenPHPContext {
+	return handler.requestContext
+}
+
+func (handler *scheduledThread) name() string {
+	return "Scheduled PHP Thread - " + handler.job.name
+}
+
+// scheduledJobOutput collects the output of a scheduled job to log it once the job is finished
+type scheduledJobOutput struct {
+	header    http.Header
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (w *scheduledJobOutput) Header() http.Header {
+	return w.header
+}
+
+func (w *scheduledJobOutput) Write(b []byte) (int, error) {
+	if remaining := maxScheduledJobOutput - w.buf.Len(); len(b) > remaining {
+		w.buf.Write(b[:remaining])
+		w.truncated = true
+
+		return len(b), nil
+	}
+
+	return w.buf.Write(b)
+}
+
+func (w *scheduledJobOutput) WriteHeader(int) {}
+
+func (w *scheduledJobOutput) Flush() {}
+
+func (w *scheduledJobOutput) String() string {
+	if w.truncated {
+		return w.buf.String() + "... (truncated)"
+	}
+
+	return w.buf.String()
+}
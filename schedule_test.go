@@ -0,0 +1,87 @@
+package frankenphp
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestScheduledJob(t *testing.T, args ...string) (*scheduledJob, string) {
+	outputFile := filepath.Join(t.TempDir(), "output")
+	job, err := newScheduledJob(ScheduledJob{
+		Name:     "test-job",
+		Schedule: "@yearly",
+		Script:   testDataPath + "/scheduled-job.php",
+		Args:     args,
+		Env:      map[string]string{"OUTPUT_FILE": outputFile},
+	})
+	require.NoError(t, err)
+
+	return job, outputFile
+}
+
+func TestScheduledJobRunsOnAnInactiveThread(t *testing.T) {
+	logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	_, err := initPHPThreads(1, 1, nil)
+	require.NoError(t, err)
+
+	job, outputFile := newTestScheduledJob(t, "foo", "bar")
+	job.trigger()
+
+	assert.Eventually(t, func() bool { return !job.running.Load() }, 5*time.Second, time.Millisecond)
+	assert.Eventually(t, func() bool { return phpThreads[0].state.is(stateInactive) }, 5*time.Second, time.Millisecond)
+	assert.IsType(t, &inactiveThread{}, phpThreads[0].handler)
+
+	output, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, job.fileName+",foo,bar\n3", string(output))
+
+	drainPHPThreads()
+}
+
+func TestScheduledJobIsSkippedWithoutSpareThread(t *testing.T) {
+	logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	_, err := initPHPThreads(1, 1, nil)
+	require.NoError(t, err)
+	convertToRegularThread(phpThreads[0])
+
+	job, outputFile := newTestScheduledJob(t)
+	job.trigger()
+
+	assert.False(t, job.running.Load())
+	assert.IsType(t, &regularThread{}, phpThreads[0].handler)
+	assert.NoFileExists(t, outputFile)
+
+	drainPHPThreads()
+}
+
+func TestScheduledJobRunsDoNotOverlap(t *testing.T) {
+	logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	_, err := initPHPThreads(1, 1, nil)
+	require.NoError(t, err)
+
+	job, outputFile := newTestScheduledJob(t)
+	job.running.Store(true)
+	job.trigger()
+
+	assert.IsType(t, &inactiveThread{}, phpThreads[0].handler)
+	assert.NoFileExists(t, outputFile)
+
+	drainPHPThreads()
+}
+
+func TestInvalidScheduledJobs(t *testing.T) {
+	o := &opt{}
+
+	assert.ErrorContains(t, WithScheduledJob(ScheduledJob{Name: "job", Schedule: "* * *", Script: "job.php"})(o), "invalid cron expression")
+	assert.ErrorContains(t, WithScheduledJob(ScheduledJob{Schedule: "@daily", Script: "job.php"})(o), "must have a name")
+
+	require.NoError(t, WithScheduledJob(ScheduledJob{Name: "job", Schedule: "@daily", Script: "job.php"})(o))
+	assert.ErrorContains(t, WithScheduledJob(ScheduledJob{Name: "job", Schedule: "@hourly", Script: "other.php"})(o), "same name")
+}
@@ -0,0 +1,217 @@
+package frankenphp
+
+// #include "frankenphp.h"
+import "C"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// ErrSendfileForbidden is returned when a file passed to frankenphp_sendfile() is not in one of the allowed root directories
+var ErrSendfileForbidden = errors.New("file not in an allowed root directory")
+
+// sendfileRoot is a directory frankenphp_sendfile() can serve files from
+type sendfileRoot struct {
+	// absolute path of the directory, with its symlinks resolved
+	path string
+	// files are opened through the root, they cannot escape the directory even if a symlink is swapped meanwhile
+	root *os.Root
+}
+
+// sendfileRoots are the directories frankenphp_sendfile() can serve files from
+var sendfileRoots []sendfileRoot
+
+// sendfile is a file sent by Go once the PHP script has called frankenphp_sendfile()
+type sendfile struct {
+	path        string
+	filename    string
+	disposition string
+	contentType string
+}
+
+// resolveSendfileRoot returns the absolute path of a root directory, with its symlinks resolved
+func resolveSendfileRoot(root string) (string, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%q is not a directory", root)
+	}
+
+	return resolved, nil
+}
+
+// initSendfileRoots opens the root directories, paths must have been resolved with resolveSendfileRoot
+func initSendfileRoots(paths []string) error {
+	roots := make([]sendfileRoot, 0, len(paths))
+	for _, path := range paths {
+		root, err := os.OpenRoot(path)
+		if err != nil {
+			for _, r := range roots {
+				_ = r.root.Close()
+			}
+
+			return fmt.Errorf("unable to open the sendfile root %q: %w", path, err)
+		}
+
+		roots = append(roots, sendfileRoot{path: path, root: root})
+	}
+	sendfileRoots = roots
+
+	return nil
+}
+
+func drainSendfileRoots() {
+	for _, r := range sendfileRoots {
+		_ = r.root.Close()
+	}
+
+	sendfileRoots = nil
+}
+
+// findSendfileRoot returns the root directory containing the file and the path of the file relative to it.
+// The symlinks of the path are only resolved to find the root, the file must then be opened through it.
+func findSendfileRoot(path string) (*os.Root, string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, r := range sendfileRoots {
+		if rel, err := filepath.Rel(r.path, resolved); err == nil && filepath.IsLocal(rel) {
+			return r.root, rel, nil
+		}
+	}
+
+	return nil, "", ErrSendfileForbidden
+}
+
+// openSendfile opens a regular file in an allowed root directory
+func openSendfile(path string) (*os.File, fs.FileInfo, error) {
+	root, rel, err := findSendfileRoot(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := root.Open(rel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := f.Stat()
+	if err == nil && !info.Mode().IsRegular() {
+		err = fmt.Errorf("%q is not a regular file: %w", path, fs.ErrNotExist)
+	}
+	if err != nil {
+		_ = f.Close()
+
+		return nil, nil, err
+	}
+
+	return f, info, nil
+}
+
+// serveFile sends the file passed to frankenphp_sendfile() once PHP has handled the request, the PHP thread is already released.
+// Range and conditional requests are handled by http.ServeContent.
+func (fc *frankenPHPContext) serveFile() {
+	sf := fc.sendfile
+	if sf == nil {
+		return
+	}
+
+	select {
+	case <-fc.done:
+	default:
+		// the request has been abandoned after its timeout
+		return
+	}
+
+	// the file is checked again since it may have changed once the thread has been released
+	f, info, err := openSendfile(sf.path)
+	if err != nil {
+		fc.logger.LogAttrs(context.Background(), slog.LevelError, "unable to send the file", slog.String("path", sf.path), slog.Any("error", err))
+		http.Error(fc.responseWriter, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+
+		return
+	}
+	defer f.Close()
+
+	h := fc.responseWriter.Header()
+	if sf.contentType != "" {
+		h.Set("Content-Type", sf.contentType)
+	} else if h.Get("Content-Type") == "" {
+		// http.ServeContent sniffs the content type if the extension is unknown
+		if ct := mime.TypeByExtension(filepath.Ext(sf.filename)); ct != "" {
+			h.Set("Content-Type", ct)
+		}
+	}
+
+	if cd := mime.FormatMediaType(sf.disposition, map[string]string{"filename": sf.filename}); cd != "" {
+		h.Set("Content-Disposition", cd)
+	} else {
+		h.Set("Content-Disposition", sf.disposition)
+	}
+
+	if h.Get("ETag") == "" {
+		h.Set("ETag", fmt.Sprintf(`"%x%x"`, info.ModTime().UnixNano(), info.Size()))
+	}
+
+	http.ServeContent(fc.responseWriter, fc.request, sf.filename, info.ModTime(), f)
+}
+
+// go_frankenphp_sendfile checks the file and marks the response as sent by Go, the PHP side of the request is then closed
+//
+//export go_frankenphp_sendfile
+func go_frankenphp_sendfile(threadIndex C.uintptr_t, path *C.char, pathLen C.size_t, filename *C.char, filenameLen C.size_t, disposition *C.char, dispositionLen C.size_t, contentType *C.char, contentTypeLen C.size_t) C.int {
+	fc := phpThreads[threadIndex].getRequestContext()
+	if fc == nil || fc.responseWriter == nil || fc.isDone || fc.webSocketEvent != nil {
+		return C.FRANKENPHP_SENDFILE_UNAVAILABLE
+	}
+
+	sf := &sendfile{
+		path:        C.GoStringN(path, C.int(pathLen)),
+		filename:    C.GoStringN(filename, C.int(filenameLen)),
+		disposition: C.GoStringN(disposition, C.int(dispositionLen)),
+		contentType: C.GoStringN(contentType, C.int(contentTypeLen)),
+	}
+
+	f, _, err := openSendfile(sf.path)
+	switch {
+	case errors.Is(err, ErrSendfileForbidden):
+		return C.FRANKENPHP_SENDFILE_FORBIDDEN
+	case err != nil:
+		fc.logger.LogAttrs(context.Background(), slog.LevelDebug, "unable to open the file to send", slog.String("path", sf.path), slog.Any("error", err))
+
+		return C.FRANKENPHP_SENDFILE_NOT_FOUND
+	}
+	_ = f.Close()
+
+	if sf.filename == "" {
+		sf.filename = filepath.Base(sf.path)
+	}
+	if sf.disposition == "" {
+		sf.disposition = "attachment"
+	}
+
+	fc.sendfile = sf
+
+	return C.FRANKENPHP_SENDFILE_OK
+}
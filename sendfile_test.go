@@ -0,0 +1,100 @@
+package frankenphp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dunglas/frankenphp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sendfileTestOptions(t *testing.T, workerScript string) *testOptions {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	return &testOptions{
+		workerScript: workerScript,
+		initOpts:     []frankenphp.Option{frankenphp.WithSendfileRoots(filepath.Join(cwd, "testdata"))},
+	}
+}
+
+func TestSendfile_module(t *testing.T) { testSendfile(t, sendfileTestOptions(t, "")) }
+func TestSendfile_worker(t *testing.T) {
+	testSendfile(t, sendfileTestOptions(t, "sendfile.php"))
+}
+func testSendfile(t *testing.T, opts *testOptions) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, _ int) {
+		req := httptest.NewRequest("GET", "http://example.com/sendfile.php?file=hello.txt", nil)
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		resp := w.Result()
+		body, _ := io.ReadAll(resp.Body)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "Hello", string(body))
+		assert.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "attachment; filename=hello.txt", resp.Header.Get("Content-Disposition"))
+		assert.Equal(t, "php", resp.Header.Get("X-Sent-By"), "the headers set by the script must be kept")
+		assert.NotEmpty(t, resp.Header.Get("ETag"))
+		assert.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+
+		req = httptest.NewRequest("GET", "http://example.com/sendfile.php?file=hello.txt&inline", nil)
+		req.Header.Set("Range", "bytes=1-3")
+		w = httptest.NewRecorder()
+		handler(w, req)
+
+		resp = w.Result()
+		body, _ = io.ReadAll(resp.Body)
+
+		assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		assert.Equal(t, "ell", string(body))
+		assert.Equal(t, "bytes 1-3/5", resp.Header.Get("Content-Range"))
+		assert.Equal(t, "inline; filename=greeting.txt", resp.Header.Get("Content-Disposition"))
+	}, opts)
+}
+
+func TestSendfileConditionalRequest(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, _ int) {
+		req := httptest.NewRequest("GET", "http://example.com/sendfile.php?file=hello.txt", nil)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		etag := w.Result().Header.Get("ETag")
+
+		req = httptest.NewRequest("GET", "http://example.com/sendfile.php?file=hello.txt", nil)
+		req.Header.Set("If-None-Match", etag)
+		w = httptest.NewRecorder()
+		handler(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Result().StatusCode)
+	}, sendfileTestOptions(t, ""))
+}
+
+func TestSendfileOutsideOfRoots(t *testing.T) {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	// a symlink must not give access to files outside of the root directories
+	link := filepath.Join(cwd, "testdata", "sendfile-escape.txt")
+	require.NoError(t, os.Symlink(filepath.Join(cwd, "frankenphp.go"), link))
+	t.Cleanup(func() { _ = os.Remove(link) })
+
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, _ int) {
+		for _, file := range []string{"../frankenphp.go", "missing.txt", "sendfile-escape.txt"} {
+			body := fetchBody("GET", "http://example.com/sendfile.php?file="+file, handler)
+			assert.Equal(t, "not sent", body, file)
+		}
+	}, sendfileTestOptions(t, ""))
+}
+
+func TestSendfileWithoutRoots(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, _ int) {
+		body := fetchBody("GET", "http://example.com/sendfile.php?file=hello.txt", handler)
+		assert.Equal(t, "not sent", body)
+	}, &testOptions{})
+}
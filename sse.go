@@ -0,0 +1,181 @@
+package frankenphp
+
+// #include "frankenphp.h"
+import "C"
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sseHeartbeatInterval is the time after which a comment is sent to an idle event stream, so that proxies do not time it out
+const sseHeartbeatInterval = 15 * time.Second
+
+// sseHeartbeat is an empty comment, ignored by clients
+var sseHeartbeat = []byte(":\n\n")
+
+// eventStream serializes the writes of the PHP thread and of the heartbeat to a Server-Sent Events response
+// implements http.ResponseWriter, it replaces the response writer of the request once the first event is sent
+type eventStream struct {
+	mu     sync.Mutex
+	w      http.ResponseWriter
+	timer  *time.Timer
+	closed bool
+}
+
+// startEventStream returns the event stream of the request, the heartbeat starts with the first event
+func (fc *frankenPHPContext) startEventStream() *eventStream {
+	if fc.eventStream != nil {
+		return fc.eventStream
+	}
+
+	s := &eventStream{w: fc.responseWriter}
+	s.mu.Lock()
+	s.timer = time.AfterFunc(sseHeartbeatInterval, s.heartbeat)
+	s.mu.Unlock()
+
+	fc.eventStream = s
+	fc.responseWriter = s
+
+	return s
+}
+
+// stopEventStream stops the heartbeat, it must be called before the response is closed
+func (fc *frankenPHPContext) stopEventStream() {
+	s := fc.eventStream
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.closed = true
+	s.timer.Stop()
+	s.mu.Unlock()
+}
+
+func (s *eventStream) Header() http.Header {
+	return s.w.Header()
+}
+
+func (s *eventStream) WriteHeader(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.w.WriteHeader(status)
+}
+
+func (s *eventStream) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.w.Write(b)
+}
+
+func (s *eventStream) FlushError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return http.NewResponseController(s.w).Flush()
+}
+
+func (s *eventStream) Flush() {
+	_ = s.FlushError()
+}
+
+func (s *eventStream) Unwrap() http.ResponseWriter {
+	return s.w
+}
+
+// send writes and flushes an event, the heartbeat is postponed
+func (s *eventStream) send(event []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.w.Write(event); err != nil {
+		return err
+	}
+	if err := http.NewResponseController(s.w).Flush(); err != nil {
+		return err
+	}
+
+	s.timer.Reset(sseHeartbeatInterval)
+
+	return nil
+}
+
+func (s *eventStream) heartbeat() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	if _, err := s.w.Write(sseHeartbeat); err != nil {
+		// the client is gone, the next event will report it to the script
+		return
+	}
+	if err := http.NewResponseController(s.w).Flush(); err != nil {
+		return
+	}
+
+	s.timer.Reset(sseHeartbeatInterval)
+}
+
+// formatEvent formats an event as described in https://html.spec.whatwg.org/multipage/server-sent-events.html
+func formatEvent(event string, data string, id *string) []byte {
+	var b strings.Builder
+
+	if event != "" {
+		b.WriteString("event: ")
+		b.WriteString(event)
+		b.WriteByte('\n')
+	}
+
+	if id != nil {
+		b.WriteString("id: ")
+		b.WriteString(*id)
+		b.WriteByte('\n')
+	}
+
+	// each line of the data must be prefixed, clients join them with line feeds
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: ")
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+
+	b.WriteByte('\n')
+
+	return []byte(b.String())
+}
+
+// go_frankenphp_sse_send writes and flushes an event, it returns false once the client is gone
+//
+//export go_frankenphp_sse_send
+func go_frankenphp_sse_send(threadIndex C.uintptr_t, event *C.char, eventLen C.size_t, data *C.char, dataLen C.size_t, id *C.char, idLen C.size_t) C.bool {
+	fc := phpThreads[threadIndex].getRequestContext()
+	if fc == nil || fc.responseWriter == nil || fc.isDone || fc.clientHasClosed() {
+		return C.bool(false)
+	}
+
+	var eventID *string
+	if id != nil {
+		s := C.GoStringN(id, C.int(idLen))
+		eventID = &s
+	}
+
+	formatted := formatEvent(C.GoStringN(event, C.int(eventLen)), C.GoStringN(data, C.int(dataLen)), eventID)
+	if err := fc.startEventStream().send(formatted); err != nil {
+		fc.logger.LogAttrs(context.Background(), slog.LevelDebug, "unable to send the event", slog.Any("error", err))
+
+		return C.bool(false)
+	}
+
+	return C.bool(!fc.clientHasClosed())
+}
@@ -0,0 +1,63 @@
+package frankenphp_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerSentEvents_module(t *testing.T) { testServerSentEvents(t, &testOptions{}) }
+func TestServerSentEvents_worker(t *testing.T) {
+	testServerSentEvents(t, &testOptions{workerScript: "sse.php"})
+}
+func testServerSentEvents(t *testing.T, opts *testOptions) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("http://example.com/sse.php?i=%d", i), nil)
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		resp := w.Result()
+		body, _ := io.ReadAll(resp.Body)
+
+		assert.Equal(t, "text/event-stream; charset=UTF-8", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+		assert.True(t, w.Flushed)
+		assert.Equal(t, fmt.Sprintf(`: stream of %d
+
+event: greeting
+id: 1
+data: hello
+data: world
+
+true
+
+data: no event name
+
+true
+
+: frankenphp_sse_send(): Argument #1 ($event) must not contain line breaks
+
+`, i), string(body))
+	}, opts)
+}
+
+func TestServerSentEventsClientGone(t *testing.T) {
+	runTest(t, func(_ func(http.ResponseWriter, *http.Request), ts *httptest.Server, i int) {
+		resp, err := http.Get(ts.URL + "/sse-loop.php")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		buf := make([]byte, 64)
+		_, err = resp.Body.Read(buf)
+		assert.NoError(t, err)
+		assert.Contains(t, string(buf), "event: tick")
+
+		// the script stops once frankenphp_sse_send() returns false
+		_ = resp.Body.Close()
+	}, &testOptions{nbParallelRequests: 1, realServer: true})
+}
@@ -51,6 +51,11 @@ type threadState struct {
 	// how long threads have been waiting in stable states
 	waitingSince time.Time
 	isWaiting    bool
+	// when the current state was entered
+	changedAt time.Time
+	// true if the thread keeps failing to boot its script before reaching a stable state,
+	// it can then be transitioned from without being considered ready
+	isFailingToBoot bool
 }
 
 type stateSubscriber struct {
@@ -63,6 +68,7 @@ func newThreadState() *threadState {
 		currentState: stateReserved,
 		subscribers:  []stateSubscriber{},
 		mu:           sync.RWMutex{},
+		changedAt:    time.Now(),
 	}
 }
 
@@ -79,6 +85,8 @@ func (ts *threadState) compareAndSwap(compareTo stateID, swapTo stateID) bool {
 	ok := ts.currentState == compareTo
 	if ok {
 		ts.currentState = swapTo
+		ts.changedAt = time.Now()
+		ts.isFailingToBoot = false
 		ts.notifySubscribers(swapTo)
 	}
 	ts.mu.Unlock()
@@ -101,6 +109,8 @@ func (ts *threadState) get() stateID {
 func (ts *threadState) set(nextState stateID) {
 	ts.mu.Lock()
 	ts.currentState = nextState
+	ts.changedAt = time.Now()
+	ts.isFailingToBoot = false
 	ts.notifySubscribers(nextState)
 	ts.mu.Unlock()
 }
@@ -148,9 +158,20 @@ func (ts *threadState) requestSafeStateChange(nextState stateID) bool {
 	// ready and inactive are safe states to transition from
 	case stateReady, stateInactive:
 		ts.currentState = nextState
+		ts.changedAt = time.Now()
 		ts.notifySubscribers(nextState)
 		ts.mu.Unlock()
 		return true
+	// so is a thread that keeps failing to boot, it is retried at the start of each script execution
+	case stateTransitionComplete:
+		if ts.isFailingToBoot {
+			ts.currentState = nextState
+			ts.changedAt = time.Now()
+			ts.isFailingToBoot = false
+			ts.notifySubscribers(nextState)
+			ts.mu.Unlock()
+			return true
+		}
 	}
 	ts.mu.Unlock()
 
@@ -159,6 +180,17 @@ func (ts *threadState) requestSafeStateChange(nextState stateID) bool {
 	return ts.requestSafeStateChange(nextState)
 }
 
+// markAsFailingToBoot hints that the thread keeps failing to boot its script after a transition
+// it stays in its current state, but the goroutines waiting for it to become ready or to transition it are released
+func (ts *threadState) markAsFailingToBoot() {
+	ts.mu.Lock()
+	if ts.currentState == stateTransitionComplete {
+		ts.isFailingToBoot = true
+		ts.notifySubscribers(stateReady)
+	}
+	ts.mu.Unlock()
+}
+
 // markAsWaiting hints that the thread reached a stable state and is waiting for requests or shutdown
 func (ts *threadState) markAsWaiting(isWaiting bool) {
 	ts.mu.Lock()
@@ -189,3 +221,12 @@ func (ts *threadState) waitTime() int64 {
 	ts.mu.RUnlock()
 	return waitTime
 }
+
+// current returns the current state and how long the thread has been in it
+func (ts *threadState) current() (stateID, time.Duration) {
+	ts.mu.RLock()
+	state, since := ts.currentState, time.Since(ts.changedAt)
+	ts.mu.RUnlock()
+
+	return state, since
+}
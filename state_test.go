@@ -38,6 +38,21 @@ func TestStateShouldHaveCorrectAmountOfSubscribers(t *testing.T) {
 	assertNumberOfSubscribers(t, threadState, 0)
 }
 
+func TestThreadFailingToBootCanTransitionWithoutBeingReady(t *testing.T) {
+	threadState := &threadState{currentState: stateTransitionComplete}
+
+	transitioned := make(chan bool)
+	go func() {
+		transitioned <- threadState.requestSafeStateChange(stateShuttingDown)
+	}()
+	assertNumberOfSubscribers(t, threadState, 1)
+
+	threadState.markAsFailingToBoot()
+	assert.True(t, <-transitioned)
+	assert.True(t, threadState.is(stateShuttingDown))
+	assert.False(t, threadState.isFailingToBoot)
+}
+
 func assertNumberOfSubscribers(t *testing.T, threadState *threadState, expected int) {
 	maxWaits := 10_000 // wait for 1 second max
 
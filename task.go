@@ -0,0 +1,168 @@
+package frankenphp
+
+// #include "frankenphp.h"
+import "C"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"unsafe"
+)
+
+var (
+	// ErrTaskRejected is returned when a task cannot be queued, e.g. because the queue of the worker is full
+	ErrTaskRejected = errors.New("task rejected")
+	// ErrNotTaskWorker is returned when a task or a call is sent to a worker that has not been started with WithWorkerTasks
+	ErrNotTaskWorker = errors.New("worker does not handle tasks")
+)
+
+// workerTask is a PHP callable invocation dispatched to a worker script looping on frankenphp_handle_task()
+type workerTask struct {
+	// the payload, in the PHP serialize() format since it crosses thread boundaries
+	payload []byte
+	// closed once the task has been handed to a thread or queued
+	accepted chan struct{}
+	// true if the task has been rejected before reaching the worker script
+	rejected bool
+	// closed once the caller of Call has given up, nil for dispatched tasks
+	cancelled <-chan struct{}
+	// true once the callback has returned, result is its return value converted to a string
+	handled bool
+	result  []byte
+}
+
+// DispatchTask sends a payload to the task worker with the given name, which receives it through frankenphp_handle_task().
+// The worker must have been started with WithWorkerTasks, ErrNotTaskWorker is returned otherwise.
+// The payload is converted to PHP: nil, booleans, numbers, strings, []byte, slices and maps are supported.
+// DispatchTask returns once the task has been handed to a thread or queued, tasks share the queue of HTTP requests
+// sent to the worker and ErrTaskRejected is returned if it is full.
+func DispatchTask(workerName string, payload any) error {
+	serialized, err := serializePHP(payload)
+	if err != nil {
+		return err
+	}
+
+	return dispatchTask(workerName, serialized)
+}
+
+func dispatchTask(workerName string, payload []byte) error {
+	if !isRunning {
+		return ErrNotRunning
+	}
+
+	worker, err := getTaskWorker(workerName)
+	if err != nil {
+		return err
+	}
+
+	fc, err := newTaskContext(worker, payload)
+	if err != nil {
+		return err
+	}
+
+	go worker.handleRequest(fc)
+
+	select {
+	case <-fc.task.accepted:
+		return nil
+	case <-fc.done:
+		// the task may also have been handled right away
+		select {
+		case <-fc.task.accepted:
+			return nil
+		default:
+			return fmt.Errorf("%w by worker %q", ErrTaskRejected, workerName)
+		}
+	}
+}
+
+// getTaskWorker returns the worker with the given name if it handles tasks
+func getTaskWorker(workerName string) (*worker, error) {
+	worker := getWorkerByName(workerName)
+	switch {
+	case worker == nil:
+		return nil, fmt.Errorf("%w: %q", ErrWorkerNotFound, workerName)
+	case !worker.tasks:
+		return nil, fmt.Errorf("%w: %q", ErrNotTaskWorker, workerName)
+	}
+
+	return worker, nil
+}
+
+// newTaskContext creates the context of a task, in the same way as the dummy context of the worker
+func newTaskContext(worker *worker, payload []byte) (*frankenPHPContext, error) {
+	fc, err := newDummyContext(
+		filepath.Base(worker.fileName),
+		WithRequestDocumentRoot(filepath.Dir(worker.fileName), false),
+		WithRequestPreparedEnv(worker.env),
+		WithWorkerName(worker.name),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	fc.task = &workerTask{payload: payload, accepted: make(chan struct{})}
+
+	return fc, nil
+}
+
+// cancelled returns a channel closed once the caller of Call has given up, nil if the context can't be cancelled
+func (fc *frankenPHPContext) cancelled() <-chan struct{} {
+	if fc.task == nil {
+		return nil
+	}
+
+	return fc.task.cancelled
+}
+
+// markAccepted notifies the dispatcher of a task that the task has been handed to a thread or queued
+func (fc *frankenPHPContext) markAccepted() {
+	if fc.task == nil {
+		return
+	}
+
+	// tasks handed over to the replacement of a worker are accepted twice
+	select {
+	case <-fc.task.accepted:
+	default:
+		close(fc.task.accepted)
+	}
+}
+
+//export go_frankenphp_dispatch_task
+func go_frankenphp_dispatch_task(workerName *C.char, workerNameLen C.size_t, payload *C.char, payloadLen C.size_t) C.int {
+	name := C.GoStringN(workerName, C.int(workerNameLen))
+
+	err := dispatchTask(name, C.GoBytes(unsafe.Pointer(payload), C.int(payloadLen)))
+	switch {
+	case err == nil:
+		return C.FRANKENPHP_TASK_DISPATCHED
+	case errors.Is(err, ErrWorkerNotFound):
+		return C.FRANKENPHP_TASK_WORKER_NOT_FOUND
+	case errors.Is(err, ErrNotTaskWorker):
+		return C.FRANKENPHP_TASK_NOT_TASK_WORKER
+	}
+
+	logger.LogAttrs(context.Background(), slog.LevelWarn, "unable to dispatch the task", slog.String("worker", name), slog.Any("error", err))
+
+	return C.FRANKENPHP_TASK_REJECTED
+}
+
+// go_frankenphp_task_payload returns the serialized payload of the task handled by the thread,
+// or nil if the thread is not handling a task
+//
+//export go_frankenphp_task_payload
+func go_frankenphp_task_payload(threadIndex C.uintptr_t) (*C.char, C.size_t) {
+	thread := phpThreads[threadIndex]
+	fc := thread.getRequestContext()
+	if fc == nil || fc.task == nil || len(fc.task.payload) == 0 {
+		return nil, 0
+	}
+
+	p := unsafe.SliceData(fc.task.payload)
+	thread.Pin(p)
+
+	return (*C.char)(unsafe.Pointer(p)), C.size_t(len(fc.task.payload))
+}
@@ -0,0 +1,71 @@
+package frankenphp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dunglas/frankenphp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var taskWorkerOpts = []frankenphp.WorkerOption{frankenphp.WithWorkerTasks(true)}
+
+func TestDispatchTaskFromGo(t *testing.T) {
+	runTest(t, func(_ func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		file := filepath.Join(t.TempDir(), "task")
+
+		require.NoError(t, frankenphp.DispatchTask("workerName", map[string]any{"file": file, "value": "from go"}))
+
+		assert.Eventually(t, func() bool {
+			content, _ := os.ReadFile(file)
+
+			return string(content) == "from go"
+		}, 5*time.Second, 10*time.Millisecond)
+	}, &testOptions{workerScript: "task-worker.php", nbWorkers: 1, nbParallelRequests: 1, workerOpts: taskWorkerOpts})
+}
+
+func TestDispatchTaskFromPHP(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		file := filepath.Join(t.TempDir(), "task")
+
+		assert.Equal(t, "true", fetchBody("GET", "http://example.com/dispatch-task.php?file="+url.QueryEscape(file), handler))
+
+		assert.Eventually(t, func() bool {
+			content, _ := os.ReadFile(file)
+
+			return string(content) == "from php"
+		}, 5*time.Second, 10*time.Millisecond)
+	}, &testOptions{workerScript: "task-worker.php", nbWorkers: 1, nbParallelRequests: 1, workerOpts: taskWorkerOpts})
+}
+
+func TestDispatchTaskToUnknownWorker(t *testing.T) {
+	runTest(t, func(_ func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		assert.ErrorIs(t, frankenphp.DispatchTask("unknown", nil), frankenphp.ErrWorkerNotFound)
+	}, &testOptions{nbParallelRequests: 1})
+}
+
+func TestDispatchTaskToWorkerWithoutTasks(t *testing.T) {
+	runTest(t, func(_ func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		assert.ErrorIs(t, frankenphp.DispatchTask("workerName", nil), frankenphp.ErrNotTaskWorker)
+
+		_, err := frankenphp.Call(context.Background(), "workerName", nil)
+		assert.ErrorIs(t, err, frankenphp.ErrNotTaskWorker)
+	}, &testOptions{workerScript: "worker.php", nbWorkers: 1, nbParallelRequests: 1})
+}
+
+func TestHTTPRequestsAreNotRoutedToTaskWorkers(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		req := httptest.NewRequest("GET", "http://example.com/task-worker.php", nil)
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	}, &testOptions{workerScript: "task-worker.php", nbWorkers: 1, nbParallelRequests: 1, workerOpts: taskWorkerOpts})
+}
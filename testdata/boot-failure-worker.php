@@ -0,0 +1,12 @@
+<?php
+
+// the boot fails once each time the marker file is created
+$marker = $_SERVER['BOOT_FAILURE_MARKER'];
+if (is_file($marker) && unlink($marker)) {
+    exit(1);
+}
+
+while (frankenphp_handle_request(function () {
+    echo 'booted';
+})) {
+}
@@ -0,0 +1,22 @@
+<?php
+
+require_once __DIR__.'/_executor.php';
+
+return function () {
+    $key = $_GET['key'];
+
+    switch ($_GET['action']) {
+        case 'set':
+            var_export(frankenphp_cache_set($key, ['value' => $_GET['value'], 'number' => 42], (int) ($_GET['ttl'] ?? 0)));
+            break;
+        case 'get':
+            var_export(frankenphp_cache_get($key, 'default'));
+            break;
+        case 'increment':
+            var_export(frankenphp_cache_increment($key, (int) ($_GET['step'] ?? 1)));
+            break;
+        case 'delete':
+            var_export(frankenphp_cache_delete($key));
+            break;
+    }
+};
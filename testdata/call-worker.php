@@ -0,0 +1,18 @@
+<?php
+
+while (frankenphp_handle_task(function (string $payload): string {
+    switch ($payload) {
+        case 'crash':
+            exit(1);
+        case 'loop':
+            while (true) {
+            }
+    }
+
+    if (str_starts_with($payload, 'touch:')) {
+        touch(substr($payload, 6));
+    }
+
+    return strtoupper($payload);
+})) {
+}
@@ -0,0 +1,11 @@
+<?php
+
+ignore_user_abort(isset($_GET['ignore']));
+
+// busy loop without output until the client disconnects
+$deadline = microtime(true) + 5;
+while (!connection_aborted() && microtime(true) < $deadline) {
+    $i = ($i ?? 0) + 1;
+}
+
+file_put_contents($_GET['marker'], (string) connection_status());
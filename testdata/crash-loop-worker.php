@@ -0,0 +1,11 @@
+<?php
+
+// fails to boot until the marker file exists
+if (!file_exists($_SERVER['MARKER'])) {
+    exit(1);
+}
+
+while (frankenphp_handle_request(function () {
+    echo "recovered";
+})) {
+}
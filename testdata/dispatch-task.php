@@ -0,0 +1,3 @@
+<?php
+
+var_export(frankenphp_dispatch_task('workerName', ['file' => $_GET['file'], 'value' => 'from php']));
@@ -0,0 +1,22 @@
+<?php
+
+require_once __DIR__.'/_executor.php';
+
+return function () {
+    $topic = $_GET['topic'];
+
+    switch ($_GET['action']) {
+        case 'publish':
+            echo frankenphp_publish($topic, $_GET['payload']);
+            break;
+        case 'poll':
+            var_export(frankenphp_poll($topic));
+            break;
+        case 'roundtrip':
+            frankenphp_poll($topic);
+            frankenphp_publish($topic, 'first');
+            frankenphp_publish($topic, 'second');
+            var_export([frankenphp_poll($topic), frankenphp_poll($topic), frankenphp_poll($topic)]);
+            break;
+    }
+};
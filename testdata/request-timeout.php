@@ -0,0 +1,21 @@
+<?php
+
+require_once __DIR__.'/_executor.php';
+
+return function () {
+    // busy loop until the request timeout interrupts the script
+    $deadline = microtime(true) + 3;
+    $i = 0;
+    try {
+        while (microtime(true) < $deadline) {
+            $i++;
+        }
+    } catch (Error $e) {
+        // keep running to exceed the grace period
+        while (isset($_GET['ignore']) && microtime(true) < $deadline) {
+            $i++;
+        }
+
+        echo 'caught: ', $e->getMessage();
+    }
+};
@@ -0,0 +1,5 @@
+<?php
+
+file_put_contents($_SERVER['OUTPUT_FILE'], implode(',', $argv) . "\n" . $argc);
+
+echo 'job done';
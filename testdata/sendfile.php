@@ -0,0 +1,20 @@
+<?php
+
+require_once __DIR__.'/_executor.php';
+
+return function () {
+    header('X-Sent-By: php');
+
+    $options = [];
+    if (isset($_GET['inline'])) {
+        $options = ['disposition' => 'inline', 'filename' => 'greeting.txt'];
+    }
+
+    if (!@frankenphp_sendfile(__DIR__.'/'.$_GET['file'], $options)) {
+        echo 'not sent';
+
+        return;
+    }
+
+    echo 'not sent either';
+};
@@ -0,0 +1,12 @@
+<?php
+
+require_once __DIR__.'/_executor.php';
+
+return function () {
+    $i = 0;
+    while (frankenphp_sse_send('tick', (string) $i++)) {
+        usleep(10000);
+    }
+
+    error_log("sse loop stopped after $i events");
+};
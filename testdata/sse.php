@@ -0,0 +1,18 @@
+<?php
+
+require_once __DIR__.'/_executor.php';
+
+return function () {
+    echo ": stream of $_GET[i]\n\n";
+
+    var_export(frankenphp_sse_send('greeting', "hello\nworld", '1'));
+    echo "\n\n";
+    var_export(frankenphp_sse_send('', 'no event name'));
+    echo "\n\n";
+
+    try {
+        frankenphp_sse_send("invalid\nname", 'data');
+    } catch (ValueError $e) {
+        echo ': ', $e->getMessage(), "\n\n";
+    }
+};
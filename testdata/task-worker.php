@@ -0,0 +1,6 @@
+<?php
+
+while (frankenphp_handle_task(function (array $payload): void {
+    file_put_contents($payload['file'], $payload['value']);
+})) {
+}
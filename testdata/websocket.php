@@ -0,0 +1,25 @@
+<?php
+
+// replies to the messages of each connection, and publishes the closed connections
+while (frankenphp_handle_websocket(function (array $event): void {
+    $connection = $event['connection'];
+
+    switch ($event['type']) {
+        case 'open':
+            frankenphp_ws_send($connection, 'welcome '.$_SERVER['QUERY_STRING']);
+            break;
+        case 'message':
+            if ($event['data'] === 'bye') {
+                frankenphp_ws_close($connection);
+                break;
+            }
+
+            frankenphp_ws_send($connection, ($event['binary'] ? 'binary:' : 'echo:').$event['data'], $event['binary']);
+            break;
+        case 'close':
+            frankenphp_publish('websocket-closed', (string) $connection);
+            break;
+    }
+})) {
+    gc_collect_cycles();
+}
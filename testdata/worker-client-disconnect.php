@@ -0,0 +1,28 @@
+<?php
+
+$requests = 0;
+
+while (frankenphp_handle_request(function () use (&$requests) {
+    $requests++;
+
+    if (!isset($_GET['marker'])) {
+        echo "requests:$requests";
+
+        return;
+    }
+
+    try {
+        // busy loop without output until the client disconnects
+        $deadline = microtime(true) + 5;
+        while (microtime(true) < $deadline) {
+            $i = ($i ?? 0) + 1;
+        }
+
+        file_put_contents($_GET['marker'], 'end');
+    } catch (\Throwable) {
+        file_put_contents($_GET['marker'], 'catch');
+    } finally {
+        file_put_contents($_GET['marker'].'.finally', 'finally');
+    }
+})) {
+}
@@ -2,6 +2,7 @@ package frankenphp
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
 // representation of a non-worker PHP thread
@@ -17,6 +18,10 @@ var (
 	regularThreads     []*phpThread
 	regularThreadMu    = &sync.RWMutex{}
 	regularRequestChan chan *frankenPHPContext
+	// number of requests currently waiting for a regular thread, bounded by maxQueueLength
+	regularQueueLength atomic.Int64
+	// requests waiting for a regular thread, ordered by priority
+	regularRequestQueue = &requestQueue{}
 )
 
 func convertToRegularThread(thread *phpThread) {
@@ -83,12 +88,17 @@ func (handler *regularThread) waitForRequest() string {
 		return handler.beforeScriptExecution()
 	}
 
+	fc.watchClientDisconnect(handler.thread)
+	fc.startTimeout(handler.thread)
+	fc.startRequestHooks(handler.thread.threadIndex)
+
 	// set the scriptFilename that should be executed
 	return fc.scriptFilename
 }
 
 func (handler *regularThread) afterRequest() {
 	handler.requestContext.closeContext()
+	handler.requestContext.endRequestHooks(handler.thread.threadIndex)
 	handler.requestContext = nil
 }
 
@@ -97,26 +107,44 @@ func handleRequestWithRegularPHPThreads(fc *frankenPHPContext) {
 	select {
 	case regularRequestChan <- fc:
 		// a thread was available to handle the request immediately
-		<-fc.done
+		fc.wait()
 		metrics.StopRequest()
 		return
 	default:
 		// no thread was available
 	}
 
+	// if the queue is full, reject the request immediately instead of letting it pile up
+	if !enqueue(&regularQueueLength, maxQueueLength) {
+		metrics.StopRequest()
+		metrics.RejectedRequest()
+		fc.rejectQueueFull()
+		return
+	}
+
 	// if no thread was available, mark the request as queued and fan it out to all threads
+	// once it is next in line according to its priority
 	metrics.QueuedRequest()
+	queued := regularRequestQueue.push(fc)
+	timeout := timeoutChan(maxWaitTime)
 	for {
+		dispatch, promoted := queued.dispatchChan(regularRequestChan)
 		select {
-		case regularRequestChan <- fc:
+		case dispatch <- fc:
+			regularRequestQueue.remove(queued)
+			regularQueueLength.Add(-1)
 			metrics.DequeuedRequest()
-			<-fc.done
+			fc.wait()
 			metrics.StopRequest()
 			return
+		case <-promoted:
+			// the request is next in line, continue to wait for a thread
 		case scaleChan <- fc:
 			// the request has triggered scaling, continue to wait for a thread
-		case <-timeoutChan(maxWaitTime):
+		case <-timeout:
 			// the request has timed out stalling
+			regularRequestQueue.remove(queued)
+			regularQueueLength.Add(-1)
 			metrics.DequeuedRequest()
 			fc.reject(504, "Gateway Timeout")
 			return
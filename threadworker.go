@@ -6,7 +6,7 @@ import (
 	"context"
 	"log/slog"
 	"path/filepath"
-	"time"
+	"sync/atomic"
 )
 
 // representation of a thread assigned to a worker script
@@ -19,27 +19,45 @@ type workerThread struct {
 	dummyContext    *frankenPHPContext
 	workerContext   *frankenPHPContext
 	backoff         *exponentialBackoff
-	isBootingScript bool // true if the worker has not reached frankenphp_handle_request yet
+	isBootingScript bool               // true if the worker has not reached frankenphp_handle_request yet
+	requestCount    int                // number of requests handled since the worker script was started
+	isRecycling     atomic.Bool        // true if the thread is being restarted because it exceeded its memory limit or its request timeout
+	bootResult      chan error         // notified once the worker script has rebooted during a rolling restart
+	standby         chan struct{}      // closed once the thread may accept requests, nil if it is already accepting requests
+	isWarmingUp     bool               // true if the worker script is replaying the warm-up requests after booting
+	warmupIndex     int                // index of the next warm-up request to replay
+	warmupContext   *frankenPHPContext // warm-up request currently handled by the worker script
+}
+
+func newWorkerThread(thread *phpThread, worker *worker) *workerThread {
+	return &workerThread{
+		state:   thread.state,
+		thread:  thread,
+		worker:  worker,
+		backoff: worker.newBackoff(),
+	}
 }
 
 func convertToWorkerThread(thread *phpThread, worker *worker) {
-	thread.setHandler(&workerThread{
-		state:  thread.state,
-		thread: thread,
-		worker: worker,
-		backoff: &exponentialBackoff{
-			maxBackoff:             1 * time.Second,
-			minBackoff:             100 * time.Millisecond,
-			maxConsecutiveFailures: 6,
-		},
-	})
+	thread.setHandler(newWorkerThread(thread, worker))
 	worker.attachThread(thread)
 }
 
+// convertToStandbyWorkerThread boots the worker script on a thread that is not attached to the worker yet
+// bootResult is notified once the script has reached frankenphp_handle_request() or has failed to boot too many times,
+// the thread only starts accepting requests once standby is closed
+func convertToStandbyWorkerThread(thread *phpThread, worker *worker, bootResult chan error, standby chan struct{}) {
+	handler := newWorkerThread(thread, worker)
+	handler.bootResult = bootResult
+	handler.standby = standby
+	thread.setHandler(handler)
+}
+
 // beforeScriptExecution returns the name of the script or an empty string on shutdown
 func (handler *workerThread) beforeScriptExecution() string {
 	switch handler.state.get() {
 	case stateTransitionRequested:
+		handler.notifyBoot(errThreadDetached)
 		handler.worker.detachThread(handler.thread)
 		return handler.thread.transitionToNewHandler()
 	case stateRestarting:
@@ -50,6 +68,7 @@ func (handler *workerThread) beforeScriptExecution() string {
 		setupWorkerScript(handler, handler.worker)
 		return handler.worker.fileName
 	case stateShuttingDown:
+		handler.notifyBoot(errThreadDetached)
 		handler.worker.detachThread(handler.thread)
 		// signal to stop
 		return ""
@@ -77,7 +96,8 @@ func setupWorkerScript(handler *workerThread, worker *worker) {
 	handler.backoff.wait()
 	metrics.StartWorker(worker.name)
 
-	if handler.state.is(stateReady) {
+	// threads of a new generation are only counted once they are attached to the worker
+	if handler.state.is(stateReady) && handler.standby == nil {
 		metrics.ReadyWorker(handler.worker.name)
 	}
 
@@ -97,8 +117,11 @@ func setupWorkerScript(handler *workerThread, worker *worker) {
 
 	handler.dummyContext = fc
 	handler.isBootingScript = true
+	handler.requestCount = 0
 	clearSandboxedEnv(handler.thread)
 	logger.LogAttrs(context.Background(), slog.LevelDebug, "starting", slog.String("worker", worker.name), slog.Int("thread", handler.thread.threadIndex))
+
+	runWorkerBootHooks(worker, handler.thread.threadIndex)
 }
 
 func tearDownWorkerScript(handler *workerThread, exitStatus int) {
@@ -108,16 +131,33 @@ func tearDownWorkerScript(handler *workerThread, exitStatus int) {
 	ctx := context.Background()
 
 	// if the worker request is not nil, the script might have crashed
-	// make sure to close the worker request context
-	if handler.workerContext != nil {
-		handler.workerContext.closeContext()
+	// make sure to close the worker request context, hooks are not called for warm-up requests
+	if fc := handler.workerContext; fc != nil {
+		if fc != handler.warmupContext {
+			fc.endRequestHooks(handler.thread.threadIndex)
+		}
+		fc.closeContext()
 		handler.workerContext = nil
 	}
 
+	if handler.warmupContext != nil {
+		handler.failWarmup(handler.warmupContext.request.URL.RequestURI(), nil, slog.Int("exit_status", exitStatus))
+		handler.warmupContext = nil
+	}
+
+	// the thread is only booted once the warm-up is done, a crash during the warm-up is a boot failure
+	isBootingScript := handler.isBootingScript || handler.isWarmingUp
+	handler.isWarmingUp = false
+
+	// the warm-up is replayed from the start after a crash, but not after a graceful restart (max_requests, max_memory...)
+	if exitStatus != 0 || isBootingScript {
+		handler.warmupIndex = 0
+	}
+
 	// on exit status 0 we just run the worker script again
-	if exitStatus == 0 && !handler.isBootingScript {
-		// TODO: make the max restart configurable
+	if exitStatus == 0 && !isBootingScript {
 		metrics.StopWorker(worker.name, StopReasonRestart)
+		runWorkerStopHooks(worker, handler.thread.threadIndex, StopReasonRestart)
 		handler.backoff.recordSuccess()
 		logger.LogAttrs(ctx, slog.LevelDebug, "restarting", slog.String("worker", worker.name), slog.Int("thread", handler.thread.threadIndex), slog.Int("exit_status", exitStatus))
 
@@ -126,18 +166,49 @@ func tearDownWorkerScript(handler *workerThread, exitStatus int) {
 
 	// worker has thrown a fatal error or has not reached frankenphp_handle_request
 	metrics.StopWorker(worker.name, StopReasonCrash)
+	runWorkerStopHooks(worker, handler.thread.threadIndex, StopReasonCrash)
 
-	if !handler.isBootingScript {
+	if !isBootingScript {
 		// fatal error (could be due to exit(1), timeouts, etc.)
 		logger.LogAttrs(ctx, slog.LevelDebug, "restarting", slog.String("worker", worker.name), slog.Int("thread", handler.thread.threadIndex), slog.Int("exit_status", exitStatus))
 
 		return
 	}
 
-	logger.LogAttrs(ctx, slog.LevelError, "worker script has not reached frankenphp_handle_request()", slog.String("worker", worker.name), slog.Int("thread", handler.thread.threadIndex))
+	if handler.isBootingScript {
+		logger.LogAttrs(ctx, slog.LevelError, "worker script has not reached frankenphp_handle_request()", slog.String("worker", worker.name), slog.Int("thread", handler.thread.threadIndex))
+	} else {
+		logger.LogAttrs(ctx, slog.LevelError, "worker script stopped during the warm-up", slog.String("worker", worker.name), slog.Int("thread", handler.thread.threadIndex), slog.Int("exit_status", exitStatus))
+	}
 
+	// a pending restart keeps waiting while the script is retried with exponential backoff
 	// panic after exponential backoff if the worker has never reached frankenphp_handle_request
 	if handler.backoff.recordFailure() {
+		// a new generation that fails to boot is discarded, the previous generation keeps serving requests
+		if handler.standby != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "too many consecutive worker failures, discarding the new generation", slog.String("worker", worker.name), slog.Int("thread", handler.thread.threadIndex), slog.Int("failures", handler.backoff.failureCount))
+
+			// the thread must stop booting the script before notifying, since the restart will then retire it
+			handler.thread.transitionToInactive()
+			handler.notifyBoot(errWorkerNotBooted)
+
+			return
+		}
+
+		handler.notifyBoot(errWorkerNotBooted)
+
+		if worker.crashLoopPolicy != CrashLoopPolicyPanic {
+			if worker.markUnhealthy() {
+				logger.LogAttrs(ctx, slog.LevelError, "too many consecutive worker failures, marking the worker as unhealthy", slog.String("worker", worker.name), slog.String("policy", string(worker.crashLoopPolicy)), slog.Int("failures", handler.backoff.failureCount))
+			}
+
+			// do not block startup, the thread keeps trying to boot the script in the background
+			// it is not marked as ready, so it is not counted in health checks and metrics until it has booted
+			handler.state.markAsFailingToBoot()
+
+			return
+		}
+
 		if !watcherIsEnabled && !handler.state.is(stateReady) {
 			logger.LogAttrs(ctx, slog.LevelError, "too many consecutive worker failures", slog.String("worker", worker.name), slog.Int("thread", handler.thread.threadIndex), slog.Int("failures", handler.backoff.failureCount))
 			panic("too many consecutive worker failures")
@@ -152,6 +223,20 @@ func (handler *workerThread) waitForWorkerRequest() bool {
 	handler.thread.Unpin()
 
 	ctx := context.Background()
+
+	// restart the worker script if it has handled the maximum amount of requests
+	// returning false will make frankenphp_handle_request() return false and the script exit gracefully
+	if handler.worker.maxRequests > 0 && handler.requestCount >= handler.worker.maxRequests {
+		logger.LogAttrs(ctx, slog.LevelDebug, "max requests reached, restarting", slog.String("worker", handler.worker.name), slog.Int("thread", handler.thread.threadIndex), slog.Int("max_requests", handler.worker.maxRequests))
+
+		return false
+	}
+
+	// threads that exceeded their memory limit or their request timeout must not accept new requests before restarting
+	if handler.isRecycling.CompareAndSwap(true, false) {
+		return false
+	}
+
 	logger.LogAttrs(ctx, slog.LevelDebug, "waiting for request", slog.String("worker", handler.worker.name), slog.Int("thread", handler.thread.threadIndex))
 
 	// Clear the first dummy request created to initialize the worker
@@ -160,16 +245,58 @@ func (handler *workerThread) waitForWorkerRequest() bool {
 		if !C.frankenphp_shutdown_dummy_request() {
 			panic("Not in CGI context")
 		}
+
+		handler.isWarmingUp = true
 	}
 
+	// replay the warm-up requests before the thread is considered booted and receives traffic
+	if fc := handler.nextWarmupRequest(); fc != nil {
+		handler.workerContext = fc
+
+		logger.LogAttrs(ctx, slog.LevelDebug, "warm-up request started", slog.String("worker", handler.worker.name), slog.Int("thread", handler.thread.threadIndex), slog.String("url", fc.request.RequestURI))
+
+		if err := updateServerContext(handler.thread, fc, true); err != nil {
+			handler.workerContext = nil
+			handler.warmupContext = nil
+			fc.closeContext()
+			handler.failWarmup(fc.request.URL.RequestURI(), err)
+
+			return handler.waitForWorkerRequest()
+		}
+
+		return true
+	}
+
+	// the script booted, failures are only counted while they are consecutive
+	if handler.isWarmingUp {
+		handler.isWarmingUp = false
+		handler.backoff.recordSuccess()
+		handler.worker.markHealthy()
+	}
+
+	handler.notifyBoot(nil)
+
 	// worker threads are 'ready' after they first reach frankenphp_handle_request()
 	// 'stateTransitionComplete' is only true on the first boot of the worker script,
 	// while 'isBootingScript' is true on every boot of the worker script
 	if handler.state.is(stateTransitionComplete) {
-		metrics.ReadyWorker(handler.worker.name)
+		if handler.standby == nil {
+			metrics.ReadyWorker(handler.worker.name)
+		}
 		handler.state.set(stateReady)
 	}
 
+	// threads of a new generation only accept requests once the whole generation has booted
+	if handler.standby != nil {
+		select {
+		case <-handler.standby:
+			handler.standby = nil
+			metrics.ReadyWorker(handler.worker.name)
+		case <-handler.thread.drainChan:
+			return false
+		}
+	}
+
 	handler.state.markAsWaiting(true)
 
 	var fc *frankenPHPContext
@@ -184,6 +311,13 @@ func (handler *workerThread) waitForWorkerRequest() bool {
 		}
 
 		return false
+	case done := <-opcacheResets:
+		// the opcache can only be reset from a request context, e.g. before booting a new generation
+		handler.state.markAsWaiting(false)
+		C.frankenphp_reset_opcache()
+		close(done)
+
+		return handler.waitForWorkerRequest()
 	case fc = <-handler.thread.requestChan:
 	case fc = <-handler.worker.requestChan:
 	}
@@ -202,6 +336,10 @@ func (handler *workerThread) waitForWorkerRequest() bool {
 		return handler.waitForWorkerRequest()
 	}
 
+	fc.watchClientDisconnect(handler.thread)
+	fc.startTimeout(handler.thread)
+	fc.startRequestHooks(handler.thread.threadIndex)
+
 	return true
 }
 
@@ -221,9 +359,61 @@ func go_frankenphp_finish_worker_request(threadIndex C.uintptr_t) {
 	fc := thread.getRequestContext()
 
 	fc.closeContext()
-	thread.handler.(*workerThread).workerContext = nil
+	handler := thread.handler.(*workerThread)
+	handler.workerContext = nil
+	// warm-up requests do not call hooks nor count towards max_requests
+	if fc != handler.warmupContext {
+		fc.endRequestHooks(thread.threadIndex)
+		handler.requestCount++
+	}
 
 	fc.logger.LogAttrs(context.Background(), slog.LevelDebug, "request handling finished", slog.String("worker", fc.scriptFilename), slog.Int("thread", thread.threadIndex), slog.String("url", fc.request.RequestURI))
+
+	if handler.worker.maxMemory > 0 {
+		if memoryUsage := int64(C.frankenphp_get_current_memory_usage()); memoryUsage > handler.worker.maxMemory {
+			handler.recycle(memoryUsage)
+		}
+	}
+}
+
+// recycle restarts the worker script on this thread once it has exceeded its memory limit
+// the thread is drained and rebooted in the same way as when restarting all workers
+func (handler *workerThread) recycle(memoryUsage int64) {
+	if !handler.scheduleRestart() {
+		return
+	}
+
+	metrics.RecycleWorker(handler.worker.name)
+	logger.LogAttrs(context.Background(), slog.LevelWarn, "memory limit exceeded, restarting", slog.String("worker", handler.worker.name), slog.Int("thread", handler.thread.threadIndex), slog.Int64("memory_usage", memoryUsage), slog.Int64("max_memory", handler.worker.maxMemory))
+}
+
+// scheduleRestart restarts the worker script once the current request is done,
+// it returns false if the thread is already restarting, transitioning or shutting down
+func (handler *workerThread) scheduleRestart() bool {
+	if !handler.state.compareAndSwap(stateReady, stateRestarting) {
+		return false
+	}
+
+	handler.isRecycling.Store(true)
+	close(handler.thread.drainChan)
+
+	go func() {
+		handler.state.waitFor(stateYielding)
+		handler.thread.drainChan = make(chan struct{})
+		handler.state.set(stateReady)
+	}()
+
+	return true
+}
+
+// notifyBoot reports to a pending rolling or blue/green restart whether the worker script rebooted successfully
+func (handler *workerThread) notifyBoot(err error) {
+	if handler.bootResult == nil {
+		return
+	}
+
+	handler.bootResult <- err
+	handler.bootResult = nil
 }
 
 // when frankenphp_finish_request() is directly called from PHP
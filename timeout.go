@@ -0,0 +1,276 @@
+package frankenphp
+
+// #include "frankenphp.h"
+import "C"
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultRequestTimeoutGracePeriod is the time a script has to yield after being interrupted by its request timeout
+const defaultRequestTimeoutGracePeriod = 5 * time.Second
+
+var (
+	requestTimeoutGracePeriod = defaultRequestTimeoutGracePeriod
+	// defaultRequestTimeout applies to the HTTP requests that have no timeout of their own, 0 if disabled
+	defaultRequestTimeout time.Duration
+)
+
+// requestTimeout enforces the maximum duration of a request from Go, including the time spent in I/O
+type requestTimeout struct {
+	duration time.Duration
+	// if set, the timeout expires at this time instead of duration after the script has started
+	deadline time.Time
+	mu       sync.Mutex
+	timer    *time.Timer
+	// true once PHP has handled the request or the request has been abandoned
+	finished bool
+	// true until the timeout error has been thrown in the script
+	interruptPending atomic.Bool
+	// closed once the request has been abandoned because the script did not yield within the grace period
+	abandoned chan struct{}
+	writer    *timeoutResponseWriter
+}
+
+func newRequestTimeout(duration time.Duration) *requestTimeout {
+	return &requestTimeout{duration: duration, abandoned: make(chan struct{})}
+}
+
+// newRequestDeadline creates a timeout expiring at the given time, whenever the script starts
+func newRequestDeadline(deadline time.Time) *requestTimeout {
+	rt := newRequestTimeout(time.Until(deadline))
+	rt.deadline = deadline
+
+	return rt
+}
+
+// applyDefaultTimeout sets the default request timeout on HTTP requests that have no timeout of their own
+func (fc *frankenPHPContext) applyDefaultTimeout() {
+	if fc.timeout == nil && !fc.timeoutDisabled && defaultRequestTimeout > 0 {
+		fc.timeout = newRequestTimeout(defaultRequestTimeout)
+	}
+}
+
+// wait blocks until PHP has handled the request or the request has been abandoned after its timeout
+func (fc *frankenPHPContext) wait() {
+	if fc.timeout == nil {
+		<-fc.done
+
+		return
+	}
+
+	select {
+	case <-fc.done:
+	case <-fc.timeout.abandoned:
+	}
+}
+
+// startTimeout arms the request timeout once PHP starts handling the request on the thread
+func (fc *frankenPHPContext) startTimeout(thread *phpThread) {
+	rt := fc.timeout
+	if rt == nil {
+		return
+	}
+
+	d := rt.duration
+	if !rt.deadline.IsZero() {
+		d = time.Until(rt.deadline)
+	}
+
+	rt.mu.Lock()
+	rt.timer = time.AfterFunc(d, func() { fc.onTimeout(thread) })
+	rt.mu.Unlock()
+}
+
+// stopTimeout disarms the request timeout once PHP has handled the request
+func (fc *frankenPHPContext) stopTimeout() {
+	rt := fc.timeout
+	if rt == nil {
+		return
+	}
+
+	rt.mu.Lock()
+	rt.finished = true
+	if rt.timer != nil {
+		rt.timer.Stop()
+	}
+	rt.mu.Unlock()
+}
+
+// onTimeout interrupts the script with a catchable error and gives it a grace period to yield
+func (fc *frankenPHPContext) onTimeout(thread *phpThread) {
+	// the handler must be read before locking, closing the request may happen while the handler is being swapped
+	worker := threadWorker(thread)
+
+	rt := fc.timeout
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if rt.finished {
+		return
+	}
+
+	if worker != nil {
+		metrics.TimedOutWorkerRequest(worker.name)
+	} else {
+		metrics.TimedOutRequest()
+	}
+
+	fc.logger.LogAttrs(context.Background(), slog.LevelWarn, "request timeout exceeded, interrupting the script", slog.Int("thread", thread.threadIndex), slog.String("url", fc.request.RequestURI), slog.Duration("timeout", rt.duration))
+
+	rt.interruptPending.Store(true)
+	thread.interrupt()
+
+	rt.timer = time.AfterFunc(requestTimeoutGracePeriod, func() { fc.abandon(thread) })
+}
+
+// abandon answers the client with a 504 if the script did not yield within the grace period.
+// A PHP thread cannot be stopped from Go, it stays busy until the script yields, e.g. once a blocking call returns.
+// A worker script is then restarted, while a regular thread simply handles the next request.
+func (fc *frankenPHPContext) abandon(thread *phpThread) {
+	rt := fc.timeout
+	rt.mu.Lock()
+	if rt.finished {
+		rt.mu.Unlock()
+
+		return
+	}
+	rt.finished = true
+
+	fc.logger.LogAttrs(context.Background(), slog.LevelError, "script did not yield after its request timeout, its thread stays busy until it does", slog.Int("thread", thread.threadIndex), slog.String("url", fc.request.RequestURI), slog.Duration("grace_period", requestTimeoutGracePeriod))
+
+	// calls from Go have no response writer
+	if rt.writer != nil {
+		rt.writer.detach()
+	}
+	close(rt.abandoned)
+	rt.mu.Unlock()
+
+	thread.handlerMu.Lock()
+	handler, ok := thread.handler.(*workerThread)
+	thread.handlerMu.Unlock()
+
+	if ok {
+		handler.scheduleRestart()
+	}
+}
+
+// threadWorker returns the worker the thread belongs to, or nil for regular threads
+func threadWorker(thread *phpThread) *worker {
+	thread.handlerMu.Lock()
+	defer thread.handlerMu.Unlock()
+
+	if handler, ok := thread.handler.(*workerThread); ok {
+		return handler.worker
+	}
+
+	return nil
+}
+
+//export go_is_request_timed_out
+func go_is_request_timed_out(threadIndex C.uintptr_t) C.bool {
+	fc := phpThreads[threadIndex].getRequestContext()
+	if fc == nil || fc.timeout == nil {
+		return C.bool(false)
+	}
+
+	// the error is only thrown once per request
+	return C.bool(fc.timeout.interruptPending.CompareAndSwap(true, false))
+}
+
+// timeoutResponseWriter lets a timed out request be answered with a 504 while the PHP thread is still running,
+// writes of the PHP thread are discarded once the request has been abandoned
+type timeoutResponseWriter struct {
+	mu sync.Mutex
+	w  http.ResponseWriter
+	// headers written by the PHP thread, copied to w when the status code is sent
+	header      http.Header
+	wroteHeader bool
+	detached    bool
+}
+
+func newTimeoutResponseWriter(w http.ResponseWriter) *timeoutResponseWriter {
+	return &timeoutResponseWriter{w: w, header: w.Header().Clone()}
+}
+
+func (tw *timeoutResponseWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutResponseWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.detached {
+		return
+	}
+
+	tw.writeHeader(status)
+}
+
+func (tw *timeoutResponseWriter) writeHeader(status int) {
+	h := tw.w.Header()
+	clear(h)
+	for k, v := range tw.header {
+		h[k] = v
+	}
+
+	tw.w.WriteHeader(status)
+	if status >= 200 {
+		tw.wroteHeader = true
+	}
+}
+
+func (tw *timeoutResponseWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.detached {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutResponseWriter) FlushError() error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.detached {
+		return http.ErrHandlerTimeout
+	}
+
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+
+	return http.NewResponseController(tw.w).Flush()
+}
+
+func (tw *timeoutResponseWriter) Flush() {
+	_ = tw.FlushError()
+}
+
+func (tw *timeoutResponseWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
+
+// detach sends a 504 if the PHP thread has not sent a status code yet and discards its further writes
+func (tw *timeoutResponseWriter) detach() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.detached = true
+	if !tw.wroteHeader {
+		tw.w.WriteHeader(http.StatusGatewayTimeout)
+		_, _ = tw.w.Write([]byte("Gateway Timeout"))
+	}
+}
@@ -0,0 +1,144 @@
+package frankenphp
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+)
+
+// WarmupRequest is a synthetic request replayed on each worker thread
+// after its worker script has booted and before it receives traffic.
+type WarmupRequest struct {
+	// Path of the request, including the query string, e.g. /products?page=1
+	Path string
+	// Method of the request. Default: GET.
+	Method string
+	// Header of the request
+	Header http.Header
+}
+
+// warmupResponseWriter discards the response of a warm-up request and keeps its status code
+type warmupResponseWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *warmupResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *warmupResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return len(b), nil
+}
+
+func (w *warmupResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *warmupResponseWriter) Flush() {}
+
+// newWarmupContext creates the context of a warm-up request, in the same way as the dummy context of the worker
+func newWarmupContext(worker *worker, wr WarmupRequest) (*frankenPHPContext, error) {
+	method := wr.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	r, err := http.NewRequest(method, wr.Path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Header = wr.Header.Clone()
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	r.Host = "localhost"
+	if host := r.Header.Get("Host"); host != "" {
+		r.Host = host
+	}
+	r.RemoteAddr = "127.0.0.1"
+
+	fr, err := NewRequestWithContext(
+		r,
+		WithRequestDocumentRoot(filepath.Dir(worker.fileName), false),
+		WithRequestPreparedEnv(worker.env),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	fc, _ := fromContext(fr.Context())
+	fc.responseWriter = &warmupResponseWriter{header: make(http.Header)}
+
+	return fc, nil
+}
+
+// nextWarmupRequest returns the next warm-up request to replay on the thread
+// or nil once all of them have been replayed or the thread is draining
+func (handler *workerThread) nextWarmupRequest() *frankenPHPContext {
+	handler.finishWarmupRequest()
+
+	for handler.warmupIndex < len(handler.worker.warmup) {
+		select {
+		case <-handler.thread.drainChan:
+			// the thread is restarting or shutting down, the warm-up starts over on the next boot
+			handler.warmupIndex = 0
+
+			return nil
+		default:
+		}
+
+		wr := handler.worker.warmup[handler.warmupIndex]
+		handler.warmupIndex++
+
+		fc, err := newWarmupContext(handler.worker, wr)
+		if err != nil {
+			handler.failWarmup(wr.Path, err)
+
+			continue
+		}
+
+		handler.warmupContext = fc
+
+		return fc
+	}
+
+	return nil
+}
+
+// finishWarmupRequest checks the response of the last warm-up request
+func (handler *workerThread) finishWarmupRequest() {
+	fc := handler.warmupContext
+	if fc == nil {
+		return
+	}
+	handler.warmupContext = nil
+
+	if status := fc.responseWriter.(*warmupResponseWriter).status; status >= http.StatusInternalServerError {
+		handler.failWarmup(fc.request.URL.RequestURI(), nil, slog.Int("status", status))
+
+		return
+	}
+
+	logger.LogAttrs(context.Background(), slog.LevelDebug, "warm-up request finished", slog.String("worker", handler.worker.name), slog.Int("thread", handler.thread.threadIndex), slog.String("url", fc.request.URL.RequestURI()))
+}
+
+// failWarmup logs and counts a failed warm-up request, the thread keeps booting
+func (handler *workerThread) failWarmup(url string, err error, attrs ...slog.Attr) {
+	metrics.FailedWorkerWarmup(handler.worker.name)
+
+	attrs = append(attrs, slog.String("worker", handler.worker.name), slog.Int("thread", handler.thread.threadIndex), slog.String("url", url))
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+
+	logger.LogAttrs(context.Background(), slog.LevelWarn, "warm-up request failed", attrs...)
+}
@@ -0,0 +1,277 @@
+package frankenphp
+
+// #include "frankenphp.h"
+import "C"
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"golang.org/x/net/websocket"
+)
+
+// webSocketWriteTimeout bounds the time spent sending a message to a client
+const webSocketWriteTimeout = 10 * time.Second
+
+// types of the events delivered to frankenphp_handle_websocket()
+const (
+	webSocketOpen    = "open"
+	webSocketMessage = "message"
+	webSocketClose   = "close"
+)
+
+var (
+	// webSocketConns contains the open connections of all WebSocket workers, by ID
+	webSocketConns    sync.Map
+	lastWebSocketConn atomic.Int64
+	webSocketConnsWG  sync.WaitGroup
+)
+
+// webSocketConn is a WebSocket connection held in Go, its events are handled by the worker script one at a time
+type webSocketConn struct {
+	id     int64
+	worker *worker
+	ws     *websocket.Conn
+	// context of the upgrade request, copied for each event
+	fc *frankenPHPContext
+}
+
+// webSocketEvent is an event of a connection handled by frankenphp_handle_websocket()
+type webSocketEvent struct {
+	kind   string
+	connID int64
+	data   []byte
+	binary bool
+	// true once the event has been passed to the callback of the worker script
+	handled bool
+}
+
+// webSocketEventCodec receives text and binary messages while keeping track of their type
+var webSocketEventCodec = websocket.Codec{Unmarshal: func(data []byte, payloadType byte, v any) error {
+	event := v.(*webSocketEvent)
+	event.data = data
+	event.binary = payloadType == websocket.BinaryFrame
+
+	return nil
+}}
+
+// hijackableResponseWriter exposes the Hijack method of wrapped response writers, such as Caddy's
+type hijackableResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w hijackableResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return r.ProtoMajor == 1 &&
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+// serveWebSocket upgrades the connection and blocks until it is closed
+func (worker *worker) serveWebSocket(fc *frankenPHPContext, w http.ResponseWriter, r *http.Request) {
+	if !isWebSocketUpgrade(r) {
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Upgrade", "websocket")
+		fc.reject(http.StatusUpgradeRequired, "Upgrade Required")
+
+		return
+	}
+
+	webSocketConnsWG.Add(1)
+	defer webSocketConnsWG.Done()
+
+	server := websocket.Server{
+		// the origin can be checked by the worker script when the connection is opened
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			conn := &webSocketConn{
+				id:     lastWebSocketConn.Add(1),
+				worker: worker,
+				ws:     ws,
+				fc:     fc,
+			}
+			conn.serve()
+		},
+	}
+	server.ServeHTTP(hijackableResponseWriter{w}, r)
+
+	fc.closeContext()
+}
+
+// serve delivers the events of the connection to the worker script until the connection is closed
+func (conn *webSocketConn) serve() {
+	webSocketConns.Store(conn.id, conn)
+
+	if !conn.dispatch(&webSocketEvent{kind: webSocketOpen, connID: conn.id}) {
+		webSocketConns.Delete(conn.id)
+		logger.LogAttrs(context.Background(), slog.LevelWarn, "the worker did not handle the opening of the WebSocket connection, closing it", slog.String("worker", conn.worker.name), slog.Int64("connection", conn.id))
+
+		return
+	}
+
+	for {
+		event := &webSocketEvent{kind: webSocketMessage, connID: conn.id}
+		if err := webSocketEventCodec.Receive(conn.ws, event); err != nil {
+			if errors.Is(err, websocket.ErrFrameTooLarge) {
+				logger.LogAttrs(context.Background(), slog.LevelWarn, "WebSocket message too large, skipping", slog.String("worker", conn.worker.name), slog.Int64("connection", conn.id))
+
+				continue
+			}
+
+			break
+		}
+
+		if !conn.dispatch(event) {
+			// the worker has been removed or is unavailable, the client is expected to reconnect
+			logger.LogAttrs(context.Background(), slog.LevelWarn, "the worker did not handle the WebSocket message, closing the connection", slog.String("worker", conn.worker.name), slog.Int64("connection", conn.id))
+			_ = conn.ws.Close()
+
+			break
+		}
+	}
+
+	webSocketConns.Delete(conn.id)
+	conn.dispatch(&webSocketEvent{kind: webSocketClose, connID: conn.id})
+}
+
+// dispatch sends the event to the worker and waits until it has been handled, which keeps the events of a connection in order
+func (conn *webSocketConn) dispatch(event *webSocketEvent) bool {
+	if !isRunning {
+		return false
+	}
+
+	fc := conn.newEventContext(event)
+	conn.worker.handleRequest(fc)
+
+	return event.handled
+}
+
+// newEventContext copies the context of the upgrade request, so that $_SERVER describes it in all events
+func (conn *webSocketConn) newEventContext(event *webSocketEvent) *frankenPHPContext {
+	fc := &frankenPHPContext{
+		documentRoot:    conn.fc.documentRoot,
+		splitPath:       conn.fc.splitPath,
+		env:             conn.fc.env,
+		logger:          conn.fc.logger,
+		request:         conn.fc.request.WithContext(context.WithoutCancel(conn.fc.request.Context())),
+		originalRequest: conn.fc.originalRequest,
+		docURI:          conn.fc.docURI,
+		pathInfo:        conn.fc.pathInfo,
+		scriptName:      conn.fc.scriptName,
+		scriptFilename:  conn.fc.scriptFilename,
+		workerName:      conn.fc.workerName,
+		priority:        conn.fc.priority,
+		done:            make(chan interface{}),
+		startedAt:       time.Now(),
+		webSocketEvent:  event,
+	}
+	// the request timeout applies to each event
+	if conn.fc.timeout != nil {
+		_ = WithRequestTimeout(conn.fc.timeout.duration)(fc)
+	}
+
+	return fc
+}
+
+// serialize converts the event to the array passed to the callback of frankenphp_handle_websocket()
+func (event *webSocketEvent) serialize() []byte {
+	var data any
+	if event.kind == webSocketMessage {
+		data = string(event.data)
+	}
+
+	b, _ := serializePHP(map[string]any{
+		"type":       event.kind,
+		"connection": event.connID,
+		"data":       data,
+		"binary":     event.binary,
+	})
+
+	return b
+}
+
+// sendWebSocketMessage sends a text or binary message to an open connection
+func sendWebSocketMessage(connID int64, data []byte, binary bool) error {
+	v, ok := webSocketConns.Load(connID)
+	if !ok {
+		return net.ErrClosed
+	}
+	ws := v.(*webSocketConn).ws
+
+	if err := ws.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout)); err != nil {
+		return err
+	}
+
+	if binary {
+		return websocket.Message.Send(ws, data)
+	}
+
+	return websocket.Message.Send(ws, string(data))
+}
+
+// closeWebSocket closes an open connection, the worker then receives its close event
+func closeWebSocket(connID int64) bool {
+	v, ok := webSocketConns.Load(connID)
+	if !ok {
+		return false
+	}
+
+	return v.(*webSocketConn).ws.Close() == nil
+}
+
+// drainWebSockets closes all connections and waits until their close events have been handled
+func drainWebSockets() {
+	webSocketConns.Range(func(_, v any) bool {
+		_ = v.(*webSocketConn).ws.Close()
+
+		return true
+	})
+
+	webSocketConnsWG.Wait()
+}
+
+// go_frankenphp_websocket_event returns the serialized event handled by the thread,
+// or nil if the thread is handling an HTTP request or a task
+//
+//export go_frankenphp_websocket_event
+func go_frankenphp_websocket_event(threadIndex C.uintptr_t) (*C.char, C.size_t) {
+	thread := phpThreads[threadIndex]
+	fc := thread.getRequestContext()
+	if fc == nil || fc.webSocketEvent == nil {
+		return nil, 0
+	}
+
+	fc.webSocketEvent.handled = true
+	payload := fc.webSocketEvent.serialize()
+
+	p := unsafe.SliceData(payload)
+	thread.Pin(p)
+
+	return (*C.char)(unsafe.Pointer(p)), C.size_t(len(payload))
+}
+
+//export go_frankenphp_ws_send
+func go_frankenphp_ws_send(connID C.zend_long, data *C.char, dataLen C.size_t, binary C.bool) C.bool {
+	err := sendWebSocketMessage(int64(connID), C.GoBytes(unsafe.Pointer(data), C.int(dataLen)), bool(binary))
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		logger.LogAttrs(context.Background(), slog.LevelDebug, "unable to send the WebSocket message", slog.Int64("connection", int64(connID)), slog.Any("error", err))
+	}
+
+	return C.bool(err == nil)
+}
+
+//export go_frankenphp_ws_close
+func go_frankenphp_ws_close(connID C.zend_long) C.bool {
+	return C.bool(closeWebSocket(int64(connID)))
+}
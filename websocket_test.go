@@ -0,0 +1,72 @@
+package frankenphp_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dunglas/frankenphp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func TestWebSocket(t *testing.T) {
+	runTest(t, func(_ func(http.ResponseWriter, *http.Request), ts *httptest.Server, i int) {
+		closed := frankenphp.Subscribe("websocket-closed")
+		defer closed.Unsubscribe()
+
+		url := strings.Replace(ts.URL, "http://", "ws://", 1) + fmt.Sprintf("/websocket.php?i=%d", i)
+		ws, err := websocket.Dial(url, "", ts.URL)
+		require.NoError(t, err)
+		defer ws.Close()
+
+		var message string
+		require.NoError(t, websocket.Message.Receive(ws, &message))
+		assert.Equal(t, fmt.Sprintf("welcome i=%d", i), message, "$_SERVER must describe the upgrade request")
+
+		require.NoError(t, websocket.Message.Send(ws, "hello"))
+		require.NoError(t, websocket.Message.Receive(ws, &message))
+		assert.Equal(t, "echo:hello", message)
+
+		var binary []byte
+		require.NoError(t, websocket.Message.Send(ws, []byte{0, 1}))
+		require.NoError(t, websocket.Message.Receive(ws, &binary))
+		assert.Equal(t, []byte("binary:\x00\x01"), binary)
+
+		// the worker closes the connection
+		require.NoError(t, websocket.Message.Send(ws, "bye"))
+		assert.Error(t, websocket.Message.Receive(ws, &message))
+
+		select {
+		case <-closed.Messages():
+		case <-time.After(time.Second):
+			t.Error("the worker did not receive the close event")
+		}
+	}, &testOptions{
+		workerScript:       "websocket.php",
+		nbWorkers:          2,
+		nbParallelRequests: 10,
+		realServer:         true,
+		workerOpts:         []frankenphp.WorkerOption{frankenphp.WithWorkerWebSocket(true)},
+	})
+}
+
+func TestWebSocketRequiresUpgrade(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		req := httptest.NewRequest("GET", "http://example.com/websocket.php", nil)
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		assert.Equal(t, http.StatusUpgradeRequired, w.Code)
+		assert.Equal(t, "websocket", w.Header().Get("Upgrade"))
+	}, &testOptions{
+		workerScript:       "websocket.php",
+		nbWorkers:          1,
+		nbParallelRequests: 1,
+		workerOpts:         []frankenphp.WorkerOption{frankenphp.WithWorkerWebSocket(true)},
+	})
+}
@@ -3,9 +3,16 @@ package frankenphp
 // #include "frankenphp.h"
 import "C"
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dunglas/frankenphp/internal/fastabs"
@@ -18,37 +25,69 @@ type worker struct {
 	fileName    string
 	num         int
 	env         PreparedEnv
+	maxRequests int
+	maxMemory   int64
+	minThreads  int
+	maxThreads  int
 	requestChan chan *frankenPHPContext
-	threads     []*phpThread
-	threadMutex sync.RWMutex
+	// number of requests currently waiting for a thread, bounded by maxQueueLength
+	queueLength    atomic.Int64
+	maxQueueLength int
+	// requests waiting for a thread, ordered by priority
+	requestQueue requestQueue
+	threads      []*phpThread
+	threadMutex  sync.RWMutex
+	// closed once the worker has been removed or replaced at runtime
+	removed chan struct{}
+	// the worker handling the requests that were still waiting once this one has been replaced
+	replacement *worker
+	// requests replayed on each thread after the worker script has booted
+	warmup []WarmupRequest
+	// true if the worker script handles WebSocket connections with frankenphp_handle_websocket()
+	webSocket bool
+	// true if the worker script handles tasks with frankenphp_handle_task(), HTTP requests are never routed to it
+	tasks bool
+	// what happens once the worker script failed to boot maxConsecutiveFailures times in a row
+	crashLoopPolicy        CrashLoopPolicy
+	minBackoff             time.Duration
+	maxBackoff             time.Duration
+	maxConsecutiveFailures int
+	// body and content type of the 503 response sent while the worker is disabled
+	unavailablePage     []byte
+	unavailablePageType string
+	healthMu            sync.RWMutex
+	unhealthy           bool
+	// closed once the worker is disabled, replaced once it has recovered
+	disabled chan struct{}
 }
 
+var ErrWorkerNotFound = errors.New("worker not found")
+
 var (
-	workers          map[string]*worker
-	watcherIsEnabled bool
+	workers             map[string]*worker
+	workersMu           sync.RWMutex
+	watcherIsEnabled    bool
+	watcherRestartBatch RestartBatch
+	// names of the workers removed at runtime, their requests are rejected instead of running on regular threads
+	removedWorkers map[string]struct{}
 )
 
 func initWorkers(opt []workerOpt) error {
+	workersMu.Lock()
 	workers = make(map[string]*worker, len(opt))
+	removedWorkers = make(map[string]struct{})
+	workersMu.Unlock()
 	workersReady := sync.WaitGroup{}
 	directoriesToWatch := getDirectoriesToWatch(opt)
 	watcherIsEnabled = len(directoriesToWatch) > 0
 
 	for _, o := range opt {
-		worker, err := newWorker(o)
+		worker, err := newWorker(o, nil)
 		if err != nil {
 			return err
 		}
 
-		workersReady.Add(o.num)
-		for i := 0; i < worker.num; i++ {
-			thread := getInactivePHPThread()
-			convertToWorkerThread(thread, worker)
-			go func() {
-				thread.state.waitFor(stateReady)
-				workersReady.Done()
-			}()
-		}
+		worker.startThreads(&workersReady)
 	}
 
 	workersReady.Wait()
@@ -58,7 +97,7 @@ func initWorkers(opt []workerOpt) error {
 	}
 
 	watcherIsEnabled = true
-	if err := watcher.InitWatcher(directoriesToWatch, RestartWorkers, logger); err != nil {
+	if err := watcher.InitWatcher(directoriesToWatch, restartWorkersOnFileChanges, logger); err != nil {
 		return err
 	}
 
@@ -73,18 +112,27 @@ func getWorkerKey(name string, filename string) string {
 	return key
 }
 
-func newWorker(o workerOpt) (*worker, error) {
+// newWorker creates a worker and registers it, unless it replaces a running worker: it is then registered once its threads are ready
+func newWorker(o workerOpt, replaced *worker) (*worker, error) {
 	absFileName, err := fastabs.FastAbs(o.fileName)
 	if err != nil {
 		return nil, fmt.Errorf("worker filename is invalid %q: %w", o.fileName, err)
 	}
 
+	// task workers never receive HTTP requests
+	if o.tasks && (o.webSocket || len(o.warmup) > 0) {
+		return nil, fmt.Errorf("task worker %q cannot handle WebSocket connections or warm-up requests", o.fileName)
+	}
+
+	workersMu.Lock()
+	defer workersMu.Unlock()
+
 	key := getWorkerKey(o.name, absFileName)
-	if _, ok := workers[key]; ok {
+	if w, ok := workers[key]; ok && w != replaced {
 		return nil, fmt.Errorf("two workers cannot use the same key %q", key)
 	}
 	for _, w := range workers {
-		if w.name == o.name {
+		if w.name == o.name && w != replaced {
 			return w, fmt.Errorf("two workers cannot have the same name: %q", o.name)
 		}
 	}
@@ -99,44 +147,316 @@ func newWorker(o workerOpt) (*worker, error) {
 
 	o.env["FRANKENPHP_WORKER\x00"] = "1"
 	w := &worker{
-		name:        o.name,
-		fileName:    absFileName,
-		num:         o.num,
-		env:         o.env,
-		requestChan: make(chan *frankenPHPContext),
-		threads:     make([]*phpThread, 0, o.num),
+		name:           o.name,
+		fileName:       absFileName,
+		num:            o.num,
+		env:            o.env,
+		maxRequests:    o.maxRequests,
+		maxMemory:      o.maxMemory,
+		minThreads:     o.minThreads,
+		maxThreads:     o.maxThreads,
+		maxQueueLength: o.maxQueueLength,
+		requestChan:    make(chan *frankenPHPContext),
+		threads:        make([]*phpThread, 0, o.num),
+		removed:        make(chan struct{}),
+		warmup:         o.warmup,
+		webSocket:      o.webSocket,
+		tasks:          o.tasks,
+
+		crashLoopPolicy:        o.crashLoopPolicy,
+		minBackoff:             o.minBackoff,
+		maxBackoff:             o.maxBackoff,
+		maxConsecutiveFailures: o.maxConsecutiveFailures,
+		unavailablePage:        o.unavailablePage,
+		unavailablePageType:    o.unavailablePageType,
+		disabled:               make(chan struct{}),
+	}
+	if w.crashLoopPolicy == "" {
+		w.crashLoopPolicy = CrashLoopPolicyPanic
+	}
+	if w.crashLoopPolicy == CrashLoopPolicyRetryForever && maxWaitTime == 0 {
+		logger.LogAttrs(context.Background(), slog.LevelWarn, "max_wait_time is not set, requests will wait with no limit while the worker is unhealthy", slog.String("worker", w.name), slog.String("policy", string(w.crashLoopPolicy)))
+	}
+	if w.minBackoff == 0 {
+		w.minBackoff = defaultMinBackoff
+	}
+	if w.maxBackoff == 0 {
+		w.maxBackoff = max(defaultMaxBackoff, w.minBackoff)
+	}
+	if w.maxConsecutiveFailures == 0 {
+		w.maxConsecutiveFailures = defaultMaxConsecutiveFailures
+	}
+	// by default, the threads started on boot are never downscaled
+	if w.minThreads == 0 {
+		w.minThreads = w.num
+	}
+	if replaced == nil {
+		workers[key] = w
+		delete(removedWorkers, w.name)
 	}
-	workers[key] = w
 
 	return w, nil
 }
 
+// startThreads converts spare threads to the worker, ready is done once each of them has booted
+func (worker *worker) startThreads(ready *sync.WaitGroup) {
+	ready.Add(worker.num)
+	for i := 0; i < worker.num; i++ {
+		thread := getInactivePHPThread()
+		convertToWorkerThread(thread, worker)
+		go func() {
+			thread.state.waitFor(stateReady)
+			ready.Done()
+		}()
+	}
+}
+
+// AddWorker starts a worker at runtime on spare threads (up to max_threads) without restarting the other workers.
+// The arguments are the same as for WithWorkers, watching files is only supported for workers passed to Init.
+func AddWorker(name string, fileName string, num int, env map[string]string, options ...WorkerOption) error {
+	if !isRunning {
+		return ErrNotRunning
+	}
+
+	o, err := newWorkerOpt(name, fileName, num, env, nil, options...)
+	if err != nil {
+		return err
+	}
+
+	if err := resolveWorkerNum(&o, runtime.GOMAXPROCS(0)*2); err != nil {
+		return err
+	}
+
+	// disallow scaling threads while the worker is starting
+	scalingMu.Lock()
+	defer scalingMu.Unlock()
+
+	if spare := countSpareThreads(); spare < o.num {
+		return fmt.Errorf("%w: %d needed, %d available", ErrNotEnoughSpareThreads, o.num, spare)
+	}
+
+	worker, err := newWorker(o, nil)
+	if err != nil {
+		return err
+	}
+
+	metrics.TotalWorkers(worker.name, worker.num)
+
+	ready := sync.WaitGroup{}
+	worker.startThreads(&ready)
+	ready.Wait()
+
+	logger.LogAttrs(context.Background(), slog.LevelInfo, "worker added", slog.String("worker", worker.name), slog.Int("num", worker.num))
+
+	return nil
+}
+
+// ReplaceWorker starts a new version of a running worker with the same name at runtime and swaps them once its threads are ready.
+// If the new version fails to boot, it is discarded and the old version keeps serving requests.
+// The threads of the old version finish their current request before being converted back to inactive threads,
+// the requests still waiting for it are handed over to the new version.
+// Both versions run side by side while the new one boots, so there must be enough spare threads for it.
+func ReplaceWorker(name string, fileName string, num int, env map[string]string, options ...WorkerOption) error {
+	if !isRunning {
+		return ErrNotRunning
+	}
+
+	o, err := newWorkerOpt(name, fileName, num, env, nil, options...)
+	if err != nil {
+		return err
+	}
+
+	if err := resolveWorkerNum(&o, runtime.GOMAXPROCS(0)*2); err != nil {
+		return err
+	}
+
+	if name == "" {
+		if name, err = fastabs.FastAbs(fileName); err != nil {
+			return fmt.Errorf("worker filename is invalid %q: %w", fileName, err)
+		}
+	}
+
+	// disallow scaling threads while the workers are swapped
+	scalingMu.Lock()
+	defer scalingMu.Unlock()
+
+	old := getWorkerByName(name)
+	if old == nil {
+		return fmt.Errorf("%w: %q", ErrWorkerNotFound, name)
+	}
+
+	if spare := countSpareThreads(); spare < o.num {
+		return fmt.Errorf("%w: %d needed, %d available", ErrNotEnoughSpareThreads, o.num, spare)
+	}
+
+	worker, err := newWorker(o, old)
+	if err != nil {
+		return err
+	}
+
+	// the new version only accepts requests once all its threads have booted, the old one keeps serving them meanwhile
+	threads := make([]*phpThread, 0, worker.num)
+	standby := make(chan struct{})
+	results := make([]chan error, 0, worker.num)
+	for range worker.num {
+		thread := getInactivePHPThread()
+		if thread == nil {
+			// a thread was started by another part of the server in the meantime
+			for _, t := range threads {
+				retireThread(t)
+			}
+
+			return ErrNotEnoughSpareThreads
+		}
+
+		result := make(chan error, 1)
+		results = append(results, result)
+		convertToStandbyWorkerThread(thread, worker, result, standby)
+		threads = append(threads, thread)
+	}
+
+	var errs []error
+	for _, result := range results {
+		if err := <-result; err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		for _, thread := range threads {
+			retireThread(thread)
+		}
+		logger.LogAttrs(context.Background(), slog.LevelError, "worker replacement aborted, keeping the previous version", slog.String("worker", worker.name), slog.Any("error", errors.Join(errs...)))
+
+		return fmt.Errorf("worker %q not replaced: %w", worker.name, errors.Join(errs...))
+	}
+
+	metrics.TotalWorkers(worker.name, worker.num)
+	for _, thread := range threads {
+		worker.attachThread(thread)
+	}
+	close(standby)
+
+	// requests are routed to the new version from now on
+	workersMu.Lock()
+	for key, w := range workers {
+		if w == old {
+			delete(workers, key)
+			break
+		}
+	}
+	workers[getWorkerKey(worker.name, worker.fileName)] = worker
+	workersMu.Unlock()
+
+	old.retire(worker)
+
+	logger.LogAttrs(context.Background(), slog.LevelInfo, "worker replaced", slog.String("worker", worker.name), slog.Int("num", worker.num))
+
+	return nil
+}
+
+// RemoveWorker stops a worker at runtime and converts its threads back to inactive threads.
+// Requests that are still waiting for the worker are rejected with a 503, its script cannot run on regular threads.
+func RemoveWorker(name string) error {
+	if !isRunning {
+		return ErrNotRunning
+	}
+
+	// disallow scaling threads while the worker is stopping
+	scalingMu.Lock()
+	defer scalingMu.Unlock()
+
+	workersMu.Lock()
+	var worker *worker
+	for key, w := range workers {
+		if w.name == name {
+			worker = w
+			delete(workers, key)
+			removedWorkers[name] = struct{}{}
+			break
+		}
+	}
+	workersMu.Unlock()
+
+	if worker == nil {
+		return fmt.Errorf("%w: %q", ErrWorkerNotFound, name)
+	}
+
+	worker.retire(nil)
+	metrics.RemoveWorker(worker.name)
+
+	logger.LogAttrs(context.Background(), slog.LevelInfo, "worker removed", slog.String("worker", worker.name))
+
+	return nil
+}
+
+// retire converts the threads of a worker that is no longer registered back to inactive threads, scalingMu must be held.
+// Requests still waiting for the worker are handed over to its replacement right away, threads finish their current request first.
+func (worker *worker) retire(replacement *worker) {
+	worker.replacement = replacement
+	close(worker.removed)
+
+	worker.threadMutex.RLock()
+	threads := slices.Clone(worker.threads)
+	worker.threadMutex.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, thread := range threads {
+		autoScaledThreads = slices.DeleteFunc(autoScaledThreads, func(t *phpThread) bool { return t == thread })
+
+		wg.Add(1)
+		go func(thread *phpThread) {
+			defer wg.Done()
+			convertToInactiveThread(thread)
+		}(thread)
+	}
+	wg.Wait()
+}
+
+// isRemoved returns true once the worker has been removed at runtime
+func (worker *worker) isRemoved() bool {
+	select {
+	case <-worker.removed:
+		return true
+	default:
+		return false
+	}
+}
+
 // EXPERIMENTAL: DrainWorkers finishes all worker scripts before a graceful shutdown
 func DrainWorkers() {
 	_ = drainWorkerThreads()
 }
 
 func drainWorkerThreads() []*phpThread {
-	ready := sync.WaitGroup{}
-	drainedThreads := make([]*phpThread, 0)
-	for _, worker := range workers {
+	threads := make([]*phpThread, 0)
+	for _, worker := range listWorkers() {
 		worker.threadMutex.RLock()
-		ready.Add(len(worker.threads))
-		for _, thread := range worker.threads {
-			if !thread.state.requestSafeStateChange(stateRestarting) {
-				// no state change allowed == thread is shutting down
-				// we'll proceed to restart all other threads anyways
-				continue
-			}
-			close(thread.drainChan)
-			drainedThreads = append(drainedThreads, thread)
-			go func(thread *phpThread) {
-				thread.state.waitFor(stateYielding)
-				ready.Done()
-			}(thread)
-		}
+		threads = append(threads, worker.threads...)
 		worker.threadMutex.RUnlock()
 	}
+
+	return drainThreads(threads)
+}
+
+// drainThreads makes the worker scripts of the given threads return and waits for them to yield
+func drainThreads(threads []*phpThread) []*phpThread {
+	ready := sync.WaitGroup{}
+	drainedThreads := make([]*phpThread, 0, len(threads))
+	for _, thread := range threads {
+		if !thread.state.requestSafeStateChange(stateRestarting) {
+			// no state change allowed == thread is shutting down
+			// we'll proceed to restart all other threads anyways
+			continue
+		}
+		close(thread.drainChan)
+		drainedThreads = append(drainedThreads, thread)
+		ready.Add(1)
+		go func(thread *phpThread) {
+			thread.state.waitFor(stateYielding)
+			ready.Done()
+		}(thread)
+	}
 	ready.Wait()
 
 	return drainedThreads
@@ -154,14 +474,88 @@ func RestartWorkers() {
 	scalingMu.Lock()
 	defer scalingMu.Unlock()
 
-	threadsToRestart := drainWorkerThreads()
+	restartDrainedThreads(drainWorkerThreads())
+}
+
+// RestartWorker attempts to restart all threads of the worker with the given name gracefully.
+// It returns the indices of the restarted threads, threads that are shutting down are skipped.
+func RestartWorker(name string) ([]int, error) {
+	worker := getWorkerByName(name)
+	if worker == nil {
+		return nil, fmt.Errorf("%w: %q", ErrWorkerNotFound, name)
+	}
+
+	// disallow scaling threads while restarting the worker
+	scalingMu.Lock()
+	defer scalingMu.Unlock()
+
+	worker.threadMutex.RLock()
+	threads := slices.Clone(worker.threads)
+	worker.threadMutex.RUnlock()
+
+	drainedThreads := drainThreads(threads)
+	restartDrainedThreads(drainedThreads)
+
+	indices := make([]int, 0, len(drainedThreads))
+	for _, thread := range drainedThreads {
+		indices = append(indices, thread.threadIndex)
+	}
+
+	return indices, nil
+}
 
-	for _, thread := range threadsToRestart {
+// restartDrainedThreads lets drained threads boot their worker script again
+func restartDrainedThreads(threads []*phpThread) {
+	for _, thread := range threads {
 		thread.drainChan = make(chan struct{})
 		thread.state.set(stateReady)
 	}
 }
 
+func getWorkerByName(name string) *worker {
+	workersMu.RLock()
+	defer workersMu.RUnlock()
+
+	for _, w := range workers {
+		if w.name == name {
+			return w
+		}
+	}
+
+	return nil
+}
+
+// isRemovedWorker returns true if the worker with the given name has been removed at runtime
+func isRemovedWorker(name string) bool {
+	workersMu.RLock()
+	_, ok := removedWorkers[name]
+	workersMu.RUnlock()
+
+	return ok
+}
+
+// getWorker returns the worker registered under the given key
+func getWorker(key string) (*worker, bool) {
+	workersMu.RLock()
+	w, ok := workers[key]
+	workersMu.RUnlock()
+
+	return w, ok
+}
+
+// listWorkers returns a snapshot of all registered workers
+func listWorkers() []*worker {
+	workersMu.RLock()
+	defer workersMu.RUnlock()
+
+	list := make([]*worker, 0, len(workers))
+	for _, w := range workers {
+		list = append(list, w)
+	}
+
+	return list
+}
+
 func getDirectoriesToWatch(workerOpts []workerOpt) []string {
 	directoriesToWatch := []string{}
 	for _, w := range workerOpts {
@@ -195,16 +589,49 @@ func (worker *worker) countThreads() int {
 	return l
 }
 
+// canScale returns false if the worker has reached its max_threads
+func (worker *worker) canScale() bool {
+	return worker.maxThreads <= 0 || worker.countThreads() < worker.maxThreads
+}
+
+// canDownScale returns false if the worker would drop below its min_threads
+func (worker *worker) canDownScale() bool {
+	return worker.countThreads() > worker.minThreads
+}
+
+// hasDownScalableThreads returns true if the worker was started with more threads than its min_threads
+func (worker *worker) hasDownScalableThreads() bool {
+	return worker.minThreads < worker.num
+}
+
+// getScaleChan returns the channel used to trigger autoscaling
+// or nil if the worker is not allowed to scale any further
+func (worker *worker) getScaleChan() chan *frankenPHPContext {
+	if !worker.canScale() {
+		return nil
+	}
+
+	return scaleChan
+}
+
 func (worker *worker) handleRequest(fc *frankenPHPContext) {
 	metrics.StartWorkerRequest(worker.name)
 
+	// the worker failed to boot too many times, reject the request until it has recovered
+	if worker.isDisabled() {
+		metrics.StopWorkerRequest(worker.name, time.Since(fc.startedAt))
+		worker.rejectUnavailable(fc)
+		return
+	}
+
 	// dispatch requests to all worker threads in order
 	worker.threadMutex.RLock()
 	for _, thread := range worker.threads {
 		select {
 		case thread.requestChan <- fc:
 			worker.threadMutex.RUnlock()
-			<-fc.done
+			fc.markAccepted()
+			fc.wait()
 			metrics.StopWorkerRequest(worker.name, time.Since(fc.startedAt))
 			return
 		default:
@@ -213,18 +640,66 @@ func (worker *worker) handleRequest(fc *frankenPHPContext) {
 	}
 	worker.threadMutex.RUnlock()
 
+	// if the queue is full, reject the request immediately instead of letting it pile up
+	if !enqueue(&worker.queueLength, worker.maxQueueLength) {
+		metrics.StopWorkerRequest(worker.name, time.Since(fc.startedAt))
+		metrics.RejectedWorkerRequest(worker.name)
+		fc.rejectQueueFull()
+		return
+	}
+
 	// if no thread was available, mark the request as queued and apply the scaling strategy
+	// it is dispatched once it is next in line according to its priority
 	metrics.QueuedWorkerRequest(worker.name)
+	queued := worker.requestQueue.push(fc)
+	fc.markAccepted()
+	timeout := timeoutChan(maxWaitTime)
 	for {
+		dispatch, promoted := queued.dispatchChan(worker.requestChan)
 		select {
-		case worker.requestChan <- fc:
+		case dispatch <- fc:
+			worker.requestQueue.remove(queued)
+			worker.queueLength.Add(-1)
 			metrics.DequeuedWorkerRequest(worker.name)
-			<-fc.done
+			fc.wait()
 			metrics.StopWorkerRequest(worker.name, time.Since(fc.startedAt))
 			return
-		case scaleChan <- fc:
+		case <-promoted:
+			// the request is next in line, continue to wait for a thread
+		case worker.getScaleChan() <- fc:
 			// the request has triggered scaling, continue to wait for a thread
-		case <-timeoutChan(maxWaitTime):
+		case <-worker.removed:
+			worker.requestQueue.remove(queued)
+			worker.queueLength.Add(-1)
+			metrics.DequeuedWorkerRequest(worker.name)
+			metrics.StopWorkerRequest(worker.name, time.Since(fc.startedAt))
+			// the worker has been replaced while the request was waiting, the new version handles it
+			if r := worker.replacement; r != nil && r.webSocket == worker.webSocket && r.tasks == worker.tasks {
+				r.handleRequest(fc)
+				return
+			}
+			// the worker has been removed while the request was waiting, its script cannot run on regular threads
+			fc.reject(http.StatusServiceUnavailable, "Service Unavailable")
+			return
+		case <-fc.cancelled():
+			worker.requestQueue.remove(queued)
+			worker.queueLength.Add(-1)
+			metrics.DequeuedWorkerRequest(worker.name)
+			metrics.StopWorkerRequest(worker.name, time.Since(fc.startedAt))
+			// the caller has given up while the call was waiting, the callback must not run
+			fc.closeContext()
+			return
+		case <-worker.disabledChan():
+			worker.requestQueue.remove(queued)
+			worker.queueLength.Add(-1)
+			metrics.DequeuedWorkerRequest(worker.name)
+			metrics.StopWorkerRequest(worker.name, time.Since(fc.startedAt))
+			// the worker has been disabled while the request was waiting
+			worker.rejectUnavailable(fc)
+			return
+		case <-timeout:
+			worker.requestQueue.remove(queued)
+			worker.queueLength.Add(-1)
 			metrics.DequeuedWorkerRequest(worker.name)
 			// the request has timed out stalling
 			fc.reject(504, "Gateway Timeout")
@@ -9,9 +9,12 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -119,6 +122,273 @@ func TestWorkerGetOpt(t *testing.T) {
 	}
 }
 
+func TestWorkerMaxRequests(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		// the worker thread restarts after 2 requests, resetting the counter
+		for j := 0; j < 6; j++ {
+			body := fetchBody("GET", "http://example.com/worker-with-counter.php", handler)
+			assert.Equal(t, fmt.Sprintf("requests:%d", j%2+1), body)
+		}
+	}, &testOptions{
+		workerScript:       "worker-with-counter.php",
+		nbWorkers:          1,
+		nbParallelRequests: 1,
+		workerOpts:         []frankenphp.WorkerOption{frankenphp.WithWorkerMaxRequests(2)},
+	})
+}
+
+func TestWorkerMaxMemory(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		// any PHP script exceeds 1 byte, the worker thread is restarted after every request
+		for j := 0; j < 3; j++ {
+			body := fetchBody("GET", "http://example.com/worker-with-counter.php", handler)
+			assert.Equal(t, "requests:1", body)
+		}
+	}, &testOptions{
+		workerScript:       "worker-with-counter.php",
+		nbWorkers:          1,
+		nbParallelRequests: 1,
+		workerOpts:         []frankenphp.WorkerOption{frankenphp.WithWorkerMaxMemory(1)},
+	})
+}
+
+func TestWorkerRejectsRequestsWhenQueueIsFull(t *testing.T) {
+	var handled, rejected atomic.Int32
+
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		req := httptest.NewRequest("GET", "http://example.com/sleep.php?sleep=100", nil)
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		resp := w.Result()
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+			rejected.Add(1)
+
+			return
+		}
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		handled.Add(1)
+	}, &testOptions{
+		workerScript:       "sleep.php",
+		nbWorkers:          1,
+		nbParallelRequests: 10,
+		workerOpts:         []frankenphp.WorkerOption{frankenphp.WithWorkerMaxQueueLength(1)},
+	})
+
+	// a single thread and a single queued request cannot absorb 10 simultaneous requests
+	assert.Greater(t, rejected.Load(), int32(0))
+	assert.Greater(t, handled.Load(), int32(0))
+}
+
+func TestRollingRestartWorkers(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		assert.Equal(t, "requests:1", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+		assert.Equal(t, "requests:2", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+
+		assert.NoError(t, frankenphp.RollingRestartWorkers(frankenphp.RestartBatch{Threads: 1}))
+
+		assert.Equal(t, "requests:1", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+	}, &testOptions{
+		workerScript:       "worker-with-counter.php",
+		nbWorkers:          1,
+		nbParallelRequests: 1,
+	})
+}
+
+func TestRollingRestartRetriesBootFailures(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "fail-once")
+
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		require.NoError(t, os.WriteFile(marker, nil, 0o644))
+
+		// a single failed boot is retried instead of aborting the restart
+		assert.NoError(t, frankenphp.RollingRestartWorkers(frankenphp.RestartBatch{Threads: 1}))
+		assert.NoFileExists(t, marker)
+		assert.Equal(t, "booted", fetchBody("GET", "http://example.com/boot-failure-worker.php", handler))
+	}, &testOptions{
+		workerScript:       "boot-failure-worker.php",
+		nbWorkers:          1,
+		nbParallelRequests: 1,
+		env:                map[string]string{"BOOT_FAILURE_MARKER": marker},
+	})
+}
+
+func TestRestartWorkerByName(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		assert.Equal(t, "requests:1", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+		assert.Equal(t, "requests:2", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+
+		_, err := frankenphp.RestartWorker("unknown")
+		assert.ErrorIs(t, err, frankenphp.ErrWorkerNotFound)
+		assert.Equal(t, "requests:3", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+
+		restarted, err := frankenphp.RestartWorker("workerName")
+		assert.NoError(t, err)
+		assert.Len(t, restarted, 1)
+		assert.Equal(t, "requests:1", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+	}, &testOptions{
+		workerScript:       "worker-with-counter.php",
+		nbWorkers:          1,
+		nbParallelRequests: 1,
+	})
+}
+
+func TestWorkerWarmupRequestsAreReplayedBeforeTraffic(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		// the two warm-up requests were handled by the worker script before the first real request
+		assert.Equal(t, "requests:3", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+	}, &testOptions{
+		workerScript:       "worker-with-counter.php",
+		nbWorkers:          1,
+		nbParallelRequests: 1,
+		workerOpts: []frankenphp.WorkerOption{frankenphp.WithWorkerWarmup(
+			frankenphp.WarmupRequest{Path: "/"},
+			frankenphp.WarmupRequest{Path: "/products", Method: http.MethodPost, Header: http.Header{"Accept": []string{"application/json"}}},
+		)},
+	})
+}
+
+func TestWorkerWarmupRequestsAreNotReplayedAfterMaxRequests(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		assert.Equal(t, "requests:2", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+		assert.Equal(t, "requests:3", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+
+		// the worker script restarted gracefully after max_requests, without replaying the warm-up
+		assert.Equal(t, "requests:1", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+	}, &testOptions{
+		workerScript:       "worker-with-counter.php",
+		nbWorkers:          1,
+		nbParallelRequests: 1,
+		workerOpts: []frankenphp.WorkerOption{
+			frankenphp.WithWorkerMaxRequests(2),
+			frankenphp.WithWorkerWarmup(frankenphp.WarmupRequest{Path: "/"}),
+		},
+	})
+}
+
+func TestAddAndRemoveWorkersAtRuntime(t *testing.T) {
+	cwd, _ := os.Getwd()
+	testDataDir := cwd + "/testdata/"
+
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		require.NoError(t, frankenphp.AddWorker("counter", testDataDir+"worker-with-counter.php", 1, nil))
+		assert.ErrorIs(t, frankenphp.AddWorker("too-many", testDataDir+"worker.php", 3, nil), frankenphp.ErrNotEnoughSpareThreads)
+
+		assert.Equal(t, "requests:1", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+		assert.Equal(t, "requests:2", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+
+		assert.ErrorIs(t, frankenphp.RemoveWorker("unknown"), frankenphp.ErrWorkerNotFound)
+		require.NoError(t, frankenphp.RemoveWorker("counter"))
+		_, err := frankenphp.RestartWorker("counter")
+		assert.ErrorIs(t, err, frankenphp.ErrWorkerNotFound)
+
+		// the threads of the removed worker can be reused
+		require.NoError(t, frankenphp.AddWorker("counter", testDataDir+"worker-with-counter.php", 1, nil))
+		assert.Equal(t, "requests:1", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+	}, &testOptions{
+		nbParallelRequests: 1,
+		initOpts:           []frankenphp.Option{frankenphp.WithNumThreads(2), frankenphp.WithMaxThreads(4)},
+	})
+
+	assert.ErrorIs(t, frankenphp.RemoveWorker("counter"), frankenphp.ErrNotRunning)
+}
+
+func TestRequestsForAnUnknownWorkerNameUseRegularThreads(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		assert.Equal(t, "I am by birth a Genevese (i not set)", fetchBody("GET", "http://example.com/index.php", handler))
+	}, &testOptions{
+		nbParallelRequests: 1,
+		requestOpts:        []frankenphp.RequestOption{frankenphp.WithWorkerName("unknown")},
+	})
+}
+
+func TestReplaceWorkerAtRuntime(t *testing.T) {
+	cwd, _ := os.Getwd()
+	testDataDir := cwd + "/testdata/"
+
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		require.NoError(t, frankenphp.AddWorker("counter", testDataDir+"worker-with-counter.php", 1, nil))
+		assert.Equal(t, "requests:1", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+		assert.Equal(t, "requests:2", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+
+		assert.ErrorIs(t, frankenphp.ReplaceWorker("unknown", testDataDir+"worker.php", 1, nil), frankenphp.ErrWorkerNotFound)
+		assert.ErrorIs(t, frankenphp.ReplaceWorker("counter", testDataDir+"worker-with-counter.php", 4, nil), frankenphp.ErrNotEnoughSpareThreads)
+
+		// the new version has booted before the old one was stopped
+		require.NoError(t, frankenphp.ReplaceWorker("counter", testDataDir+"worker-with-counter.php", 2, nil))
+		assert.Equal(t, "requests:1", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+
+		workerThreads := 0
+		for _, thread := range frankenphp.DebugState().ThreadDebugStates {
+			if thread.WorkerName == "counter" {
+				workerThreads++
+			}
+		}
+		assert.Equal(t, 2, workerThreads)
+	}, &testOptions{
+		nbParallelRequests: 1,
+		initOpts:           []frankenphp.Option{frankenphp.WithNumThreads(2), frankenphp.WithMaxThreads(5)},
+	})
+}
+
+func TestReplaceWorkerKeepsTheOldVersionIfTheNewOneFailsToBoot(t *testing.T) {
+	cwd, _ := os.Getwd()
+	testDataDir := cwd + "/testdata/"
+
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		require.NoError(t, frankenphp.AddWorker("counter", testDataDir+"worker-with-counter.php", 1, nil))
+		assert.Equal(t, "requests:1", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+
+		// the new version never reaches frankenphp_handle_request(), the process must not panic
+		err := frankenphp.ReplaceWorker(
+			"counter",
+			testDataDir+"crash-loop-worker.php",
+			1,
+			map[string]string{"MARKER": filepath.Join(t.TempDir(), "missing")},
+			frankenphp.WithWorkerBackoff(time.Millisecond, 10*time.Millisecond, 2),
+		)
+		assert.Error(t, err)
+
+		assert.Equal(t, "requests:2", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+	}, &testOptions{
+		nbParallelRequests: 1,
+		initOpts:           []frankenphp.Option{frankenphp.WithNumThreads(2), frankenphp.WithMaxThreads(4)},
+	})
+}
+
+func TestBlueGreenRestartWorkers(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		assert.Equal(t, "requests:1", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+		assert.Equal(t, "requests:2", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+
+		assert.NoError(t, frankenphp.BlueGreenRestartWorkers())
+
+		assert.Equal(t, "requests:1", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+	}, &testOptions{
+		workerScript:       "worker-with-counter.php",
+		nbWorkers:          1,
+		nbParallelRequests: 1,
+		initOpts:           []frankenphp.Option{frankenphp.WithNumThreads(2), frankenphp.WithMaxThreads(4)},
+	})
+}
+
+func TestBlueGreenRestartKeepsTheOldGenerationWithoutSpareThreads(t *testing.T) {
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		assert.Equal(t, "requests:1", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+
+		assert.ErrorIs(t, frankenphp.BlueGreenRestartWorkers(), frankenphp.ErrNotEnoughSpareThreads)
+
+		assert.Equal(t, "requests:2", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+	}, &testOptions{
+		workerScript:       "worker-with-counter.php",
+		nbWorkers:          1,
+		nbParallelRequests: 1,
+		initOpts:           []frankenphp.Option{frankenphp.WithNumThreads(2), frankenphp.WithMaxThreads(2)},
+	})
+}
+
 func ExampleServeHTTP_workers() {
 	if err := frankenphp.Init(
 		frankenphp.WithWorkers("worker1", "worker1.php", 4, map[string]string{"ENV1": "foo"}, []string{}),
@@ -156,3 +426,56 @@ func TestWorkerHasOSEnvironmentVariableInSERVER(t *testing.T) {
 		assert.Contains(t, string(body), "custom_env_variable_value")
 	}, &testOptions{workerScript: "worker.php", nbWorkers: 1, nbParallelRequests: 1})
 }
+
+func TestHealthChecks(t *testing.T) {
+	assert.ErrorIs(t, frankenphp.CheckLiveness(), frankenphp.ErrNotLive)
+
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		assert.NoError(t, frankenphp.CheckLiveness())
+		assert.NoError(t, frankenphp.CheckReadiness(frankenphp.ReadinessOptions{MaxQueueDepth: 1}))
+		assert.Equal(t, "requests:1", fetchBody("GET", "http://example.com/worker-with-counter.php", handler))
+	}, &testOptions{
+		workerScript:       "worker-with-counter.php",
+		nbWorkers:          1,
+		nbParallelRequests: 1,
+	})
+
+	assert.ErrorIs(t, frankenphp.CheckReadiness(frankenphp.ReadinessOptions{}), frankenphp.ErrNotReady)
+}
+
+func TestDisabledWorkerRecoversInTheBackground(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "booted")
+	page := filepath.Join(dir, "503.html")
+	require.NoError(t, os.WriteFile(page, []byte("<p>maintenance</p>"), 0644))
+
+	runTest(t, func(handler func(http.ResponseWriter, *http.Request), _ *httptest.Server, i int) {
+		req := httptest.NewRequest("GET", "http://example.com/crash-loop-worker.php", nil)
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		resp := w.Result()
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "<p>maintenance</p>", string(body))
+		assert.ErrorIs(t, frankenphp.CheckReadiness(frankenphp.ReadinessOptions{}), frankenphp.ErrNotReady)
+
+		require.NoError(t, os.WriteFile(marker, nil, 0644))
+
+		assert.Eventually(t, func() bool {
+			return fetchBody("GET", "http://example.com/crash-loop-worker.php", handler) == "recovered"
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.NoError(t, frankenphp.CheckReadiness(frankenphp.ReadinessOptions{}))
+	}, &testOptions{
+		workerScript:       "crash-loop-worker.php",
+		nbWorkers:          1,
+		nbParallelRequests: 1,
+		env:                map[string]string{"MARKER": marker},
+		workerOpts: []frankenphp.WorkerOption{
+			frankenphp.WithWorkerCrashLoopPolicy(frankenphp.CrashLoopPolicyDisable),
+			frankenphp.WithWorkerBackoff(time.Millisecond, 10*time.Millisecond, 2),
+			frankenphp.WithWorkerUnavailablePage(page),
+		},
+	})
+}